@@ -3,42 +3,214 @@ package main
 import (
 	"context"
 	"fmt"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sync/errgroup"
 	"log/slog"
+	gohttp "net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	"user-segmentation/internal/api/grpc"
 	"user-segmentation/internal/api/http"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/cache"
 	"user-segmentation/internal/config"
+	"user-segmentation/internal/feed"
+	"user-segmentation/internal/health"
 	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/outbox"
+	"user-segmentation/internal/ratelimit"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/repo/apikeys"
+	"user-segmentation/internal/repo/attributes"
+	"user-segmentation/internal/repo/experiments"
+	"user-segmentation/internal/repo/flags"
 	"user-segmentation/internal/repo/history"
+	"user-segmentation/internal/repo/notify"
+	outboxrepo "user-segmentation/internal/repo/outbox"
 	"user-segmentation/internal/repo/segments"
+	whrepo "user-segmentation/internal/repo/webhooks"
+	"user-segmentation/internal/schedule"
 	"user-segmentation/internal/service"
+	"user-segmentation/internal/tracing"
+	"user-segmentation/internal/webhooks"
+	"user-segmentation/migrations"
 )
 
+// userCacheChannel broadcasts invalidations of user segments cache between replicas
+const userCacheChannel = "user_segments_invalidation"
+
 func main() {
 	cfg := config.MustLoad()
 
 	eg, ctx := errgroup.WithContext(context.Background())
 	log := logger.Create(cfg.Env)
+	// workers log without request context, so they get the default logger
+	slog.SetDefault(log)
 	log.Info("starting app", slog.String("env", cfg.Env))
 
-	conn, err := pgx.Connect(ctx, cfg.DbConn)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		File:        cfg.Tracing.File,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Error("cannot setup tracing", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	defer func() {
+		shCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shCtx); err != nil {
+			log.Error("cannot flush traces", slog.String("error", err.Error()))
+		}
+	}()
+
+	poolCfg, err := pgxpool.ParseConfig(cfg.DbConn)
+	if err != nil {
+		log.Error("invalid database connection string", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	poolCfg.ConnConfig.Tracer = tracing.PgxTracer{}
+	conn, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		log.Error("cannot create connection pool", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	err = conn.Ping(ctx)
 	for i := 0; i < 5 && err != nil; i++ {
 		time.Sleep(time.Second * 3)
 		log.Info("reconnect to PostgreSQL", slog.Int("attempt", i+1))
-		conn, err = pgx.Connect(ctx, cfg.DbConn)
+		err = conn.Ping(ctx)
 	}
 	if err != nil {
 		log.Error("cannot connect to database", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
-	defer func() { _ = conn.Close(ctx) }()
-	svc := service.New(segments.New(conn), history.New(conn))
+	defer conn.Close()
+	prometheus.MustRegister(metrics.NewPoolCollector(conn))
+	segmentsRepo := segments.New(conn)
+	expectedSchema, err := migrations.Version()
+	if err != nil {
+		log.Error("cannot get schema version", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	hc := health.New()
+	hc.Add("postgres", health.Ping(conn))
+	hc.Add("migrations", health.Migrations(conn, expectedSchema))
+	eg.Go(hc.Worker("members-refresh", func() error {
+		return metrics.RefreshMembers(ctx, segmentsRepo, cfg.MembersRefresh)
+	}))
+	var userSegments service.SegmentsRepo = segmentsRepo
+	var experimentsRepo service.ExperimentsRepo = experiments.New(conn)
+	if cfg.UserCache.Size > 0 {
+		invalidations := notify.New(conn, userCacheChannel)
+		usersCache := cache.NewSegments(segmentsRepo, cfg.UserCache.Size, cfg.UserCache.TTL, invalidations)
+		eg.Go(hc.Worker("cache-invalidation", func() error {
+			return invalidations.Listen(ctx, usersCache.Invalidate, usersCache.Purge)
+		}))
+		userSegments = usersCache
+		// every read of user segments checks the experiments, so their list is cached as well
+		experimentsRepo = cache.NewExperiments(experimentsRepo, cfg.UserCache.TTL)
+	}
+	historyRepo := history.New(conn)
+	tx := repo.NewTransactor(conn)
+	svcOpts := []service.Option{
+		service.WithTransactions(tx),
+		service.WithAliasTTL(cfg.AliasTTL),
+		service.WithExperiments(experimentsRepo),
+		service.WithAttributes(attributes.New(conn)),
+		service.WithFlags(flags.New(conn)),
+	}
+	if cfg.Outbox.Publisher != outbox.PublisherNone {
+		publisher, closePublisher, err := newPublisher(cfg.Outbox)
+		if err != nil {
+			log.Error("cannot create outbox publisher", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		defer closePublisher()
+		outboxRepo := outboxrepo.New(conn)
+		relay := outbox.Relay{
+			Repo:      outboxRepo,
+			Tx:        tx,
+			Publisher: publisher,
+			Interval:  cfg.Outbox.Interval,
+			BatchSize: cfg.Outbox.BatchSize,
+		}
+		eg.Go(hc.Worker("outbox-relay", func() error {
+			return relay.Run(ctx)
+		}))
+		svcOpts = append(svcOpts, service.WithOutbox(outboxRepo))
+		log.Info("outbox enabled", slog.String("publisher", cfg.Outbox.Publisher))
+	}
+	svc := service.New(userSegments, historyRepo, svcOpts...)
+	scheduler := schedule.Scheduler{Switcher: svc, Interval: cfg.ScheduleInterval}
+	eg.Go(hc.Worker("scheduler", func() error {
+		return scheduler.Run(ctx)
+	}))
+	changes := feed.New(historyRepo)
+	operationsChannel := notify.New(conn, feed.Channel)
+	eg.Go(hc.Worker("change-feed", func() error {
+		return changes.Run(ctx)
+	}))
+	eg.Go(hc.Worker("change-feed-listener", func() error {
+		return operationsChannel.Listen(ctx, changes.Notify, changes.Reset)
+	}))
+
+	webhooksRepo := whrepo.New(conn)
+	dispatcher := webhooks.Dispatcher{
+		Repo:        webhooksRepo,
+		Client:      &gohttp.Client{Timeout: cfg.Webhooks.Timeout},
+		Interval:    cfg.Webhooks.PollInterval,
+		BatchSize:   cfg.Webhooks.BatchSize,
+		Concurrency: cfg.Webhooks.Concurrency,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Backoff:     cfg.Webhooks.Backoff,
+	}
+	eg.Go(hc.Worker("webhooks", func() error {
+		return dispatcher.Run(ctx)
+	}))
+
+	keys := auth.NewKeys(apikeys.New(conn), cfg.AdminAPIKey)
+	authenticators := auth.Chain{keys}
+	if cfg.JWT.JWKS != "" {
+		jwks, err := auth.LoadJWKS(ctx, cfg.JWT.JWKS)
+		if err != nil {
+			log.Error("cannot load jwks", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		authenticators = append(authenticators, auth.JWT{
+			Keys:     jwks,
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+			Claim:    cfg.JWT.Claim,
+			Leeway:   30 * time.Second,
+		})
+		log.Info("jwt authentication enabled", slog.String("issuer", cfg.JWT.Issuer))
+	}
+	readLimit := newLimiter(ctx, eg, hc, "read", cfg.RateLimit.ReadRPS, cfg.RateLimit.ReadBurst)
+	writeLimit := newLimiter(ctx, eg, hc, "write", cfg.RateLimit.WriteRPS, cfg.RateLimit.WriteBurst)
+	httpOpts := []http.Option{
+		http.WithHealth(hc),
+		http.WithKeys(keys),
+		http.WithFeed(changes),
+		http.WithWebhooks(webhooks.New(webhooksRepo)),
+		http.WithRateLimit(readLimit, writeLimit),
+		http.WithTrustedProxies(cfg.TrustedProxies),
+	}
+	grpcOpts := []grpc.Option{grpc.WithRateLimit(readLimit, writeLimit)}
+	if cfg.AuthEnabled {
+		httpOpts = append(httpOpts, http.WithAuth(authenticators))
+		grpcOpts = append(grpcOpts, grpc.WithAuth(authenticators))
+	} else {
+		log.Warn("authentication is disabled, API is available to anyone")
+	}
 
-	srv := http.New(log, cfg.HTTPAddr, cfg.Env, svc)
+	srv := http.New(log, cfg.HTTPAddr, cfg.Env, svc, httpOpts...)
+	srvGRPC := grpc.New(log, cfg.GRPCAddr, svc, grpcOpts...)
 	sigQuit := make(chan os.Signal, 1)
 	signal.Ignore(syscall.SIGHUP, syscall.SIGPIPE)
 	signal.Notify(sigQuit, syscall.SIGINT, syscall.SIGTERM)
@@ -46,6 +218,13 @@ func main() {
 	eg.Go(func() error {
 		select {
 		case s := <-sigQuit:
+			hc.Drain()
+			srvGRPC.Drain()
+			log.Info("draining before shutdown", slog.String("delay", cfg.DrainDelay.String()))
+			select {
+			case <-time.After(cfg.DrainDelay):
+			case <-sigQuit:
+			}
 			return fmt.Errorf("captured signal: %v", s)
 		case <-ctx.Done():
 			return nil
@@ -55,8 +234,42 @@ func main() {
 	eg.Go(func() (err error) {
 		return srv.Listen(ctx)
 	})
+	eg.Go(func() (err error) {
+		return srvGRPC.Listen(ctx)
+	})
 	if err := eg.Wait(); err != nil {
 		log.Error("caught error for graceful shutdown", slog.String("error", err.Error()))
 	}
 	log.Info("server has been shutdown successfully")
 }
+
+func newPublisher(cfg config.Outbox) (outbox.Publisher, func(), error) {
+	switch cfg.Publisher {
+	case outbox.PublisherStdout:
+		return outbox.NewWriterPublisher(os.Stdout), func() {}, nil
+	case outbox.PublisherFile:
+		p, err := outbox.NewFilePublisher(cfg.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, func() { _ = p.Close() }, nil
+	case outbox.PublisherHTTP:
+		if cfg.URL == "" {
+			return nil, nil, fmt.Errorf("%w: http publisher needs url", outbox.ErrUnknownPublisher)
+		}
+		return outbox.HTTPPublisher{URL: cfg.URL, Client: &gohttp.Client{Timeout: cfg.Timeout}}, func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %s", outbox.ErrUnknownPublisher, cfg.Publisher)
+}
+
+func newLimiter(ctx context.Context, eg *errgroup.Group, hc *health.Health, name string, rps float64, burst int) *ratelimit.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	l := ratelimit.New(name, rps, burst)
+	prometheus.MustRegister(l)
+	eg.Go(hc.Worker("ratelimit-"+name, func() error {
+		return l.Run(ctx)
+	}))
+	return l
+}
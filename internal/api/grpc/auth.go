@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"user-segmentation/internal/api/grpc/pb"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/logger"
+)
+
+// methodRoles contains roles required by methods. Methods not listed here (health, reflection) are public
+var methodRoles = map[string]apikeys.Role{
+	pb.Segmentation_CreateSegment_FullMethodName:      apikeys.Admin,
+	pb.Segmentation_DeleteSegment_FullMethodName:      apikeys.Admin,
+	pb.Segmentation_GetSegment_FullMethodName:         apikeys.Reader,
+	pb.Segmentation_ListSegments_FullMethodName:       apikeys.Reader,
+	pb.Segmentation_UpdateSegment_FullMethodName:      apikeys.Admin,
+	pb.Segmentation_SetSegmentState_FullMethodName:    apikeys.Admin,
+	pb.Segmentation_SetSegmentRollout_FullMethodName:  apikeys.Admin,
+	pb.Segmentation_ChangeUserSegments_FullMethodName: apikeys.Editor,
+	pb.Segmentation_GetUserSegments_FullMethodName:    apikeys.Reader,
+	pb.Segmentation_ExportHistory_FullMethodName:      apikeys.Reader,
+}
+
+// mdHeader adapts incoming metadata to auth.Header
+type mdHeader metadata.MD
+
+func (h mdHeader) Get(key string) string {
+	v := metadata.MD(h).Get(key)
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+func authorize(ctx context.Context, a auth.Authenticator, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id, err := a.Authenticate(ctx, mdHeader(md))
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil, status.Error(codes.Unauthenticated, auth.ErrUnauthenticated.Error())
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, "api.grpc.authorize")
+		return nil, status.Error(codes.Internal, ErrInternal.Error())
+	}
+	if role, ok := methodRoles[method]; ok {
+		err := id.Allowed(role)
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	ctx = auth.WithIdentity(ctx, id)
+	return logger.WithLogger(ctx, logger.Log(ctx).With(slog.String("caller", id.Name))), nil
+}
+
+func unaryAuth(a auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(a auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, loggedStream{ServerStream: ss, ctx: ctx})
+	}
+}
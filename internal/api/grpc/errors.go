@@ -0,0 +1,52 @@
+package grpc
+
+import (
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/service"
+)
+
+var ErrInternal = errors.New("internal error")
+
+func toStatus(err error) error {
+	if err == nil || errors.Is(err, repo.ErrNoSegments) {
+		return nil
+	}
+	if errors.Is(err, repo.ErrSegmentAlreadyExists) || errors.Is(err, repo.ErrExperimentAlreadyExists) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, repo.ErrRelationNotFound) || errors.Is(err, repo.ErrSegmentNotFound) ||
+		errors.Is(err, repo.ErrExperimentNotFound) || errors.Is(err, repo.ErrParentNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, segments.ErrEmptySlug) || errors.Is(err, segments.ErrSlugToLong) || errors.Is(err, service.ErrInvalidDates) ||
+		errors.Is(err, segments.ErrInvalidSchedule) || errors.Is(err, segments.ErrUnknownState) ||
+		errors.Is(err, segments.ErrInvalidPercent) || errors.Is(err, segments.ErrInvalidRamp) ||
+		errors.Is(err, segments.ErrTooManySteps) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, segments.ErrDescriptionTooLong) || errors.Is(err, segments.ErrOwnerTooLong) ||
+		errors.Is(err, segments.ErrInvalidTag) || errors.Is(err, segments.ErrTooManyTags) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, experiments.ErrInvalidKey) || errors.Is(err, experiments.ErrInvalidVariant) ||
+		errors.Is(err, experiments.ErrDuplicateName) || errors.Is(err, experiments.ErrInvalidWeight) ||
+		errors.Is(err, experiments.ErrTooFewVariants) || errors.Is(err, experiments.ErrTooManyVariants) ||
+		errors.Is(err, experiments.ErrZeroWeights) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, segments.ErrInvalidTransition) || errors.Is(err, segments.ErrHasChildren) ||
+		errors.Is(err, segments.ErrArchived) || errors.Is(err, segments.ErrNotArchived) ||
+		errors.Is(err, segments.ErrParentArchived) || errors.Is(err, segments.ErrCycle) ||
+		errors.Is(err, segments.ErrGroupConflict) || errors.Is(err, experiments.ErrSegmentInUse) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, service.ErrExperimentsDisabled) {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	return status.Error(codes.Internal, ErrInternal.Error())
+}
@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"context"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"user-segmentation/internal/api/grpc/pb"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+)
+
+type segmentationServer struct {
+	pb.UnimplementedSegmentationServer
+	svc service.Service
+}
+
+func (s segmentationServer) CreateSegment(ctx context.Context, req *pb.SegmentRequest) (*pb.SegmentProcessed, error) {
+	err := toStatus(s.svc.CreateSegment(ctx, segments.Segment{Slug: req.GetSlug()}))
+	return &pb.SegmentProcessed{Done: err == nil}, err
+}
+
+func (s segmentationServer) DeleteSegment(ctx context.Context, req *pb.SegmentRequest) (*pb.SegmentProcessed, error) {
+	err := toStatus(s.svc.DeleteSegment(ctx, req.GetSlug()))
+	return &pb.SegmentProcessed{Done: err == nil}, err
+}
+
+func (s segmentationServer) GetSegment(ctx context.Context, req *pb.SegmentRequest) (*pb.SegmentInfo, error) {
+	seg, err := s.svc.GetSegment(ctx, req.GetSlug())
+	if err = toStatus(err); err != nil {
+		return nil, err
+	}
+	return segmentInfo(seg), nil
+}
+
+func (s segmentationServer) ListSegments(ctx context.Context, req *pb.ListSegmentsRequest) (*pb.SegmentList, error) {
+	seg, err := s.svc.ListSegments(ctx, req.GetTag(), req.GetOwner(), req.GetState())
+	if err = toStatus(err); err != nil {
+		return nil, err
+	}
+	res := &pb.SegmentList{Segments: make([]*pb.SegmentInfo, len(seg))}
+	for i := range seg {
+		res.Segments[i] = segmentInfo(seg[i])
+	}
+	return res, nil
+}
+
+func (s segmentationServer) UpdateSegment(ctx context.Context, req *pb.UpdateSegmentRequest) (*pb.SegmentInfo, error) {
+	var tags *[]string
+	if req.Tags != nil {
+		t := req.GetTags().GetTags()
+		tags = &t
+	}
+	seg, err := s.svc.UpdateSegment(ctx, req.GetSlug(), req.Description, req.Owner, tags)
+	if err = toStatus(err); err != nil {
+		return nil, err
+	}
+	return segmentInfo(seg), nil
+}
+
+func (s segmentationServer) SetSegmentState(ctx context.Context, req *pb.SetSegmentStateRequest) (*pb.SegmentInfo, error) {
+	seg, err := s.svc.SetSegmentState(ctx, req.GetSlug(), req.GetState())
+	if err = toStatus(err); err != nil {
+		return nil, err
+	}
+	return segmentInfo(seg), nil
+}
+
+func (s segmentationServer) SetSegmentRollout(ctx context.Context, req *pb.SetSegmentRolloutRequest) (*pb.Rollout, error) {
+	ramp := make([]segments.RampStep, len(req.GetRamp()))
+	for i, step := range req.GetRamp() {
+		ramp[i] = segments.RampStep{At: step.GetAt().AsTime(), Percent: step.GetPercent()}
+	}
+	seg, ramp, err := s.svc.SetSegmentRollout(ctx, req.GetSlug(), req.GetPercent(), ramp)
+	if err = toStatus(err); err != nil {
+		return nil, err
+	}
+	res := &pb.Rollout{Segment: seg.Slug, Ramp: make([]*pb.RampStep, len(ramp))}
+	if seg.Rollout != nil {
+		res.Percent = &seg.Rollout.Percent
+	}
+	for i, step := range ramp {
+		res.Ramp[i] = &pb.RampStep{At: timestamppb.New(step.At), Percent: step.Percent}
+	}
+	return res, nil
+}
+
+func (s segmentationServer) ChangeUserSegments(ctx context.Context, req *pb.ChangeUserSegmentsRequest) (*pb.ChangeResult, error) {
+	change := s.svc.ChangeUserSegments
+	if req.GetReplace() {
+		change = s.svc.ReplaceUserSegments
+	}
+	res, err := change(ctx, req.GetUserId(), req.GetAdd(), req.GetRemove())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.ChangeResult{
+		Done:   len(res) == 0,
+		Errors: res,
+	}, nil
+}
+
+func (s segmentationServer) GetUserSegments(ctx context.Context, req *pb.UserRequest) (*pb.UserSegments, error) {
+	seg, err := s.svc.GetUserSegments(ctx, req.GetUserId())
+	if err = toStatus(err); err != nil {
+		return nil, err
+	}
+	res := &pb.UserSegments{Segments: make([]*pb.Segment, len(seg))}
+	for i := range seg {
+		res.Segments[i] = &pb.Segment{Slug: seg[i].Slug}
+		if v := seg[i].Variant; v != nil {
+			res.Segments[i].Experiment, res.Segments[i].Variant = v.Experiment, v.Variant
+		}
+	}
+	return res, nil
+}
+
+func (s segmentationServer) ExportHistory(req *pb.HistoryRequest, stream pb.Segmentation_ExportHistoryServer) error {
+	var sendErr error
+	err := s.svc.ExportOperations(stream.Context(), int(req.GetYear()), int(req.GetMonth()), func(op operations.Operation) error {
+		sendErr = stream.Send(&pb.Operation{
+			UserId:  op.UserID,
+			Segment: op.Segment.Slug,
+			Type:    operationType(op.Type),
+			Time:    timestamppb.New(op.Time),
+			Details: op.Details,
+		})
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	return toStatus(err)
+}
+
+func segmentInfo(seg segments.Segment) *pb.SegmentInfo {
+	res := &pb.SegmentInfo{
+		Slug:        seg.Slug,
+		Description: seg.Description,
+		Owner:       seg.Owner,
+		Tags:        seg.Tags,
+		State:       seg.State.String(),
+		Group:       seg.Group,
+		Parent:      seg.Parent,
+		Rule:        seg.Rule,
+		CreatedAt:   timestamppb.New(seg.CreatedAt),
+		UpdatedAt:   timestamppb.New(seg.UpdatedAt),
+	}
+	if seg.ActiveFrom != nil {
+		res.ActiveFrom = timestamppb.New(*seg.ActiveFrom)
+	}
+	if seg.ActiveUntil != nil {
+		res.ActiveUntil = timestamppb.New(*seg.ActiveUntil)
+	}
+	if seg.Rollout != nil {
+		res.RolloutPercent = &seg.Rollout.Percent
+	}
+	return res
+}
+
+func operationType(t operations.Type) pb.Operation_Type {
+	switch t {
+	case operations.Remove:
+		return pb.Operation_REMOVE
+	case operations.Rename:
+		return pb.Operation_RENAME
+	case operations.Transition:
+		return pb.Operation_STATE
+	case operations.Activate:
+		return pb.Operation_ACTIVATE
+	case operations.Deactivate:
+		return pb.Operation_DEACTIVATE
+	case operations.Rollout:
+		return pb.Operation_ROLLOUT
+	}
+	return pb.Operation_ADD
+}
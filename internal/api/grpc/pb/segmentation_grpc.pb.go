@@ -0,0 +1,478 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: segmentation.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Segmentation_CreateSegment_FullMethodName      = "/segmentation.v1.Segmentation/CreateSegment"
+	Segmentation_DeleteSegment_FullMethodName      = "/segmentation.v1.Segmentation/DeleteSegment"
+	Segmentation_GetSegment_FullMethodName         = "/segmentation.v1.Segmentation/GetSegment"
+	Segmentation_ListSegments_FullMethodName       = "/segmentation.v1.Segmentation/ListSegments"
+	Segmentation_UpdateSegment_FullMethodName      = "/segmentation.v1.Segmentation/UpdateSegment"
+	Segmentation_SetSegmentState_FullMethodName    = "/segmentation.v1.Segmentation/SetSegmentState"
+	Segmentation_SetSegmentRollout_FullMethodName  = "/segmentation.v1.Segmentation/SetSegmentRollout"
+	Segmentation_ChangeUserSegments_FullMethodName = "/segmentation.v1.Segmentation/ChangeUserSegments"
+	Segmentation_GetUserSegments_FullMethodName    = "/segmentation.v1.Segmentation/GetUserSegments"
+	Segmentation_ExportHistory_FullMethodName      = "/segmentation.v1.Segmentation/ExportHistory"
+)
+
+// SegmentationClient is the client API for Segmentation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SegmentationClient interface {
+	CreateSegment(ctx context.Context, in *SegmentRequest, opts ...grpc.CallOption) (*SegmentProcessed, error)
+	DeleteSegment(ctx context.Context, in *SegmentRequest, opts ...grpc.CallOption) (*SegmentProcessed, error)
+	GetSegment(ctx context.Context, in *SegmentRequest, opts ...grpc.CallOption) (*SegmentInfo, error)
+	// ListSegments returns segments having the tag, the owner and the state, empty fields match any segment
+	ListSegments(ctx context.Context, in *ListSegmentsRequest, opts ...grpc.CallOption) (*SegmentList, error)
+	// UpdateSegment changes the metadata fields that are set in the request
+	UpdateSegment(ctx context.Context, in *UpdateSegmentRequest, opts ...grpc.CallOption) (*SegmentInfo, error)
+	SetSegmentState(ctx context.Context, in *SetSegmentStateRequest, opts ...grpc.CallOption) (*SegmentInfo, error)
+	// SetSegmentRollout sets the rollout percent of the segment and replaces its ramp, an empty ramp drops it
+	SetSegmentRollout(ctx context.Context, in *SetSegmentRolloutRequest, opts ...grpc.CallOption) (*Rollout, error)
+	ChangeUserSegments(ctx context.Context, in *ChangeUserSegmentsRequest, opts ...grpc.CallOption) (*ChangeResult, error)
+	GetUserSegments(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserSegments, error)
+	// ExportHistory streams operations made during the month, they are read from the database in batches
+	ExportHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (Segmentation_ExportHistoryClient, error)
+}
+
+type segmentationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSegmentationClient(cc grpc.ClientConnInterface) SegmentationClient {
+	return &segmentationClient{cc}
+}
+
+func (c *segmentationClient) CreateSegment(ctx context.Context, in *SegmentRequest, opts ...grpc.CallOption) (*SegmentProcessed, error) {
+	out := new(SegmentProcessed)
+	err := c.cc.Invoke(ctx, Segmentation_CreateSegment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) DeleteSegment(ctx context.Context, in *SegmentRequest, opts ...grpc.CallOption) (*SegmentProcessed, error) {
+	out := new(SegmentProcessed)
+	err := c.cc.Invoke(ctx, Segmentation_DeleteSegment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) GetSegment(ctx context.Context, in *SegmentRequest, opts ...grpc.CallOption) (*SegmentInfo, error) {
+	out := new(SegmentInfo)
+	err := c.cc.Invoke(ctx, Segmentation_GetSegment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) ListSegments(ctx context.Context, in *ListSegmentsRequest, opts ...grpc.CallOption) (*SegmentList, error) {
+	out := new(SegmentList)
+	err := c.cc.Invoke(ctx, Segmentation_ListSegments_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) UpdateSegment(ctx context.Context, in *UpdateSegmentRequest, opts ...grpc.CallOption) (*SegmentInfo, error) {
+	out := new(SegmentInfo)
+	err := c.cc.Invoke(ctx, Segmentation_UpdateSegment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) SetSegmentState(ctx context.Context, in *SetSegmentStateRequest, opts ...grpc.CallOption) (*SegmentInfo, error) {
+	out := new(SegmentInfo)
+	err := c.cc.Invoke(ctx, Segmentation_SetSegmentState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) SetSegmentRollout(ctx context.Context, in *SetSegmentRolloutRequest, opts ...grpc.CallOption) (*Rollout, error) {
+	out := new(Rollout)
+	err := c.cc.Invoke(ctx, Segmentation_SetSegmentRollout_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) ChangeUserSegments(ctx context.Context, in *ChangeUserSegmentsRequest, opts ...grpc.CallOption) (*ChangeResult, error) {
+	out := new(ChangeResult)
+	err := c.cc.Invoke(ctx, Segmentation_ChangeUserSegments_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) GetUserSegments(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserSegments, error) {
+	out := new(UserSegments)
+	err := c.cc.Invoke(ctx, Segmentation_GetUserSegments_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *segmentationClient) ExportHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (Segmentation_ExportHistoryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Segmentation_ServiceDesc.Streams[0], Segmentation_ExportHistory_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &segmentationExportHistoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Segmentation_ExportHistoryClient interface {
+	Recv() (*Operation, error)
+	grpc.ClientStream
+}
+
+type segmentationExportHistoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *segmentationExportHistoryClient) Recv() (*Operation, error) {
+	m := new(Operation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SegmentationServer is the server API for Segmentation service.
+// All implementations must embed UnimplementedSegmentationServer
+// for forward compatibility
+type SegmentationServer interface {
+	CreateSegment(context.Context, *SegmentRequest) (*SegmentProcessed, error)
+	DeleteSegment(context.Context, *SegmentRequest) (*SegmentProcessed, error)
+	GetSegment(context.Context, *SegmentRequest) (*SegmentInfo, error)
+	// ListSegments returns segments having the tag, the owner and the state, empty fields match any segment
+	ListSegments(context.Context, *ListSegmentsRequest) (*SegmentList, error)
+	// UpdateSegment changes the metadata fields that are set in the request
+	UpdateSegment(context.Context, *UpdateSegmentRequest) (*SegmentInfo, error)
+	SetSegmentState(context.Context, *SetSegmentStateRequest) (*SegmentInfo, error)
+	// SetSegmentRollout sets the rollout percent of the segment and replaces its ramp, an empty ramp drops it
+	SetSegmentRollout(context.Context, *SetSegmentRolloutRequest) (*Rollout, error)
+	ChangeUserSegments(context.Context, *ChangeUserSegmentsRequest) (*ChangeResult, error)
+	GetUserSegments(context.Context, *UserRequest) (*UserSegments, error)
+	// ExportHistory streams operations made during the month, they are read from the database in batches
+	ExportHistory(*HistoryRequest, Segmentation_ExportHistoryServer) error
+	mustEmbedUnimplementedSegmentationServer()
+}
+
+// UnimplementedSegmentationServer must be embedded to have forward compatible implementations.
+type UnimplementedSegmentationServer struct {
+}
+
+func (UnimplementedSegmentationServer) CreateSegment(context.Context, *SegmentRequest) (*SegmentProcessed, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSegment not implemented")
+}
+func (UnimplementedSegmentationServer) DeleteSegment(context.Context, *SegmentRequest) (*SegmentProcessed, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSegment not implemented")
+}
+func (UnimplementedSegmentationServer) GetSegment(context.Context, *SegmentRequest) (*SegmentInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSegment not implemented")
+}
+func (UnimplementedSegmentationServer) ListSegments(context.Context, *ListSegmentsRequest) (*SegmentList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSegments not implemented")
+}
+func (UnimplementedSegmentationServer) UpdateSegment(context.Context, *UpdateSegmentRequest) (*SegmentInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSegment not implemented")
+}
+func (UnimplementedSegmentationServer) SetSegmentState(context.Context, *SetSegmentStateRequest) (*SegmentInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSegmentState not implemented")
+}
+func (UnimplementedSegmentationServer) SetSegmentRollout(context.Context, *SetSegmentRolloutRequest) (*Rollout, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSegmentRollout not implemented")
+}
+func (UnimplementedSegmentationServer) ChangeUserSegments(context.Context, *ChangeUserSegmentsRequest) (*ChangeResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangeUserSegments not implemented")
+}
+func (UnimplementedSegmentationServer) GetUserSegments(context.Context, *UserRequest) (*UserSegments, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserSegments not implemented")
+}
+func (UnimplementedSegmentationServer) ExportHistory(*HistoryRequest, Segmentation_ExportHistoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportHistory not implemented")
+}
+func (UnimplementedSegmentationServer) mustEmbedUnimplementedSegmentationServer() {}
+
+// UnsafeSegmentationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SegmentationServer will
+// result in compilation errors.
+type UnsafeSegmentationServer interface {
+	mustEmbedUnimplementedSegmentationServer()
+}
+
+func RegisterSegmentationServer(s grpc.ServiceRegistrar, srv SegmentationServer) {
+	s.RegisterService(&Segmentation_ServiceDesc, srv)
+}
+
+func _Segmentation_CreateSegment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SegmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).CreateSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_CreateSegment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).CreateSegment(ctx, req.(*SegmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_DeleteSegment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SegmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).DeleteSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_DeleteSegment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).DeleteSegment(ctx, req.(*SegmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_GetSegment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SegmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).GetSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_GetSegment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).GetSegment(ctx, req.(*SegmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_ListSegments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSegmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).ListSegments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_ListSegments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).ListSegments(ctx, req.(*ListSegmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_UpdateSegment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSegmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).UpdateSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_UpdateSegment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).UpdateSegment(ctx, req.(*UpdateSegmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_SetSegmentState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSegmentStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).SetSegmentState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_SetSegmentState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).SetSegmentState(ctx, req.(*SetSegmentStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_SetSegmentRollout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSegmentRolloutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).SetSegmentRollout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_SetSegmentRollout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).SetSegmentRollout(ctx, req.(*SetSegmentRolloutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_ChangeUserSegments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeUserSegmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).ChangeUserSegments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_ChangeUserSegments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).ChangeUserSegments(ctx, req.(*ChangeUserSegmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_GetUserSegments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SegmentationServer).GetUserSegments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Segmentation_GetUserSegments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SegmentationServer).GetUserSegments(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Segmentation_ExportHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SegmentationServer).ExportHistory(m, &segmentationExportHistoryServer{stream})
+}
+
+type Segmentation_ExportHistoryServer interface {
+	Send(*Operation) error
+	grpc.ServerStream
+}
+
+type segmentationExportHistoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *segmentationExportHistoryServer) Send(m *Operation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Segmentation_ServiceDesc is the grpc.ServiceDesc for Segmentation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Segmentation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "segmentation.v1.Segmentation",
+	HandlerType: (*SegmentationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSegment",
+			Handler:    _Segmentation_CreateSegment_Handler,
+		},
+		{
+			MethodName: "DeleteSegment",
+			Handler:    _Segmentation_DeleteSegment_Handler,
+		},
+		{
+			MethodName: "GetSegment",
+			Handler:    _Segmentation_GetSegment_Handler,
+		},
+		{
+			MethodName: "ListSegments",
+			Handler:    _Segmentation_ListSegments_Handler,
+		},
+		{
+			MethodName: "UpdateSegment",
+			Handler:    _Segmentation_UpdateSegment_Handler,
+		},
+		{
+			MethodName: "SetSegmentState",
+			Handler:    _Segmentation_SetSegmentState_Handler,
+		},
+		{
+			MethodName: "SetSegmentRollout",
+			Handler:    _Segmentation_SetSegmentRollout_Handler,
+		},
+		{
+			MethodName: "ChangeUserSegments",
+			Handler:    _Segmentation_ChangeUserSegments_Handler,
+		},
+		{
+			MethodName: "GetUserSegments",
+			Handler:    _Segmentation_GetUserSegments_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportHistory",
+			Handler:       _Segmentation_ExportHistory_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "segmentation.proto",
+}
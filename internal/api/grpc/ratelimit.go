@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/ratelimit"
+)
+
+func clientKey(ctx context.Context) string {
+	if id, ok := auth.IdentityFrom(ctx); ok && id != auth.Anonymous {
+		return id.Name
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + host
+		}
+		return "ip:" + p.Addr.String()
+	}
+	return "unknown"
+}
+
+// checkLimit uses the read budget for methods available to readers and the write budget for the others
+func checkLimit(ctx context.Context, read *ratelimit.Limiter, write *ratelimit.Limiter, method string) error {
+	l := write
+	if methodRoles[method] <= apikeys.Reader {
+		l = read
+	}
+	if l == nil {
+		return nil
+	}
+	if ok, wait := l.Allow(clientKey(ctx)); !ok {
+		return status.Error(codes.ResourceExhausted, fmt.Sprintf("too many requests, retry after %v", wait))
+	}
+	return nil
+}
+
+func unaryLimit(read *ratelimit.Limiter, write *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkLimit(ctx, read, write, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamLimit(read *ratelimit.Limiter, write *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkLimit(ss.Context(), read, write, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,174 @@
+package grpc
+
+//go:generate protoc -I ../../../api/proto --go_out=../../.. --go_opt=module=user-segmentation --go-grpc_out=../../.. --go-grpc_opt=module=user-segmentation segmentation.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"time"
+	"user-segmentation/internal/api/grpc/pb"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/ratelimit"
+	"user-segmentation/internal/service"
+)
+
+type Server struct {
+	*grpc.Server
+	Addr   string
+	log    *slog.Logger
+	health *health.Server
+}
+
+type options struct {
+	auth       auth.Authenticator
+	readLimit  *ratelimit.Limiter
+	writeLimit *ratelimit.Limiter
+}
+
+type Option func(o *options)
+
+// WithAuth enables authentication of API callers. Without it every method is available to anyone
+func WithAuth(a auth.Authenticator) Option {
+	return func(o *options) {
+		o.auth = a
+	}
+}
+
+// WithRateLimit limits rate of requests of every client. Nil limiter disables the limit
+func WithRateLimit(read *ratelimit.Limiter, write *ratelimit.Limiter) Option {
+	return func(o *options) {
+		o.readLimit = read
+		o.writeLimit = write
+	}
+}
+
+func New(log *slog.Logger, addr string, svc service.Service, opts ...Option) *Server {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	unary := []grpc.UnaryServerInterceptor{unaryLogger(log)}
+	stream := []grpc.StreamServerInterceptor{streamLogger(log)}
+	if o.auth != nil {
+		log.Info("grpc authentication enabled")
+		unary = append(unary, unaryAuth(o.auth))
+		stream = append(stream, streamAuth(o.auth))
+	}
+	if o.readLimit != nil || o.writeLimit != nil {
+		unary = append(unary, unaryLimit(o.readLimit, o.writeLimit))
+		stream = append(stream, streamLimit(o.readLimit, o.writeLimit))
+	}
+	s := Server{
+		Server: grpc.NewServer(
+			grpc.StatsHandler(otelgrpc.NewServerHandler()),
+			grpc.ChainUnaryInterceptor(unary...),
+			grpc.ChainStreamInterceptor(stream...),
+		),
+		Addr:   addr,
+		log:    log,
+		health: health.NewServer(),
+	}
+	pb.RegisterSegmentationServer(s.Server, segmentationServer{svc: svc})
+	grpc_health_v1.RegisterHealthServer(s.Server, s.health)
+	reflection.Register(s.Server)
+	return &s
+}
+
+func (s *Server) Listen(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("grpc server can't listen: %w", err)
+	}
+	errCh := make(chan error, 1)
+	defer func() {
+		s.health.Shutdown()
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(10 * time.Second):
+			s.log.Error("can't gracefully stop grpc server", slog.String("addr", s.Addr))
+			s.Stop()
+		}
+	}()
+
+	go func() {
+		if err := s.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			errCh <- err
+		}
+	}()
+	s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		return fmt.Errorf("grpc server can't serve requests: %w", err)
+	}
+}
+
+// Drain reports NOT_SERVING to health checks, so clients stop sending new requests before Listen stops the server
+func (s *Server) Drain() {
+	s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+}
+
+func logRequest(ctx context.Context, method string, start time.Time, err error) {
+	logger.Log(ctx).InfoContext(
+		ctx,
+		"request handled",
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.String("duration", fmt.Sprintf("%13v", time.Since(start))),
+	)
+}
+
+// startRequest stores the request-scoped logger and returns the request ID to the client in the header
+func startRequest(ctx context.Context, log *slog.Logger) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx, id := logger.StartRequest(ctx, log, mdHeader(md).Get(logger.RequestIDHeader))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logger.RequestIDHeader, id))
+	return ctx
+}
+
+func unaryLogger(log *slog.Logger) grpc.UnaryServerInterceptor {
+	log.Info("grpc logger interceptor enabled")
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = startRequest(ctx, log)
+		resp, err := handler(ctx, req)
+		logRequest(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+type loggedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamLogger(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := startRequest(ss.Context(), log)
+		err := handler(srv, loggedStream{ServerStream: ss, ctx: ctx})
+		logRequest(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
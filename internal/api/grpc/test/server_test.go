@@ -0,0 +1,246 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+	grpcserver "user-segmentation/internal/api/grpc"
+	"user-segmentation/internal/api/grpc/pb"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func dial(t *testing.T, svc service.Service) *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpcserver.New(slog.Default(), "", svc)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestServer_GetUserSegments(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	r.
+		On("GetUserSegments", mock.Anything, int64(1)).
+		Return([]segments.Segment{{Slug: "slug-1"}, {Slug: "slug-2"}}, nil)
+	client := pb.NewSegmentationClient(dial(t, service.New(r, nil)))
+
+	res, err := client.GetUserSegments(context.Background(), &pb.UserRequest{UserId: 1})
+	require.NoError(t, err)
+	require.Len(t, res.GetSegments(), 2)
+	require.Equal(t, "slug-1", res.GetSegments()[0].GetSlug())
+}
+
+func TestServer_CreateSegment(t *testing.T) {
+	client := pb.NewSegmentationClient(dial(t, service.New(nil, nil)))
+
+	res, err := client.CreateSegment(context.Background(), &pb.SegmentRequest{Slug: ""})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.False(t, res.GetDone())
+}
+
+func TestServer_GetSegment(t *testing.T) {
+	percent := 25.0
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Get", mock.Anything, "slug").
+		Return(segments.Segment{
+			Slug:     "slug",
+			Metadata: segments.Metadata{Owner: "growth", Tags: []string{"exp"}},
+			State:    segments.Active,
+			Rollout:  &segments.Rollout{Percent: percent},
+		}, nil)
+	r.
+		On("List", mock.Anything, segments.Filter{Tag: "exp"}).
+		Return([]segments.Segment{{Slug: "slug"}, {Slug: "other"}}, nil)
+	client := pb.NewSegmentationClient(dial(t, service.New(r, nil)))
+
+	seg, err := client.GetSegment(context.Background(), &pb.SegmentRequest{Slug: "slug"})
+	require.NoError(t, err)
+	require.Equal(t, "growth", seg.GetOwner())
+	require.Equal(t, []string{"exp"}, seg.GetTags())
+	require.Equal(t, segments.Active.String(), seg.GetState())
+	require.Equal(t, percent, seg.GetRolloutPercent())
+	require.Nil(t, seg.ActiveFrom)
+
+	list, err := client.ListSegments(context.Background(), &pb.ListSegmentsRequest{Tag: "exp"})
+	require.NoError(t, err)
+	require.Len(t, list.GetSegments(), 2)
+	require.Nil(t, list.GetSegments()[0].RolloutPercent, "segments without rollouts have no percent")
+
+	_, err = client.ListSegments(context.Background(), &pb.ListSegmentsRequest{State: "unknown"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_UpdateSegment(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Update", mock.Anything, "slug", mock.MatchedBy(func(p segments.Patch) bool {
+			return p.Description == nil && p.Owner != nil && *p.Owner == "growth" && p.Tags != nil && len(*p.Tags) == 0
+		})).
+		Return(segments.Segment{Slug: "slug", Metadata: segments.Metadata{Owner: "growth"}}, nil).
+		Once()
+	r.
+		On("SetState", mock.Anything, "slug", mock.Anything, segments.Paused).
+		Return(segments.Segment{Slug: "slug", State: segments.Paused}, segments.Active, nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.Anything).
+		Return(nil).
+		Once()
+	client := pb.NewSegmentationClient(dial(t, service.New(r, h)))
+
+	owner := "growth"
+	seg, err := client.UpdateSegment(context.Background(), &pb.UpdateSegmentRequest{Slug: "slug", Owner: &owner, Tags: &pb.Tags{}})
+	require.NoError(t, err, "absent fields are not changed, empty tags remove them")
+	require.Equal(t, "growth", seg.GetOwner())
+
+	seg, err = client.SetSegmentState(context.Background(), &pb.SetSegmentStateRequest{Slug: "slug", State: segments.Paused.String()})
+	require.NoError(t, err)
+	require.Equal(t, segments.Paused.String(), seg.GetState())
+
+	_, err = client.SetSegmentState(context.Background(), &pb.SetSegmentStateRequest{Slug: "slug", State: "unknown"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_SetSegmentRollout(t *testing.T) {
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	percent := 10.0
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SetRollout", mock.Anything, "slug", &percent, mock.AnythingOfType("string"), []segments.RampStep{{At: at, Percent: 50}}).
+		Return(segments.Segment{Slug: "slug", Rollout: &segments.Rollout{Percent: percent}}, (*float64)(nil), nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.Anything).
+		Return(nil).
+		Once()
+	client := pb.NewSegmentationClient(dial(t, service.New(r, h)))
+
+	res, err := client.SetSegmentRollout(context.Background(), &pb.SetSegmentRolloutRequest{
+		Slug:    "slug",
+		Percent: percent,
+		Ramp:    []*pb.RampStep{{At: timestamppb.New(at), Percent: 50}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, percent, res.GetPercent())
+	require.Len(t, res.GetRamp(), 1)
+	require.True(t, res.GetRamp()[0].GetAt().AsTime().Equal(at))
+
+	_, err = client.SetSegmentRollout(context.Background(), &pb.SetSegmentRolloutRequest{Slug: "slug", Percent: 100.5})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Errors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{repo.ErrSegmentNotFound, codes.NotFound},
+		{repo.ErrParentNotFound, codes.NotFound},
+		{repo.ErrExperimentNotFound, codes.NotFound},
+		{repo.ErrSegmentAlreadyExists, codes.AlreadyExists},
+		{repo.ErrExperimentAlreadyExists, codes.AlreadyExists},
+		{segments.ErrInvalidTransition, codes.FailedPrecondition},
+		{segments.ErrArchived, codes.FailedPrecondition},
+		{segments.ErrNotArchived, codes.FailedPrecondition},
+		{segments.ErrParentArchived, codes.FailedPrecondition},
+		{segments.ErrHasChildren, codes.FailedPrecondition},
+		{experiments.ErrSegmentInUse, codes.FailedPrecondition},
+		{segments.ErrCycle, codes.FailedPrecondition},
+		{segments.ErrGroupConflict, codes.FailedPrecondition},
+		{segments.ErrDescriptionTooLong, codes.InvalidArgument},
+		{segments.ErrOwnerTooLong, codes.InvalidArgument},
+		{segments.ErrInvalidTag, codes.InvalidArgument},
+		{segments.ErrTooManyTags, codes.InvalidArgument},
+		{segments.ErrInvalidRamp, codes.InvalidArgument},
+		{experiments.ErrInvalidKey, codes.InvalidArgument},
+		{experiments.ErrZeroWeights, codes.InvalidArgument},
+		{service.ErrExperimentsDisabled, codes.Unimplemented},
+		{errors.New("connection reset"), codes.Internal},
+	}
+	for _, c := range cases {
+		r := mocks.NewSegmentsRepo(t)
+		r.
+			On("SetState", mock.Anything, "slug", mock.Anything, segments.Archived).
+			Return(segments.Segment{}, segments.Active, c.err).
+			Once()
+		client := pb.NewSegmentationClient(dial(t, service.New(r, nil)))
+
+		_, err := client.DeleteSegment(context.Background(), &pb.SegmentRequest{Slug: "slug"})
+		require.Equal(t, c.want, status.Code(err), c.err.Error())
+	}
+}
+
+func TestServer_ExportHistory(t *testing.T) {
+	now := time.Now().UTC()
+	r := mocks.NewHistoryRepo(t)
+	r.
+		On("GetPage", mock.Anything, 2023, 9, int64(0), mock.AnythingOfType("int")).
+		Return([]operations.Operation{
+			{ID: 1, UserID: 1, Segment: segments.Segment{Slug: "slug-1"}, Type: operations.Add, Time: now},
+			{ID: 2, UserID: 1, Segment: segments.Segment{Slug: "slug-1"}, Type: operations.Remove, Time: now},
+		}, nil)
+	client := pb.NewSegmentationClient(dial(t, service.New(nil, r)))
+
+	stream, err := client.ExportHistory(context.Background(), &pb.HistoryRequest{Year: 2023, Month: 9})
+	require.NoError(t, err)
+	var got []*pb.Operation
+	for {
+		op, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, op)
+	}
+	require.Len(t, got, 2)
+	require.Equal(t, pb.Operation_REMOVE, got[1].GetType())
+	require.True(t, got[0].GetTime().AsTime().Equal(now))
+
+	stream, err = client.ExportHistory(context.Background(), &pb.HistoryRequest{Year: 2023, Month: 13})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Health(t *testing.T) {
+	client := grpc_health_v1.NewHealthClient(dial(t, service.New(nil, nil)))
+
+	res, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, res.GetStatus())
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/logger"
+)
+
+// authenticate stores identity of the caller in the request context. Callers without credentials
+// are stored as anonymous, so the routes without role requirements stay public
+func authenticate(a auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := a.Authenticate(c, c.Request.Header)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				err = auth.ErrUnauthenticated
+			}
+			code, err := hideError(err)
+			c.AbortWithStatusJSON(code, errorResponse(c, err))
+			return
+		}
+		ctx := auth.WithIdentity(c.Request.Context(), id)
+		ctx = logger.WithLogger(ctx, logger.Log(ctx).With(slog.String("caller", id.Name)))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// allow rejects requests of callers with role lower than required
+func allow(role apikeys.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := auth.IdentityFrom(c.Request.Context())
+		if !ok {
+			// identity is always set when authentication is enabled
+			return
+		}
+		if err := id.Allowed(role); err != nil {
+			code, err := hideError(err)
+			c.AbortWithStatusJSON(code, errorResponse(c, err))
+		}
+	}
+}
+
+func authError(err error) (int, bool) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return http.StatusUnauthorized, true
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden, true
+	}
+	return 0, false
+}
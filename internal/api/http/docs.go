@@ -0,0 +1,32 @@
+package http
+
+import (
+	"embed"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+//go:embed docs/openapi.json
+var OpenAPISpec []byte
+
+//go:embed docs/swagger.html
+var swaggerUI []byte
+
+// swaggerAssets are the files of swagger-ui-dist 5.18.2 (Apache License 2.0), so the docs page
+// loads nothing from third-party hosts
+//
+//go:embed docs/swagger-ui
+var swaggerAssets embed.FS
+
+func getOpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", OpenAPISpec)
+}
+
+func getDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", swaggerUI)
+}
+
+// getDocsAsset serves a file of swagger-ui, the file parameter cannot leave the directory
+func getDocsAsset(c *gin.Context) {
+	c.FileFromFS("docs/swagger-ui/"+c.Param("file"), http.FS(swaggerAssets))
+}
@@ -1,8 +1,18 @@
 package http
 
 import (
+	"cmp"
+	"encoding/json"
 	"net/http"
+	"slices"
+	"time"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/flags"
+	"user-segmentation/internal/entities/operations"
 	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/entities/webhooks"
 	"user-segmentation/internal/service"
 )
 
@@ -10,7 +20,51 @@ type segment struct {
 	Slug string `json:"slug" binding:"required"`
 }
 
-type CreateSegmentRequest segment
+type CreateSegmentRequest struct {
+	Slug        string   `json:"slug" binding:"required"`
+	Description string   `json:"description"`
+	Owner       string   `json:"owner"`
+	Tags        []string `json:"tags"`
+	// State is active or draft, active by default
+	State       string     `json:"state"`
+	ActiveFrom  *time.Time `json:"active_from"`
+	ActiveUntil *time.Time `json:"active_until"`
+	// Rule makes members of the segment the users with matching attributes
+	Rule string `json:"rule"`
+}
+
+// SetRuleRequest replaces the rule of the segment, empty rule keeps current members as explicit ones
+type SetRuleRequest struct {
+	Rule string `json:"rule"`
+}
+
+// SetScheduleRequest replaces the schedule of the segment, missing bounds are open
+type SetScheduleRequest struct {
+	ActiveFrom  *time.Time `json:"active_from"`
+	ActiveUntil *time.Time `json:"active_until"`
+}
+
+type RampStepRequest struct {
+	At      time.Time `json:"at" binding:"required"`
+	Percent *float64  `json:"percent" binding:"required"`
+}
+
+// SetRolloutRequest sets the rollout percent and replaces the ramp, an empty ramp drops it
+type SetRolloutRequest struct {
+	Percent *float64          `json:"percent" binding:"required"`
+	Ramp    []RampStepRequest `json:"ramp" binding:"dive"`
+}
+
+type SetSegmentStateRequest struct {
+	State string `json:"state" binding:"required"`
+}
+
+// UpdateSegmentRequest changes only the fields present in the body
+type UpdateSegmentRequest struct {
+	Description *string   `json:"description"`
+	Owner       *string   `json:"owner"`
+	Tags        *[]string `json:"tags"`
+}
 
 type DeleteSegmentRequest segment
 
@@ -25,9 +79,131 @@ func errToSegmentProcessed(err error) SegmentProcessedResponse {
 	}
 }
 
+// RenameSegmentRequest contains the new slug of the segment
+type RenameSegmentRequest segment
+
 type ChangeUserSegmentsRequest struct {
 	Remove []string `json:"remove"`
 	Add    []string `json:"add"`
+	// Replace removes the user from other segments of exclusive groups of the added segments
+	Replace bool `json:"replace"`
+}
+
+type SetAttributesRequest struct {
+	Attributes map[string]any `json:"attributes" binding:"required"`
+}
+
+type AttributesResponse struct {
+	UserID     int64          `json:"user_id"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// UpsertAttributesRequest sets attributes of the users keeping their other attributes, null values remove attributes
+type UpsertAttributesRequest struct {
+	Users []UserAttributesRequest `json:"users" binding:"required,min=1,dive"`
+}
+
+type UserAttributesRequest struct {
+	UserID     int64          `json:"user_id"`
+	Attributes map[string]any `json:"attributes" binding:"required"`
+}
+
+func attributesToResponse(attrs map[int64]attributes.Attributes) []AttributesResponse {
+	res := make([]AttributesResponse, 0, len(attrs))
+	for id, a := range attrs {
+		res = append(res, AttributesResponse{UserID: id, Attributes: a})
+	}
+	slices.SortFunc(res, func(a, b AttributesResponse) int {
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+	return res
+}
+
+// AttributeChangeResponse has null old for a new attribute and null new for a removed one
+type AttributeChangeResponse struct {
+	Name string    `json:"name"`
+	Old  any       `json:"old"`
+	New  any       `json:"new"`
+	Time time.Time `json:"time"`
+}
+
+func attributeChangesToResponse(changes []attributes.Change) []AttributeChangeResponse {
+	res := make([]AttributeChangeResponse, len(changes))
+	for i, ch := range changes {
+		res[i] = AttributeChangeResponse{Name: ch.Name, Old: ch.Old, New: ch.New, Time: ch.Time}
+	}
+	return res
+}
+
+type DefineAttributeRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Type        string `json:"type" binding:"required"`
+	Description string `json:"description"`
+}
+
+type AttributeDefinitionResponse struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func definitionToResponse(d attributes.Definition) AttributeDefinitionResponse {
+	return AttributeDefinitionResponse{Name: d.Name, Type: string(d.Type), Description: d.Description, CreatedAt: d.CreatedAt}
+}
+
+func definitionsToResponse(defs []attributes.Definition) []AttributeDefinitionResponse {
+	res := make([]AttributeDefinitionResponse, len(defs))
+	for i := range defs {
+		res[i] = definitionToResponse(defs[i])
+	}
+	return res
+}
+
+// SetPayloadRequest replaces the payload of the segment, the payload is validated against the schema if it is set
+type SetPayloadRequest struct {
+	Payload  json.RawMessage `json:"payload" binding:"required"`
+	Schema   json.RawMessage `json:"schema"`
+	Priority int             `json:"priority"`
+}
+
+type PayloadResponse struct {
+	Value json.RawMessage `json:"value"`
+	// Schema is null for payloads without a schema
+	Schema   json.RawMessage `json:"schema"`
+	Priority int             `json:"priority"`
+}
+
+type ConfigResponse struct {
+	UserID int64          `json:"user_id"`
+	Config map[string]any `json:"config"`
+	// Segments are the slugs of the segments with payloads from the highest priority
+	Segments []string `json:"segments"`
+}
+
+// FlagsResponse contains the requested flags by keys
+type FlagsResponse struct {
+	UserID int64                   `json:"user_id"`
+	Flags  map[string]FlagResponse `json:"flags"`
+}
+
+type FlagResponse struct {
+	// Value is a boolean or the name of the variant of the experiment
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+func flagsToResponse(userID int64, fs []flags.Flag) FlagsResponse {
+	res := FlagsResponse{UserID: userID, Flags: make(map[string]FlagResponse, len(fs))}
+	for _, f := range fs {
+		res.Flags[f.Key] = FlagResponse{Value: f.Value, Source: string(f.Source)}
+	}
+	return res
+}
+
+// SetOverrideRequest sets the value of the flag for the user, the value is a boolean or a variant name
+type SetOverrideRequest struct {
+	Value any `json:"value" binding:"required"`
 }
 
 type ChangeResultResponse struct {
@@ -48,12 +224,344 @@ func changeResultToResponse(res service.ChangeErrors, err error) ChangeResultRes
 	}
 }
 
-type SegmentResponse segment
+// SegmentResponse names the experiment and the variant for segments of experiment variants
+type SegmentResponse struct {
+	Slug       string `json:"slug"`
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	// Implied is set for ancestors of the segments of the user
+	Implied bool `json:"implied,omitempty"`
+}
 
 func segmentsToResponse(seg []segments.Segment) []SegmentResponse {
 	res := make([]SegmentResponse, len(seg))
 	for i := range res {
-		res[i].Slug = seg[i].Slug
+		res[i].Slug, res[i].Implied = seg[i].Slug, seg[i].Implied
+		if v := seg[i].Variant; v != nil {
+			res[i].Experiment, res[i].Variant = v.Experiment, v.Variant
+		}
+	}
+	return res
+}
+
+type SegmentInfoResponse struct {
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+	Owner       string     `json:"owner"`
+	Tags        []string   `json:"tags"`
+	State       string     `json:"state"`
+	ActiveFrom  *time.Time `json:"active_from"`
+	ActiveUntil *time.Time `json:"active_until"`
+	Group       string     `json:"group"`
+	// Parent is empty for roots of trees
+	Parent string `json:"parent"`
+	// RolloutPercent is null if membership is not managed by a rollout
+	RolloutPercent *float64 `json:"rollout_percent"`
+	// Rule is empty for segments with explicit members
+	Rule string `json:"rule"`
+	// Payload is null for segments without configuration
+	Payload   *PayloadResponse `json:"payload"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+func segmentToInfoResponse(seg segments.Segment) SegmentInfoResponse {
+	tags := seg.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var percent *float64
+	if seg.Rollout != nil {
+		percent = &seg.Rollout.Percent
+	}
+	var payload *PayloadResponse
+	if p := seg.Payload; p != nil {
+		payload = &PayloadResponse{Value: p.Value, Schema: p.Schema, Priority: p.Priority}
+	}
+	return SegmentInfoResponse{
+		Slug:           seg.Slug,
+		Description:    seg.Description,
+		Owner:          seg.Owner,
+		Tags:           tags,
+		State:          seg.State.String(),
+		ActiveFrom:     seg.ActiveFrom,
+		ActiveUntil:    seg.ActiveUntil,
+		Group:          seg.Group,
+		Parent:         seg.Parent,
+		RolloutPercent: percent,
+		Rule:           seg.Rule,
+		Payload:        payload,
+		CreatedAt:      seg.CreatedAt,
+		UpdatedAt:      seg.UpdatedAt,
+	}
+}
+
+func segmentsToInfoResponse(seg []segments.Segment) []SegmentInfoResponse {
+	res := make([]SegmentInfoResponse, len(seg))
+	for i := range res {
+		res[i] = segmentToInfoResponse(seg[i])
+	}
+	return res
+}
+
+type CreateGroupRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Exclusive bool   `json:"exclusive"`
+}
+
+// SetGroupRequest moves the segment to the group, empty group removes the segment from its group
+type SetGroupRequest struct {
+	Group string `json:"group"`
+}
+
+// SetParentRequest makes the segment a child of the parent, empty parent makes the segment a root
+type SetParentRequest struct {
+	Parent string `json:"parent"`
+}
+
+type GroupResponse struct {
+	Name      string    `json:"name"`
+	Exclusive bool      `json:"exclusive"`
+	Segments  []string  `json:"segments"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func groupToResponse(g segments.Group) GroupResponse {
+	return GroupResponse{Name: g.Name, Exclusive: g.Exclusive, Segments: g.Segments, CreatedAt: g.CreatedAt}
+}
+
+func groupsToResponse(groups []segments.Group) []GroupResponse {
+	res := make([]GroupResponse, len(groups))
+	for i := range groups {
+		res[i] = groupToResponse(groups[i])
+	}
+	return res
+}
+
+type RampStepResponse struct {
+	At      time.Time `json:"at"`
+	Percent float64   `json:"percent"`
+}
+
+// RolloutResponse contains the steps of the ramp that are not applied yet, percent is null if the rollout is disabled
+type RolloutResponse struct {
+	Segment string             `json:"segment"`
+	Percent *float64           `json:"percent"`
+	Ramp    []RampStepResponse `json:"ramp"`
+}
+
+func rampFromRequest(steps []RampStepRequest) []segments.RampStep {
+	res := make([]segments.RampStep, len(steps))
+	for i, step := range steps {
+		res[i] = segments.RampStep{At: step.At, Percent: *step.Percent}
+	}
+	return res
+}
+
+func rolloutToResponse(seg segments.Segment, ramp []segments.RampStep) RolloutResponse {
+	res := RolloutResponse{Segment: seg.Slug, Ramp: make([]RampStepResponse, len(ramp))}
+	if seg.Rollout != nil {
+		res.Percent = &seg.Rollout.Percent
+	}
+	for i, step := range ramp {
+		res.Ramp[i] = RampStepResponse{At: step.At, Percent: step.Percent}
+	}
+	return res
+}
+
+type TransitionResponse struct {
+	Segment string    `json:"segment"`
+	Active  bool      `json:"active"`
+	At      time.Time `json:"at"`
+}
+
+func transitionsToResponse(trs []segments.Transition) []TransitionResponse {
+	res := make([]TransitionResponse, len(trs))
+	for i, tr := range trs {
+		res[i] = TransitionResponse{Segment: tr.Segment, Active: tr.Activate, At: tr.At}
+	}
+	return res
+}
+
+type VariantRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Weight int    `json:"weight"`
+}
+
+type CreateExperimentRequest struct {
+	Key         string           `json:"key" binding:"required"`
+	Description string           `json:"description"`
+	Variants    []VariantRequest `json:"variants" binding:"required"`
+}
+
+type VariantResponse struct {
+	Name    string `json:"name"`
+	Weight  int    `json:"weight"`
+	Segment string `json:"segment"`
+}
+
+type ExperimentResponse struct {
+	Key         string            `json:"key"`
+	Description string            `json:"description"`
+	Variants    []VariantResponse `json:"variants"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+func experimentToResponse(exp experiments.Experiment) ExperimentResponse {
+	variants := make([]VariantResponse, len(exp.Variants))
+	for i, v := range exp.Variants {
+		variants[i] = VariantResponse{Name: v.Name, Weight: v.Weight, Segment: v.Segment.Slug}
+	}
+	return ExperimentResponse{
+		Key:         exp.Key,
+		Description: exp.Description,
+		Variants:    variants,
+		CreatedAt:   exp.CreatedAt,
+	}
+}
+
+func experimentsToResponse(exps []experiments.Experiment) []ExperimentResponse {
+	res := make([]ExperimentResponse, len(exps))
+	for i := range exps {
+		res[i] = experimentToResponse(exps[i])
+	}
+	return res
+}
+
+type ChangeEventResponse struct {
+	ID        int64             `json:"id"`
+	UserID    int64             `json:"user_id"`
+	Segment   string            `json:"segment"`
+	Operation string            `json:"operation"`
+	Time      time.Time         `json:"time"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+func operationToChangeEvent(op operations.Operation) ChangeEventResponse {
+	return ChangeEventResponse{
+		ID:        op.ID,
+		UserID:    op.UserID,
+		Segment:   op.Segment.Slug,
+		Operation: op.Type.String(),
+		Time:      op.Time,
+		Details:   op.Details,
+	}
+}
+
+type IssueKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role" binding:"required"`
+}
+
+type KeyResponse struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type IssuedKeyResponse struct {
+	KeyResponse
+	Key string `json:"key"`
+}
+
+type KeyRevokedResponse SegmentProcessedResponse
+
+func keyToResponse(key apikeys.APIKey) KeyResponse {
+	return KeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Role:      key.Role.String(),
+		Prefix:    key.Prefix,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+func keysToResponse(keys []apikeys.APIKey) []KeyResponse {
+	res := make([]KeyResponse, len(keys))
+	for i := range keys {
+		res[i] = keyToResponse(keys[i])
+	}
+	return res
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret"`
+	Segments   []string `json:"segments"`
+	Operations []string `json:"operations"`
+}
+
+type WebhookResponse struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	Segments   []string  `json:"segments"`
+	Operations []string  `json:"operations"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type CreatedWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookProcessedResponse SegmentProcessedResponse
+
+func webhookToResponse(w webhooks.Webhook) WebhookResponse {
+	ops := make([]string, len(w.Operations))
+	for i := range ops {
+		ops[i] = w.Operations[i].String()
+	}
+	return WebhookResponse{
+		ID:         w.ID,
+		URL:        w.URL,
+		Segments:   w.Segments,
+		Operations: ops,
+		CreatedAt:  w.CreatedAt,
+	}
+}
+
+func webhooksToResponse(hooks []webhooks.Webhook) []WebhookResponse {
+	res := make([]WebhookResponse, len(hooks))
+	for i := range res {
+		res[i] = webhookToResponse(hooks[i])
+	}
+	return res
+}
+
+type DeliveryResponse struct {
+	ID            int64           `json:"id"`
+	OperationID   int64           `json:"operation_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	LastStatus    *int            `json:"last_status"`
+	LastError     *string         `json:"last_error"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+}
+
+func deliveriesToResponse(deliveries []webhooks.Delivery) []DeliveryResponse {
+	res := make([]DeliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		res[i] = DeliveryResponse{
+			ID:          d.ID,
+			OperationID: d.OperationID,
+			Payload:     d.Payload,
+			Status:      d.Status.String(),
+			Attempts:    d.Attempts,
+			LastStatus:  d.LastStatus,
+			LastError:   d.LastError,
+			CreatedAt:   d.CreatedAt,
+			DeliveredAt: d.DeliveredAt,
+		}
+		if d.Status == webhooks.Pending {
+			res[i].NextAttemptAt = &deliveries[i].NextAttemptAt
+		}
 	}
 	return res
 }
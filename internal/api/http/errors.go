@@ -4,9 +4,17 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/flags"
+	"user-segmentation/internal/entities/rules"
 	"user-segmentation/internal/entities/segments"
+	whentities "user-segmentation/internal/entities/webhooks"
+	"user-segmentation/internal/logger"
 	"user-segmentation/internal/repo"
 	"user-segmentation/internal/service"
+	"user-segmentation/internal/webhooks"
 )
 
 var (
@@ -18,35 +26,85 @@ func hideError(err error) (int, error) {
 	if err == nil || errors.Is(err, repo.ErrNoSegments) {
 		return http.StatusOK, nil
 	}
-	if errors.Is(err, repo.ErrSegmentAlreadyExists) {
+	if code, ok := authError(err); ok {
+		return code, err
+	}
+	if errors.Is(err, repo.ErrSegmentAlreadyExists) || errors.Is(err, segments.ErrInvalidTransition) ||
+		errors.Is(err, segments.ErrNotArchived) || errors.Is(err, repo.ErrExperimentAlreadyExists) ||
+		errors.Is(err, repo.ErrGroupAlreadyExists) || errors.Is(err, segments.ErrGroupConflict) ||
+		errors.Is(err, repo.ErrAttributeAlreadyExists) || errors.Is(err, attributes.ErrInUse) ||
+		errors.Is(err, segments.ErrCycle) || errors.Is(err, segments.ErrHasChildren) || errors.Is(err, segments.ErrParentArchived) ||
+		errors.Is(err, experiments.ErrSegmentInUse) {
 		return http.StatusConflict, err
 	}
-	if errors.Is(err, repo.ErrRelationNotFound) || errors.Is(err, repo.ErrSegmentNotFound) {
+	if errors.Is(err, repo.ErrRelationNotFound) || errors.Is(err, repo.ErrSegmentNotFound) || errors.Is(err, repo.ErrKeyNotFound) ||
+		errors.Is(err, repo.ErrWebhookNotFound) || errors.Is(err, repo.ErrDeliveryNotFound) || errors.Is(err, repo.ErrExperimentNotFound) ||
+		errors.Is(err, repo.ErrGroupNotFound) || errors.Is(err, repo.ErrAttributeNotFound) || errors.Is(err, repo.ErrOverrideNotFound) ||
+		errors.Is(err, repo.ErrParentNotFound) {
 		return http.StatusNotFound, err
 	}
-	if errors.Is(err, segments.ErrEmptySlug) || errors.Is(err, segments.ErrSlugToLong) || errors.Is(err, service.ErrInvalidDates) || errors.Is(err, ErrChanging) {
+	if errors.Is(err, segments.ErrEmptySlug) || errors.Is(err, segments.ErrSlugToLong) || errors.Is(err, service.ErrInvalidDates) || errors.Is(err, ErrChanging) ||
+		errors.Is(err, service.ErrSameSlug) || errors.Is(err, service.ErrNotConfirmed) || errors.Is(err, segments.ErrUnknownState) ||
+		errors.Is(err, segments.ErrInvalidSchedule) || errors.Is(err, segments.ErrInvalidPercent) ||
+		errors.Is(err, segments.ErrInvalidRamp) || errors.Is(err, segments.ErrTooManySteps) ||
+		errors.Is(err, segments.ErrInvalidPayload) || errors.Is(err, segments.ErrInvalidPayloadSchema) ||
+		errors.Is(err, segments.ErrPayloadTooLarge) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, segments.ErrDescriptionTooLong) || errors.Is(err, segments.ErrOwnerTooLong) ||
+		errors.Is(err, segments.ErrInvalidTag) || errors.Is(err, segments.ErrTooManyTags) ||
+		errors.Is(err, segments.ErrEmptyGroup) || errors.Is(err, segments.ErrGroupNameTooLong) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, experiments.ErrInvalidKey) || errors.Is(err, experiments.ErrInvalidVariant) ||
+		errors.Is(err, experiments.ErrDuplicateName) || errors.Is(err, experiments.ErrInvalidWeight) ||
+		errors.Is(err, experiments.ErrTooFewVariants) || errors.Is(err, experiments.ErrTooManyVariants) ||
+		errors.Is(err, experiments.ErrZeroWeights) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, rules.ErrInvalidRule) || errors.Is(err, attributes.ErrInvalidName) ||
+		errors.Is(err, attributes.ErrInvalidValue) || errors.Is(err, attributes.ErrTooManyValues) ||
+		errors.Is(err, attributes.ErrUnknownAttribute) || errors.Is(err, attributes.ErrUnknownType) ||
+		errors.Is(err, attributes.ErrDescriptionTooLong) || errors.Is(err, service.ErrTooManyUsers) ||
+		errors.Is(err, service.ErrInvalidLimit) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, flags.ErrInvalidKeys) || errors.Is(err, flags.ErrInvalidOverride) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, service.ErrExperimentsDisabled) || errors.Is(err, service.ErrAttributesDisabled) ||
+		errors.Is(err, service.ErrFlagsDisabled) {
+		return http.StatusNotImplemented, err
+	}
+	if errors.Is(err, apikeys.ErrEmptyName) || errors.Is(err, apikeys.ErrNameToLong) || errors.Is(err, apikeys.ErrUnknownRole) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, whentities.ErrInvalidURL) || errors.Is(err, whentities.ErrSecretTooShort) ||
+		errors.Is(err, whentities.ErrUnknownOperation) || errors.Is(err, whentities.ErrUnknownStatus) ||
+		errors.Is(err, webhooks.ErrInvalidLimit) {
 		return http.StatusBadRequest, err
 	}
 	return http.StatusInternalServerError, ErrInternal
 }
 
-func errorResponse(err error) gin.H {
+// errorResponse contains the request ID, so the client may find the request in logs
+func errorResponse(c *gin.Context, err error) gin.H {
 	return gin.H{
-		"data":  nil,
-		"error": err.Error(),
+		"data":       nil,
+		"error":      err.Error(),
+		"request_id": logger.RequestID(c),
 	}
 }
 
 func handleError(c *gin.Context, err error, data any) {
 	code, hidden := hideError(err)
-	var resErr any
-	if errors.Is(hidden, nil) {
-		resErr = nil
-	} else {
-		resErr = hidden.Error()
-	}
-	c.JSON(code, gin.H{
+	res := gin.H{
 		"data":  data,
-		"error": resErr,
-	})
+		"error": nil,
+	}
+	if !errors.Is(hidden, nil) {
+		res["error"] = hidden.Error()
+		res["request_id"] = logger.RequestID(c)
+	}
+	c.JSON(code, res)
 }
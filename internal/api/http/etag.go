@@ -0,0 +1,40 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// respondCached responds with data tagged by the hash of its JSON.
+// Clients revalidating with a matching If-None-Match get 304 without the body
+func respondCached(c *gin.Context, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		handleError(c, err, nil)
+		return
+	}
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	handleError(c, nil, data)
+}
+
+// etagMatches compares tags of If-None-Match weakly as RFC 9110 requires
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
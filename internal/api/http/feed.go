@@ -0,0 +1,77 @@
+package http
+
+import (
+	"errors"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/feed"
+	"user-segmentation/internal/logger"
+)
+
+const lastEventIDHeader = "Last-Event-ID"
+
+// sseSink writes operations as Server-Sent Events with operation IDs as event IDs
+type sseSink struct {
+	c *gin.Context
+}
+
+func (s sseSink) Send(op operations.Operation) error {
+	s.c.Render(-1, sse.Event{
+		Id:    strconv.FormatInt(op.ID, 10),
+		Event: op.Type.String(),
+		Data:  operationToChangeEvent(op),
+	})
+	s.c.Writer.Flush()
+	return s.c.Err()
+}
+
+func (s sseSink) KeepAlive() error {
+	if _, err := s.c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+func parseInt64(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 {
+		return 0, ErrInvalidRequest
+	}
+	return v, nil
+}
+
+func streamChanges(f *feed.Feed) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := parseInt64(c.Query("user_id"))
+		var lastID int64
+		if err == nil {
+			lastID, err = parseInt64(c.GetHeader(lastEventIDHeader))
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		filter := operations.Filter{UserID: userID, Segment: c.Query("segment")}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		// disables buffering of nginx
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+		err = f.Stream(c, filter, lastID, sseSink{c: c})
+		if err != nil && !errors.Is(err, feed.ErrClosed) {
+			// headers are sent, the client resumes by Last-Event-ID
+			logger.Log(c).InfoContext(c, "change stream interrupted", slog.String("error", err.Error()))
+		}
+	}
+}
@@ -4,10 +4,17 @@ import (
 	"encoding/csv"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/flags"
+	"user-segmentation/internal/entities/segments"
 	"user-segmentation/internal/logger"
 	"user-segmentation/internal/service"
+	"user-segmentation/internal/webhooks"
 )
 
 var ErrInvalidRequest = errors.New("invalid request")
@@ -16,19 +23,65 @@ func createSegment(svc service.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CreateSegmentRequest
 		if err := c.BindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
 			return
 		}
-		err := svc.CreateSegment(c, req.Slug)
+		seg := segments.Segment{
+			Slug:     req.Slug,
+			Metadata: segments.Metadata{Description: req.Description, Owner: req.Owner, Tags: req.Tags},
+			Schedule: segments.Schedule{ActiveFrom: req.ActiveFrom, ActiveUntil: req.ActiveUntil},
+			Rule:     req.Rule,
+		}
+		var err error
+		if req.State != "" {
+			seg.State, err = segments.ParseState(req.State)
+		}
+		if err == nil {
+			err = svc.CreateSegment(c, seg)
+		}
 		handleError(c, err, errToSegmentProcessed(err))
 	}
 }
 
+func listSegments(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := svc.ListSegments(c, c.Query("tag"), c.Query("owner"), c.Query("state"))
+		handleError(c, err, segmentsToInfoResponse(res))
+	}
+}
+
+func getSegment(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		seg, err := svc.GetSegment(c, c.Param("slug"))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func updateSegment(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateSegmentRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.UpdateSegment(c, c.Param("slug"), req.Description, req.Owner, req.Tags)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
 func deleteSegment(svc service.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req DeleteSegmentRequest
 		if err := c.BindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
 			return
 		}
 		err := svc.DeleteSegment(c, req.Slug)
@@ -36,6 +89,284 @@ func deleteSegment(svc service.Service) gin.HandlerFunc {
 	}
 }
 
+func renameSegment(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RenameSegmentRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.RenameSegment(c, c.Param("slug"), req.Slug)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		logger.Log(c).Info("segment renamed", slog.String("from", c.Param("slug")), slog.String("to", seg.Slug))
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func setSegmentState(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetSegmentStateRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.SetSegmentState(c, c.Param("slug"), req.State)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func setSegmentSchedule(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetScheduleRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.SetSegmentSchedule(c, c.Param("slug"), req.ActiveFrom, req.ActiveUntil)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func setSegmentRule(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetRuleRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.SetSegmentRule(c, c.Param("slug"), req.Rule)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func setSegmentPayload(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetPayloadRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.SetSegmentPayload(c, c.Param("slug"), req.Payload, req.Schema, req.Priority)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func removeSegmentPayload(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		seg, err := svc.RemoveSegmentPayload(c, c.Param("slug"))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func setSegmentRollout(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetRolloutRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, ramp, err := svc.SetSegmentRollout(c, c.Param("slug"), *req.Percent, rampFromRequest(req.Ramp))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		logger.Log(c).Info("segment rollout set", slog.String("segment", seg.Slug), slog.Float64("percent", *req.Percent))
+		handleError(c, nil, rolloutToResponse(seg, ramp))
+	}
+}
+
+func getSegmentRollout(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		seg, ramp, err := svc.GetSegmentRollout(c, c.Param("slug"))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, rolloutToResponse(seg, ramp))
+	}
+}
+
+func disableSegmentRollout(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		seg, err := svc.DisableSegmentRollout(c, c.Param("slug"))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, rolloutToResponse(seg, nil))
+	}
+}
+
+// upcomingTransitions lists transitions until the time in RFC 3339, a week ahead by default
+func upcomingTransitions(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		until := time.Now().Add(7 * 24 * time.Hour)
+		if q := c.Query("until"); q != "" {
+			t, err := time.Parse(time.RFC3339, q)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+				return
+			}
+			until = t
+		}
+		limit := 0
+		if q := c.Query("limit"); q != "" {
+			l, err := strconv.Atoi(q)
+			if err != nil || l < 0 {
+				c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+				return
+			}
+			limit = l
+		}
+		res, err := svc.UpcomingTransitions(c, until, limit)
+		handleError(c, err, transitionsToResponse(res))
+	}
+}
+
+func restoreSegment(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		seg, err := svc.RestoreSegment(c, c.Param("slug"))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func purgeSegment(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := svc.PurgeSegment(c, c.Param("slug"), c.Query("confirm"))
+		if err == nil {
+			logger.Log(c).Info("segment purged", slog.String("segment", c.Param("slug")))
+		}
+		handleError(c, err, errToSegmentProcessed(err))
+	}
+}
+
+func createGroup(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateGroupRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		g, err := svc.CreateGroup(c, req.Name, req.Exclusive)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, groupToResponse(g))
+	}
+}
+
+func listGroups(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := svc.ListGroups(c)
+		handleError(c, err, groupsToResponse(res))
+	}
+}
+
+func deleteGroup(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := svc.DeleteGroup(c, c.Param("name"))
+		handleError(c, err, errToSegmentProcessed(err))
+	}
+}
+
+func setSegmentGroup(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetGroupRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.SetSegmentGroup(c, c.Param("slug"), req.Group)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func setSegmentParent(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetParentRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		seg, err := svc.SetSegmentParent(c, c.Param("slug"), req.Parent)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, segmentToInfoResponse(seg))
+	}
+}
+
+func createExperiment(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateExperimentRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		variants := make([]experiments.Variant, len(req.Variants))
+		for i, v := range req.Variants {
+			variants[i] = experiments.Variant{Name: v.Name, Weight: v.Weight}
+		}
+		exp, err := svc.CreateExperiment(c, req.Key, req.Description, variants)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, experimentToResponse(exp))
+	}
+}
+
+func listExperiments(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := svc.ListExperiments(c)
+		handleError(c, err, experimentsToResponse(res))
+	}
+}
+
+func getExperiment(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exp, err := svc.GetExperiment(c, c.Param("key"))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, experimentToResponse(exp))
+	}
+}
+
 func changeUserSegments(svc service.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req ChangeUserSegmentsRequest
@@ -44,10 +375,14 @@ func changeUserSegments(svc service.Service) gin.HandlerFunc {
 			err = c.BindJSON(&req)
 		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
 			return
 		}
-		result, err := svc.ChangeUserSegments(c, int64(id), req.Add, req.Remove)
+		change := svc.ChangeUserSegments
+		if req.Replace {
+			change = svc.ReplaceUserSegments
+		}
+		result, err := change(c, int64(id), req.Add, req.Remove)
 		response := changeResultToResponse(result, err)
 		if !response.Done {
 			err = ErrChanging
@@ -56,14 +391,199 @@ func changeUserSegments(svc service.Service) gin.HandlerFunc {
 	}
 }
 
+func setUserAttributes(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetAttributesRequest
+		id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err == nil {
+			err = c.BindJSON(&req)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		attrs, err := svc.SetUserAttributes(c, id, req.Attributes)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, AttributesResponse{UserID: id, Attributes: attrs})
+	}
+}
+
+func getUserConfig(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		cfg, err := svc.GetUserConfig(c, id)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, ConfigResponse{UserID: id, Config: cfg.Values, Segments: cfg.Segments})
+	}
+}
+
+func getUserFlags(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		keys, err := flags.ParseKeys(c.Query("keys"))
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		fs, err := svc.GetUserFlags(c, id, keys)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		respondCached(c, flagsToResponse(id, fs))
+	}
+}
+
+func setFlagOverride(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		var req SetOverrideRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		key := c.Param("key")
+		if err := svc.SetFlagOverride(c, id, key, req.Value); err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, FlagResponse{Value: req.Value, Source: string(flags.SourceOverride)})
+	}
+}
+
+func deleteFlagOverride(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		err = svc.DeleteFlagOverride(c, id, c.Param("key"))
+		handleError(c, err, errToSegmentProcessed(err))
+	}
+}
+
+func getUserAttributes(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		attrs, err := svc.GetUserAttributes(c, id)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, AttributesResponse{UserID: id, Attributes: attrs})
+	}
+}
+
+func upsertUserAttributes(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpsertAttributesRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		values := make(map[int64]map[string]any, len(req.Users))
+		for _, u := range req.Users {
+			if _, ok := values[u.UserID]; ok {
+				c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+				return
+			}
+			values[u.UserID] = u.Attributes
+		}
+		res, err := svc.UpsertUserAttributes(c, values)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, attributesToResponse(res))
+	}
+}
+
+func getUserAttributeHistory(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		limit := 100
+		if err == nil && c.Query("limit") != "" {
+			limit, err = strconv.Atoi(c.Query("limit"))
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		res, err := svc.GetUserAttributeHistory(c, id, limit)
+		handleError(c, err, attributeChangesToResponse(res))
+	}
+}
+
+func defineAttribute(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req DefineAttributeRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		d, err := svc.DefineAttribute(c, req.Name, req.Type, req.Description)
+		if err != nil {
+			handleError(c, err, nil)
+			return
+		}
+		handleError(c, nil, definitionToResponse(d))
+	}
+}
+
+func listAttributes(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := svc.ListAttributes(c)
+		handleError(c, err, definitionsToResponse(res))
+	}
+}
+
+func deleteAttribute(svc service.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := svc.DeleteAttribute(c, c.Param("name"))
+		handleError(c, err, errToSegmentProcessed(err))
+	}
+}
+
 func getUserSegments(svc service.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.Atoi(c.Param("user_id"))
+		var implied bool
+		if err == nil && c.Query("implied") != "" {
+			implied, err = strconv.ParseBool(c.Query("implied"))
+		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
 			return
 		}
-		seg, err := svc.GetUserSegments(c, int64(id))
+		var seg []segments.Segment
+		if implied {
+			seg, err = svc.GetUserImpliedSegments(c, int64(id))
+		} else {
+			seg, err = svc.GetUserSegments(c, int64(id))
+		}
 		handleError(c, err, segmentsToResponse(seg))
 	}
 }
@@ -76,13 +596,14 @@ func getHistory(svc service.Service) gin.HandlerFunc {
 			month, err = strconv.Atoi(c.Param("month"))
 		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
 			return
 		}
 		ops, err := svc.GetHistory(c, year, month)
 		if err != nil {
 			code, err := hideError(err)
-			c.JSON(code, errorResponse(err))
+			c.JSON(code, errorResponse(c, err))
+			return
 		}
 		c.Writer.Header().Set("Content-Type", "text/csv")
 		c.Writer.Header().Set("Content-Disposition", "attachment;filename=history.csv")
@@ -97,3 +618,115 @@ func getHistory(svc service.Service) gin.HandlerFunc {
 		}
 	}
 }
+
+func issueKey(keys auth.Keys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req IssueKeyRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		key, secret, err := keys.Issue(c, req.Name, req.Role)
+		if err != nil {
+			code, err := hideError(err)
+			c.JSON(code, errorResponse(c, err))
+			return
+		}
+		logger.Log(c).Info("api key issued", slog.Int64("id", key.ID), slog.String("role", key.Role.String()))
+		handleError(c, nil, IssuedKeyResponse{KeyResponse: keyToResponse(key), Key: secret})
+	}
+}
+
+func listKeys(keys auth.Keys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := keys.List(c)
+		handleError(c, err, keysToResponse(res))
+	}
+}
+
+func revokeKey(keys auth.Keys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		err = keys.Revoke(c, id)
+		if err == nil {
+			logger.Log(c).Info("api key revoked", slog.Int64("id", id))
+		}
+		handleError(c, err, KeyRevokedResponse(errToSegmentProcessed(err)))
+	}
+}
+
+func createWebhook(w webhooks.Webhooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateWebhookRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		hook, err := w.Create(c, req.URL, req.Secret, req.Segments, req.Operations)
+		if err != nil {
+			code, err := hideError(err)
+			c.JSON(code, errorResponse(c, err))
+			return
+		}
+		logger.Log(c).Info("webhook created", slog.Int64("id", hook.ID), slog.String("url", hook.URL))
+		handleError(c, nil, CreatedWebhookResponse{WebhookResponse: webhookToResponse(hook), Secret: hook.Secret})
+	}
+}
+
+func listWebhooks(w webhooks.Webhooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := w.List(c)
+		handleError(c, err, webhooksToResponse(res))
+	}
+}
+
+func deleteWebhook(w webhooks.Webhooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		err = w.Delete(c, id)
+		if err == nil {
+			logger.Log(c).Info("webhook deleted", slog.Int64("id", id))
+		}
+		handleError(c, err, WebhookProcessedResponse(errToSegmentProcessed(err)))
+	}
+}
+
+func getDeliveries(w webhooks.Webhooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		limit := 100
+		if err == nil && c.Query("limit") != "" {
+			limit, err = strconv.Atoi(c.Query("limit"))
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		res, err := w.Deliveries(c, id, c.Query("status"), limit)
+		handleError(c, err, deliveriesToResponse(res))
+	}
+}
+
+func retryDelivery(w webhooks.Webhooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		var deliveryID int64
+		if err == nil {
+			deliveryID, err = strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, ErrInvalidRequest))
+			return
+		}
+		err = w.Retry(c, id, deliveryID)
+		handleError(c, err, WebhookProcessedResponse(errToSegmentProcessed(err)))
+	}
+}
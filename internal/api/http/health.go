@@ -0,0 +1,33 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"user-segmentation/internal/health"
+)
+
+type ReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func getLiveness(c *gin.Context) {
+	c.String(http.StatusOK, health.StatusOK)
+}
+
+func getReadiness(h *health.Health) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks, err := h.Ready(c)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, ReadinessResponse{Status: err.Error(), Checks: checks})
+			return
+		}
+		c.JSON(http.StatusOK, ReadinessResponse{Status: health.StatusOK, Checks: checks})
+	}
+}
+
+// setHealthRoutes registers probes of orchestrators. They are frequent, so they are set before logging and tracing
+func setHealthRoutes(r gin.IRouter, h *health.Health) {
+	r.GET("/healthz", getLiveness)
+	r.GET("/readyz", getReadiness(h))
+}
@@ -0,0 +1,17 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"time"
+	"user-segmentation/internal/metrics"
+)
+
+func observe(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/ratelimit"
+)
+
+var ErrTooManyRequests = errors.New("too many requests")
+
+// clientKey identifies the caller by its identity or, for anonymous callers, by IP
+func clientKey(c *gin.Context) string {
+	if id, ok := auth.IdentityFrom(c.Request.Context()); ok && id != auth.Anonymous {
+		return id.Name
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// limit takes tokens from the read budget for safe methods and from the write budget for the others.
+// Nil limiter means unlimited budget
+func limit(read *ratelimit.Limiter, write *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			l = read
+		}
+		if l == nil {
+			return
+		}
+		if ok, wait := l.Allow(clientKey(c)); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(c, ErrTooManyRequests))
+		}
+	}
+}
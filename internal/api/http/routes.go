@@ -2,18 +2,80 @@ package http
 
 import (
 	"github.com/gin-gonic/gin"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/feed"
 	"user-segmentation/internal/service"
+	"user-segmentation/internal/webhooks"
 )
 
 func SetRoutes(r gin.IRouter, svc service.Service) {
 	r.Any("/ping", func(c *gin.Context) {
 		c.String(200, "pong")
 	})
+	r.GET("/openapi.json", getOpenAPISpec)
+	r.GET("/docs", getDocs)
+	r.GET("/docs/:file", getDocsAsset)
 
-	r.POST("/segments", createSegment(svc))
-	r.DELETE("/segments", deleteSegment(svc))
+	r.POST("/segments", allow(apikeys.Admin), createSegment(svc))
+	r.DELETE("/segments", allow(apikeys.Admin), deleteSegment(svc))
+	r.GET("/segments", allow(apikeys.Reader), listSegments(svc))
+	r.GET("/segments/:slug", allow(apikeys.Reader), getSegment(svc))
+	r.PATCH("/segments/:slug", allow(apikeys.Admin), updateSegment(svc))
+	r.POST("/segments/:slug/rename", allow(apikeys.Admin), renameSegment(svc))
+	r.POST("/segments/:slug/state", allow(apikeys.Admin), setSegmentState(svc))
+	r.POST("/segments/:slug/restore", allow(apikeys.Admin), restoreSegment(svc))
+	r.PUT("/segments/:slug/schedule", allow(apikeys.Admin), setSegmentSchedule(svc))
+	r.PUT("/segments/:slug/group", allow(apikeys.Admin), setSegmentGroup(svc))
+	r.PUT("/segments/:slug/parent", allow(apikeys.Admin), setSegmentParent(svc))
+	r.PUT("/segments/:slug/rule", allow(apikeys.Admin), setSegmentRule(svc))
+	r.PUT("/segments/:slug/payload", allow(apikeys.Admin), setSegmentPayload(svc))
+	r.DELETE("/segments/:slug/payload", allow(apikeys.Admin), removeSegmentPayload(svc))
+	r.PUT("/segments/:slug/rollout", allow(apikeys.Admin), setSegmentRollout(svc))
+	r.GET("/segments/:slug/rollout", allow(apikeys.Reader), getSegmentRollout(svc))
+	r.DELETE("/segments/:slug/rollout", allow(apikeys.Admin), disableSegmentRollout(svc))
+	r.GET("/schedule", allow(apikeys.Reader), upcomingTransitions(svc))
+	r.DELETE("/segments/:slug", allow(apikeys.Admin), purgeSegment(svc))
 
-	r.GET("/history/:year/:month", getHistory(svc))
-	r.GET("/users/:user_id", getUserSegments(svc))
-	r.POST("/users/:user_id", changeUserSegments(svc))
+	r.POST("/groups", allow(apikeys.Admin), createGroup(svc))
+	r.GET("/groups", allow(apikeys.Reader), listGroups(svc))
+	r.DELETE("/groups/:name", allow(apikeys.Admin), deleteGroup(svc))
+
+	r.POST("/experiments", allow(apikeys.Admin), createExperiment(svc))
+	r.GET("/experiments", allow(apikeys.Reader), listExperiments(svc))
+	r.GET("/experiments/:key", allow(apikeys.Reader), getExperiment(svc))
+
+	r.POST("/attributes", allow(apikeys.Admin), defineAttribute(svc))
+	r.GET("/attributes", allow(apikeys.Reader), listAttributes(svc))
+	r.DELETE("/attributes/:name", allow(apikeys.Admin), deleteAttribute(svc))
+
+	r.GET("/history/:year/:month", allow(apikeys.Reader), getHistory(svc))
+	r.GET("/users/:user_id", allow(apikeys.Reader), getUserSegments(svc))
+	r.POST("/users/:user_id", allow(apikeys.Editor), changeUserSegments(svc))
+	r.GET("/users/:user_id/config", allow(apikeys.Reader), getUserConfig(svc))
+	r.GET("/users/:user_id/flags", allow(apikeys.Reader), getUserFlags(svc))
+	r.PUT("/users/:user_id/flags/:key", allow(apikeys.Editor), setFlagOverride(svc))
+	r.DELETE("/users/:user_id/flags/:key", allow(apikeys.Editor), deleteFlagOverride(svc))
+	r.POST("/users/attributes", allow(apikeys.Editor), upsertUserAttributes(svc))
+	r.GET("/users/:user_id/attributes", allow(apikeys.Reader), getUserAttributes(svc))
+	r.PUT("/users/:user_id/attributes", allow(apikeys.Editor), setUserAttributes(svc))
+	r.GET("/users/:user_id/attributes/history", allow(apikeys.Reader), getUserAttributeHistory(svc))
+}
+
+func SetKeyRoutes(r gin.IRouter, keys auth.Keys) {
+	r.POST("/keys", allow(apikeys.Admin), issueKey(keys))
+	r.GET("/keys", allow(apikeys.Admin), listKeys(keys))
+	r.DELETE("/keys/:id", allow(apikeys.Admin), revokeKey(keys))
+}
+
+func SetFeedRoutes(r gin.IRouter, f *feed.Feed) {
+	r.GET("/changes/stream", allow(apikeys.Reader), streamChanges(f))
+}
+
+func SetWebhookRoutes(r gin.IRouter, w webhooks.Webhooks) {
+	r.POST("/webhooks", allow(apikeys.Admin), createWebhook(w))
+	r.GET("/webhooks", allow(apikeys.Admin), listWebhooks(w))
+	r.DELETE("/webhooks/:id", allow(apikeys.Admin), deleteWebhook(w))
+	r.GET("/webhooks/:id/deliveries", allow(apikeys.Admin), getDeliveries(w))
+	r.POST("/webhooks/:id/deliveries/:delivery_id/retry", allow(apikeys.Admin), retryDelivery(w))
 }
@@ -5,11 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"log/slog"
 	"net/http"
 	"time"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/feed"
+	"user-segmentation/internal/health"
 	"user-segmentation/internal/logger"
+	"user-segmentation/internal/ratelimit"
 	"user-segmentation/internal/service"
+	"user-segmentation/internal/tracing"
+	"user-segmentation/internal/webhooks"
 )
 
 type Server struct {
@@ -17,15 +25,101 @@ type Server struct {
 	log *slog.Logger
 }
 
-func New(log *slog.Logger, addr string, mode string, svc service.Service) *Server {
+type options struct {
+	auth           auth.Authenticator
+	keys           *auth.Keys
+	readLimit      *ratelimit.Limiter
+	writeLimit     *ratelimit.Limiter
+	trustedProxies []string
+	health         *health.Health
+	feed           *feed.Feed
+	webhooks       *webhooks.Webhooks
+}
+
+type Option func(o *options)
+
+// WithAuth enables authentication of API callers. Without it every route is available to anyone
+func WithAuth(a auth.Authenticator) Option {
+	return func(o *options) {
+		o.auth = a
+	}
+}
+
+// WithKeys enables API keys management routes
+func WithKeys(keys auth.Keys) Option {
+	return func(o *options) {
+		o.keys = &keys
+	}
+}
+
+// WithRateLimit limits rate of API requests of every client. Nil limiter disables the limit
+func WithRateLimit(read *ratelimit.Limiter, write *ratelimit.Limiter) Option {
+	return func(o *options) {
+		o.readLimit = read
+		o.writeLimit = write
+	}
+}
+
+// WithTrustedProxies allows to take client IP from headers set by the proxies. By default, no proxy is trusted
+func WithTrustedProxies(proxies []string) Option {
+	return func(o *options) {
+		o.trustedProxies = proxies
+	}
+}
+
+// WithHealth enables /healthz and /readyz probes
+func WithHealth(h *health.Health) Option {
+	return func(o *options) {
+		o.health = h
+	}
+}
+
+// WithFeed enables the stream of committed operations
+func WithFeed(f *feed.Feed) Option {
+	return func(o *options) {
+		o.feed = f
+	}
+}
+
+// WithWebhooks enables webhooks management routes
+func WithWebhooks(w webhooks.Webhooks) Option {
+	return func(o *options) {
+		o.webhooks = &w
+	}
+}
+
+func New(log *slog.Logger, addr string, mode string, svc service.Service, opts ...Option) *Server {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	gin.SetMode(mode)
 
 	r := gin.New()
+	if err := r.SetTrustedProxies(o.trustedProxies); err != nil {
+		log.Error("invalid trusted proxies", slog.String("error", err.Error()))
+	}
+	// handlers pass gin.Context as context.Context, so it must see values of the request context, e.g. spans
+	r.ContextWithFallback = true
 	r.Use(gin.Recovery())
+	if o.health != nil {
+		setHealthRoutes(r, o.health)
+	}
+	r.Use(otelgin.Middleware(tracing.ServiceName))
 	logMW := logger.Middleware(log)
 	r.Use(func(c *gin.Context) {
-		logMW(c.Request, c.Set, c.Next)
+		logMW(c.Request, c.Writer.Header(), func(ctx context.Context) {
+			c.Request = c.Request.WithContext(ctx)
+		}, func() context.Context {
+			c.Next()
+			return c
+		})
 	})
+	r.Use(observe)
+	if o.auth != nil {
+		log.Info("authentication enabled")
+		r.Use(authenticate(o.auth))
+	}
 	s := Server{
 		Server: http.Server{
 			Addr:    addr,
@@ -33,7 +127,21 @@ func New(log *slog.Logger, addr string, mode string, svc service.Service) *Serve
 		},
 		log: log,
 	}
-	SetRoutes(r.Group("/api"), svc)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	api := r.Group("/api")
+	if o.readLimit != nil || o.writeLimit != nil {
+		api.Use(limit(o.readLimit, o.writeLimit))
+	}
+	SetRoutes(api, svc)
+	if o.keys != nil {
+		SetKeyRoutes(api, *o.keys)
+	}
+	if o.feed != nil {
+		SetFeedRoutes(api, o.feed)
+	}
+	if o.webhooks != nil {
+		SetWebhookRoutes(api, *o.webhooks)
+	}
 	return &s
 }
 
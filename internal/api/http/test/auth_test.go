@@ -0,0 +1,89 @@
+package test
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/auth"
+	authmocks "user-segmentation/internal/auth/mocks"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+const (
+	adminKey  = "admin-key"
+	readerKey = "reader-key"
+)
+
+func authServer(t *testing.T, svc service.Service) http.Handler {
+	keysRepo := authmocks.NewKeysRepo(t)
+	keysRepo.
+		On("GetByHash", mock.Anything, apikeys.Hash(readerKey)).
+		Return(apikeys.APIKey{Name: "reader", Role: apikeys.Reader}, nil).
+		Maybe()
+	keysRepo.
+		On("GetByHash", mock.Anything, mock.Anything).
+		Return(apikeys.APIKey{}, repo.ErrKeyNotFound).
+		Maybe()
+	keysRepo.
+		On("Store", mock.Anything, mock.AnythingOfType("apikeys.APIKey")).
+		Return(int64(1), nil).
+		Maybe()
+	keys := auth.NewKeys(keysRepo, adminKey)
+	srv := httpserver.New(
+		slog.Default(), ":8888", gin.ReleaseMode, svc,
+		httpserver.WithAuth(auth.Chain{keys}), httpserver.WithKeys(keys),
+	)
+	return srv.Handler
+}
+
+func do(h http.Handler, method string, path string, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(auth.KeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuth_Roles(t *testing.T) {
+	segRepo := mocks.NewSegmentsRepo(t)
+	segRepo.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	segRepo.
+		On("GetUserSegments", mock.Anything, int64(1)).
+		Return([]segments.Segment{{Slug: "slug"}}, nil)
+	h := authServer(t, service.New(segRepo, nil))
+
+	require.Equal(t, http.StatusOK, do(h, http.MethodGet, "/api/ping", "", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do(h, http.MethodGet, "/api/users/1", "", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do(h, http.MethodGet, "/api/users/1", "wrong-key", "").Code)
+	require.Equal(t, http.StatusOK, do(h, http.MethodGet, "/api/users/1", readerKey, "").Code)
+	require.Equal(t, http.StatusForbidden, do(h, http.MethodPost, "/api/users/1", readerKey, `{"add":["slug"]}`).Code)
+	require.Equal(t, http.StatusForbidden, do(h, http.MethodDelete, "/api/segments", readerKey, `{"slug":"slug"}`).Code)
+	require.Equal(t, http.StatusForbidden, do(h, http.MethodGet, "/api/keys", readerKey, "").Code)
+}
+
+func TestAuth_IssueKey(t *testing.T) {
+	h := authServer(t, service.Service{})
+
+	rec := do(h, http.MethodPost, "/api/keys", adminKey, `{"name":"batch","role":"superuser"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(h, http.MethodPost, "/api/keys", adminKey, `{"name":"batch","role":"editor"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"key":"sk_`)
+	require.Contains(t, rec.Body.String(), `"role":"editor"`)
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/feed"
+	"user-segmentation/internal/feed/mocks"
+	"user-segmentation/internal/service"
+)
+
+func TestFeed_StreamChanges(t *testing.T) {
+	r := mocks.NewOperationsRepo(t)
+	r.
+		On("After", mock.Anything, int64(41), operations.Filter{UserID: 1, Segment: "slug"}, mock.Anything).
+		Return([]operations.Operation{
+			{ID: 42, UserID: 1, Segment: segments.Segment{Slug: "slug"}, Type: operations.Remove},
+		}, nil)
+	h := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, service.Service{}, httpserver.WithFeed(feed.New(r))).Handler
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/changes/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/changes/stream?user_id=1&segment=slug", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "41")
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var event []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "" {
+		event = append(event, scanner.Text())
+	}
+	require.Len(t, event, 3)
+	require.Equal(t, "id:42", strings.ReplaceAll(event[0], " ", ""))
+	require.Equal(t, "event:remove", strings.ReplaceAll(event[1], " ", ""))
+	require.Contains(t, event[2], `"operation":"remove"`)
+	require.Contains(t, event[2], `"user_id":1`)
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/entities/flags"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestFlags_ETag(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	r.
+		On("GetUserSegments", mock.Anything, int64(42)).
+		Return([]segments.Segment{{Slug: "beta"}}, nil)
+	r.
+		On("Ancestors", mock.Anything, []string{"beta"}).
+		Return([]segments.Segment{}, nil)
+	f := mocks.NewFlagsRepo(t)
+	f.
+		On("Overrides", mock.Anything, int64(42), []string{"beta", "other"}).
+		Return(map[string]any{}, nil)
+	f.
+		On("Known", mock.Anything, []string{"beta", "other"}).
+		Return(map[string]flags.Source{"beta": flags.SourceSegment}, nil)
+	svc := service.New(r, mocks.NewHistoryRepo(t), service.WithFlags(f))
+	h := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, svc).Handler
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/42/flags?keys=beta,other,beta", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := get("")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"data": {"user_id": 42, "flags": {
+		"beta": {"value": true, "source": "segment"},
+		"other": {"value": false, "source": "default"}
+	}}, "error": null}`, rec.Body.String())
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"))
+
+	for _, header := range []string{etag, "W/" + etag, `"stale", ` + etag, "*"} {
+		rec = get(header)
+		require.Equal(t, http.StatusNotModified, rec.Code, header)
+		require.Empty(t, rec.Body.String())
+		require.Equal(t, etag, rec.Header().Get("ETag"))
+	}
+	require.Equal(t, http.StatusOK, get(`"stale"`).Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/42/flags?keys=beta,,other", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
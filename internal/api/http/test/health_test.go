@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/health"
+	"user-segmentation/internal/service"
+)
+
+func TestHealth_Probes(t *testing.T) {
+	hc := health.New()
+	var dbErr error
+	hc.Add("postgres", func(ctx context.Context) error { return dbErr })
+	h := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, service.Service{}, httpserver.WithHealth(hc)).Handler
+	probe := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, probe("/healthz").Code)
+	require.Equal(t, http.StatusOK, probe("/readyz").Code)
+
+	dbErr = errors.New("connection refused")
+	rec := probe("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), `"postgres":"connection refused"`)
+	require.Equal(t, http.StatusOK, probe("/healthz").Code)
+
+	dbErr = nil
+	hc.Drain()
+	require.Equal(t, http.StatusServiceUnavailable, probe("/readyz").Code)
+	require.Equal(t, http.StatusOK, probe("/healthz").Code)
+}
@@ -0,0 +1,31 @@
+package test
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestHistory_Error(t *testing.T) {
+	hr := mocks.NewHistoryRepo(t)
+	hr.
+		On("Get", mock.Anything, 2024, 3).
+		Return(nil, errors.New("connection refused")).
+		Once()
+	h := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, service.New(nil, hr)).Handler
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/history/2024/3", nil))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+	require.Empty(t, rec.Header().Get("Content-Disposition"))
+	require.NotContains(t, rec.Body.String(), "User ID")
+}
@@ -0,0 +1,27 @@
+package test
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/service"
+)
+
+func TestMetrics(t *testing.T) {
+	h := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, service.Service{}).Handler
+
+	require.Equal(t, http.StatusOK, do(h, http.MethodGet, "/api/ping", "", "").Code)
+	require.Equal(t, http.StatusBadRequest, do(h, http.MethodGet, "/api/users/abc", "", "").Code)
+	require.Equal(t, http.StatusNotFound, do(h, http.MethodGet, "/api/nothing", "", "").Code)
+
+	rec := do(h, http.MethodGet, "/metrics", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	require.Contains(t, body, `http_requests_total{method="GET",route="/api/ping",status="200"}`)
+	require.Contains(t, body, `http_requests_total{method="GET",route="/api/users/:user_id",status="400"}`)
+	require.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="404"}`)
+	require.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/api/ping",status="200"`)
+}
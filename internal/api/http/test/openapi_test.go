@@ -0,0 +1,87 @@
+package test
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/feed"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/webhooks"
+)
+
+var pathParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+type openAPI struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadSpec(t *testing.T, handler http.Handler) openAPI {
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL + "/api/openapi.json")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var spec openAPI
+	require.NoError(t, json.Unmarshal(body, &spec))
+	return spec
+}
+
+func TestOpenAPI_CoversRoutes(t *testing.T) {
+	srv := httpserver.New(
+		slog.Default(), ":8888", gin.ReleaseMode, service.Service{},
+		httpserver.WithKeys(auth.NewKeys(nil, "")),
+		httpserver.WithFeed(feed.New(nil)),
+		httpserver.WithWebhooks(webhooks.New(nil)),
+	)
+	engine, ok := srv.Handler.(*gin.Engine)
+	require.True(t, ok)
+	spec := loadSpec(t, srv.Handler)
+	require.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+	for _, route := range engine.Routes() {
+		if route.Method == http.MethodConnect {
+			// OpenAPI has no way to describe CONNECT operations
+			continue
+		}
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		path := pathParam.ReplaceAllString(strings.TrimPrefix(route.Path, "/api"), "{$1}")
+		ops, ok := spec.Paths[path]
+		require.Truef(t, ok, "path %s is missing from the spec", path)
+		_, ok = ops[strings.ToLower(route.Method)]
+		require.Truef(t, ok, "%s %s is missing from the spec", route.Method, path)
+	}
+}
+
+func TestOpenAPI_Docs(t *testing.T) {
+	srv := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, service.Service{})
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "openapi.json")
+	require.NotContains(t, rec.Body.String(), "https://", "swagger-ui is served by the service")
+
+	for _, file := range []string{"swagger-ui.css", "swagger-ui-bundle.js"} {
+		rec = httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/docs/"+file, nil))
+		require.Equal(t, http.StatusOK, rec.Code, file)
+		require.NotEmpty(t, rec.Body.Bytes(), file)
+	}
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/docs/missing.js", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/ratelimit"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestRateLimit(t *testing.T) {
+	segRepo := mocks.NewSegmentsRepo(t)
+	segRepo.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	segRepo.
+		On("GetUserSegments", mock.Anything, mock.AnythingOfType("int64")).
+		Return([]segments.Segment{}, nil)
+	srv := httpserver.New(
+		slog.Default(), ":8888", gin.ReleaseMode, service.New(segRepo, nil),
+		httpserver.WithRateLimit(ratelimit.New("read", 1, 2), ratelimit.New("write", 0.01, 1)),
+	)
+	h := srv.Handler
+
+	require.Equal(t, http.StatusOK, do(h, http.MethodGet, "/api/users/1", "", "").Code)
+	require.Equal(t, http.StatusOK, do(h, http.MethodGet, "/api/users/1", "", "").Code)
+	rec := do(h, http.MethodGet, "/api/users/1", "", "")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	retry, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.Equal(t, 1, retry)
+
+	// write budget is separate from the read one
+	rec = do(h, http.MethodPost, "/api/segments", "", `{"slug":""}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = do(h, http.MethodPost, "/api/segments", "", `{"slug":""}`)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	retry, err = strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.Greater(t, retry, 90)
+
+	rec = do(h, http.MethodGet, "/metrics", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `ratelimit_requests_total{budget="read",result="limited"}`)
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/service"
+)
+
+func TestRequestID(t *testing.T) {
+	h := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, service.New(nil, nil)).Handler
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "generated", header: ""},
+		{name: "propagated", header: "req-42", want: "req-42"},
+		{name: "invalid", header: "bad\x01id"},
+		{name: "too long", header: strings.Repeat("a", 129)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/users/abc", nil)
+			if tt.header != "" {
+				req.Header.Set(logger.RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+
+			id := rec.Header().Get(logger.RequestIDHeader)
+			require.NotEmpty(t, id)
+			if tt.want != "" {
+				require.Equal(t, tt.want, id)
+			} else {
+				require.NotEqual(t, tt.header, id)
+			}
+			var body struct {
+				RequestID string `json:"request_id"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			require.Equal(t, id, body.RequestID)
+		})
+	}
+}
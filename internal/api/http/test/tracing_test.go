@@ -0,0 +1,57 @@
+package test
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestTracing_Propagation(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	segRepo := mocks.NewSegmentsRepo(t)
+	segRepo.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	segRepo.
+		On("GetUserSegments", mock.Anything, int64(1)).
+		Return([]segments.Segment{}, nil)
+	h := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, service.New(segRepo, nil)).Handler
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	spans := recorder.Ended()
+	names := make(map[string]sdktrace.ReadOnlySpan, len(spans))
+	for _, s := range spans {
+		require.Equal(t, traceID, s.SpanContext().TraceID().String())
+		names[s.Name()] = s
+	}
+	require.Contains(t, names, "/api/users/:user_id")
+	require.Contains(t, names, "Service.GetUserSegments")
+	require.Equal(t,
+		names["/api/users/:user_id"].SpanContext().SpanID(),
+		names["Service.GetUserSegments"].Parent().SpanID(),
+	)
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"user-segmentation/internal/entities/apikeys"
+)
+
+var (
+	// ErrNoCredentials means that the authenticator did not find its credentials, so the next one may try
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUnauthenticated    = errors.New("unauthenticated")
+	ErrForbidden          = errors.New("forbidden")
+)
+
+// Header gives access to request metadata, e.g. http.Header
+type Header interface {
+	Get(key string) string
+}
+
+type Identity struct {
+	Name string
+	Role apikeys.Role
+}
+
+// Anonymous is the identity of callers without credentials
+var Anonymous = Identity{Name: "anonymous", Role: apikeys.None}
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, h Header) (Identity, error)
+}
+
+// Chain tries authenticators one by one until someone finds its credentials
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, h Header) (Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(ctx, h)
+		if !errors.Is(err, ErrNoCredentials) {
+			return id, err
+		}
+	}
+	return Anonymous, nil
+}
+
+// Allowed reports whether the identity may perform actions requiring the role
+func (id Identity) Allowed(role apikeys.Role) error {
+	if id.Role >= role {
+		return nil
+	}
+	if id == Anonymous {
+		return ErrUnauthenticated
+	}
+	return ErrForbidden
+}
+
+type ctxKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
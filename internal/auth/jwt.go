@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+	"time"
+	"user-segmentation/internal/entities/apikeys"
+)
+
+const (
+	PermissionRead  = "segments:read"
+	PermissionWrite = "segments:write"
+	PermissionAdmin = "segments:admin"
+)
+
+var permissionRoles = map[string]apikeys.Role{
+	PermissionRead:  apikeys.Reader,
+	PermissionWrite: apikeys.Editor,
+	PermissionAdmin: apikeys.Admin,
+}
+
+// JWT authenticates callers by bearer tokens issued by an OIDC provider
+type JWT struct {
+	Keys     *JWKS
+	Issuer   string
+	Audience string
+	// Claim contains permissions of the caller, either as space-separated string (like "scope") or array
+	Claim string
+	// Leeway allows small clock skew between the provider and the service
+	Leeway time.Duration
+}
+
+func (j JWT) Authenticate(ctx context.Context, h Header) (Identity, error) {
+	raw, ok := strings.CutPrefix(h.Get("Authorization"), "Bearer ")
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(j.Leeway),
+	}
+	if j.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.Issuer))
+	}
+	if j.Audience != "" {
+		opts = append(opts, jwt.WithAudience(j.Audience))
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return j.Keys.Key(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return Identity{}, errors.Join(ErrInvalidCredentials, err)
+	}
+	sub, _ := claims.GetSubject()
+	return Identity{Name: "jwt:" + sub, Role: j.role(claims)}, nil
+}
+
+// role returns the highest role granted by permissions from the claim
+func (j JWT) role(claims jwt.MapClaims) apikeys.Role {
+	var perms []string
+	switch v := claims[j.Claim].(type) {
+	case string:
+		perms = strings.Fields(v)
+	case []any:
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				perms = append(perms, s)
+			}
+		}
+	}
+	role := apikeys.None
+	for _, p := range perms {
+		if r, ok := permissionRoles[p]; ok && r > role {
+			role = r
+		}
+	}
+	return role
+}
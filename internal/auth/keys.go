@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/repo"
+)
+
+const KeyHeader = "X-API-Key"
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=KeysRepo
+type KeysRepo interface {
+	Store(ctx context.Context, key apikeys.APIKey) (int64, error)
+	List(ctx context.Context) ([]apikeys.APIKey, error)
+	Revoke(ctx context.Context, id int64) error
+	GetByHash(ctx context.Context, hash []byte) (apikeys.APIKey, error)
+}
+
+// Keys authenticates callers by API keys and manages them
+type Keys struct {
+	Repo KeysRepo
+	// bootstrap is the hash of the admin key from config, which allows to issue the first keys
+	bootstrap []byte
+}
+
+func (k Keys) Authenticate(ctx context.Context, h Header) (Identity, error) {
+	secret := h.Get(KeyHeader)
+	if secret == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	hash := apikeys.Hash(secret)
+	if k.bootstrap != nil && subtle.ConstantTimeCompare(hash, k.bootstrap) == 1 {
+		return Identity{Name: "bootstrap-admin", Role: apikeys.Admin}, nil
+	}
+	key, err := k.Repo.GetByHash(ctx, hash)
+	if errors.Is(err, repo.ErrKeyNotFound) {
+		return Identity{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Name: "key:" + key.Name, Role: key.Role}, nil
+}
+
+// Issue creates a key and returns it with the secret, which cannot be recovered later
+func (k Keys) Issue(ctx context.Context, name string, role string) (apikeys.APIKey, string, error) {
+	r, err := apikeys.ParseRole(role)
+	if err != nil {
+		return apikeys.APIKey{}, "", err
+	}
+	key, secret, err := apikeys.New(name, r)
+	if err != nil {
+		return apikeys.APIKey{}, "", err
+	}
+	key.ID, err = k.Repo.Store(ctx, key)
+	if err != nil {
+		return apikeys.APIKey{}, "", err
+	}
+	return key, secret, nil
+}
+
+func (k Keys) List(ctx context.Context) ([]apikeys.APIKey, error) {
+	return k.Repo.List(ctx)
+}
+
+func (k Keys) Revoke(ctx context.Context, id int64) error {
+	return k.Repo.Revoke(ctx, id)
+}
+
+// NewKeys creates keys authenticator. If adminKey is not empty, it is accepted as a key with admin role
+func NewKeys(r KeysRepo, adminKey string) Keys {
+	k := Keys{Repo: r}
+	if adminKey != "" {
+		k.bootstrap = apikeys.Hash(adminKey)
+	}
+	return k
+}
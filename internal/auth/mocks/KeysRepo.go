@@ -0,0 +1,118 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	apikeys "user-segmentation/internal/entities/apikeys"
+
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// KeysRepo is an autogenerated mock type for the KeysRepo type
+type KeysRepo struct {
+	mock.Mock
+}
+
+// GetByHash provides a mock function with given fields: ctx, hash
+func (_m *KeysRepo) GetByHash(ctx context.Context, hash []byte) (apikeys.APIKey, error) {
+	ret := _m.Called(ctx, hash)
+
+	var r0 apikeys.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) (apikeys.APIKey, error)); ok {
+		return rf(ctx, hash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) apikeys.APIKey); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		r0 = ret.Get(0).(apikeys.APIKey)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx
+func (_m *KeysRepo) List(ctx context.Context) ([]apikeys.APIKey, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []apikeys.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]apikeys.APIKey, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []apikeys.APIKey); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]apikeys.APIKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Revoke provides a mock function with given fields: ctx, id
+func (_m *KeysRepo) Revoke(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Store provides a mock function with given fields: ctx, key
+func (_m *KeysRepo) Store(ctx context.Context, key apikeys.APIKey) (int64, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, apikeys.APIKey) (int64, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, apikeys.APIKey) int64); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, apikeys.APIKey) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewKeysRepo creates a new instance of KeysRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewKeysRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *KeysRepo {
+	mock := &KeysRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
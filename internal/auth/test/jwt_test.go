@@ -0,0 +1,216 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"user-segmentation/internal/auth"
+	"user-segmentation/internal/entities/apikeys"
+)
+
+const (
+	issuer   = "https://sso.example.com"
+	audience = "segmentation"
+)
+
+type header map[string]string
+
+func (h header) Get(key string) string {
+	return h[key]
+}
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+type keys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	jwks []byte
+}
+
+func generateKeys(t *testing.T) keys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			{
+				"kid": "rsa",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   b64(rsaKey.N),
+				"e":   b64(big.NewInt(int64(rsaKey.E))),
+			},
+			{
+				"kid": "ec",
+				"kty": "EC",
+				"crv": "P-256",
+				"x":   b64(ecKey.X),
+				"y":   b64(ecKey.Y),
+			},
+		},
+	})
+	require.NoError(t, err)
+	return keys{rsa: rsaKey, ec: ecKey, jwks: jwks}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) header {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return header{"Authorization": "Bearer " + signed}
+}
+
+func claims(scope any, exp time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   issuer,
+		"aud":   audience,
+		"sub":   "user@example.com",
+		"exp":   exp.Unix(),
+		"scope": scope,
+	}
+}
+
+func authenticator(t *testing.T, source string) auth.JWT {
+	jwks, err := auth.LoadJWKS(context.Background(), source)
+	require.NoError(t, err)
+	return auth.JWT{Keys: jwks, Issuer: issuer, Audience: audience, Claim: "scope"}
+}
+
+func TestJWT_Authenticate(t *testing.T) {
+	k := generateKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, k.jwks, 0o600))
+	a := authenticator(t, path)
+	hour := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		header   header
+		wantRole apikeys.Role
+		wantErr  error
+	}{
+		{
+			name:    "no token",
+			header:  header{},
+			wantErr: auth.ErrNoCredentials,
+		},
+		{
+			name:     "rsa reader",
+			header:   sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, claims("openid segments:read", hour)),
+			wantRole: apikeys.Reader,
+		},
+		{
+			name:     "ec admin",
+			header:   sign(t, jwt.SigningMethodES256, "ec", k.ec, claims([]string{"segments:read", "segments:admin"}, hour)),
+			wantRole: apikeys.Admin,
+		},
+		{
+			name:     "no permissions",
+			header:   sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, claims("openid", hour)),
+			wantRole: apikeys.None,
+		},
+		{
+			name:    "expired",
+			header:  sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, claims("segments:write", time.Now().Add(-time.Hour))),
+			wantErr: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "wrong audience",
+			header: sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, jwt.MapClaims{
+				"iss": issuer, "aud": "other", "exp": hour.Unix(), "scope": "segments:write",
+			}),
+			wantErr: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "wrong issuer",
+			header: sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, jwt.MapClaims{
+				"iss": "https://evil.example.com", "aud": audience, "exp": hour.Unix(), "scope": "segments:write",
+			}),
+			wantErr: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "no expiry",
+			header: sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, jwt.MapClaims{
+				"iss": issuer, "aud": audience, "scope": "segments:write",
+			}),
+			wantErr: auth.ErrInvalidCredentials,
+		},
+		{
+			name:    "unknown key",
+			header:  sign(t, jwt.SigningMethodRS256, "other", k.rsa, claims("segments:write", hour)),
+			wantErr: auth.ErrInvalidCredentials,
+		},
+		{
+			name:    "symmetric algorithm",
+			header:  sign(t, jwt.SigningMethodHS256, "rsa", []byte("secret"), claims("segments:admin", hour)),
+			wantErr: auth.ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := a.Authenticate(context.Background(), tt.header)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRole, id.Role)
+			assert.Equal(t, "jwt:user@example.com", id.Name)
+		})
+	}
+}
+
+func TestJWT_RemoteJWKS(t *testing.T) {
+	k := generateKeys(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(k.jwks)
+	}))
+	defer srv.Close()
+	a := authenticator(t, srv.URL)
+
+	h := sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, claims("segments:write", time.Now().Add(time.Hour)))
+	id, err := a.Authenticate(context.Background(), h)
+	require.NoError(t, err)
+	require.Equal(t, apikeys.Editor, id.Role)
+}
+
+func TestChain_Authenticate(t *testing.T) {
+	k := generateKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, k.jwks, 0o600))
+	chain := auth.Chain{auth.NewKeys(nil, "admin-key"), authenticator(t, path)}
+
+	id, err := chain.Authenticate(context.Background(), header{auth.KeyHeader: "admin-key"})
+	require.NoError(t, err)
+	require.Equal(t, apikeys.Admin, id.Role)
+
+	h := sign(t, jwt.SigningMethodRS256, "rsa", k.rsa, claims("segments:read", time.Now().Add(time.Hour)))
+	id, err = chain.Authenticate(context.Background(), h)
+	require.NoError(t, err)
+	require.Equal(t, apikeys.Reader, id.Role)
+	require.ErrorIs(t, id.Allowed(apikeys.Editor), auth.ErrForbidden)
+
+	id, err = chain.Authenticate(context.Background(), header{})
+	require.NoError(t, err)
+	require.Equal(t, auth.Anonymous, id)
+	require.ErrorIs(t, id.Allowed(apikeys.Reader), auth.ErrUnauthenticated)
+	require.NoError(t, id.Allowed(apikeys.None))
+}
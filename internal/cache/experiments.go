@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/service"
+)
+
+const experimentsCache = "experiments"
+
+// Experiments caches the list of experiments checked on every read of user segments. Experiments created
+// by this replica are listed at once, the ones of other replicas and changes of variant segments after ttl
+type Experiments struct {
+	service.ExperimentsRepo
+	list *LRU[struct{}, []experiments.Experiment]
+	// generation grows on every invalidation, so lists read from the database before it are not cached
+	mu         sync.Mutex
+	generation uint64
+}
+
+func (e *Experiments) List(ctx context.Context) ([]experiments.Experiment, error) {
+	if res, ok := e.list.Get(struct{}{}); ok {
+		metrics.CountCache(experimentsCache, true)
+		return slices.Clone(res), nil
+	}
+	metrics.CountCache(experimentsCache, false)
+	e.mu.Lock()
+	gen := e.generation
+	e.mu.Unlock()
+	res, err := e.ExperimentsRepo.List(ctx)
+	if err != nil {
+		return res, err
+	}
+	e.mu.Lock()
+	if gen == e.generation {
+		e.list.Add(struct{}{}, slices.Clone(res))
+	}
+	e.mu.Unlock()
+	return res, nil
+}
+
+func (e *Experiments) Store(ctx context.Context, exp experiments.Experiment) error {
+	err := e.ExperimentsRepo.Store(ctx, exp)
+	if err == nil {
+		repo.AfterCommit(ctx, e.Purge)
+	}
+	return err
+}
+
+// Purge drops the cached list
+func (e *Experiments) Purge() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.generation++
+	e.list.Purge()
+}
+
+// NewExperiments caches the list of experiments for ttl
+func NewExperiments(repo service.ExperimentsRepo, ttl time.Duration) *Experiments {
+	return &Experiments{
+		ExperimentsRepo: repo,
+		list:            NewLRU[struct{}, []experiments.Experiment](1, ttl),
+	}
+}
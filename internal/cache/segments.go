@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/service"
+)
+
+const (
+	userSegmentsCache   = "user_segments"
+	dynamicSegmentCache = "dynamic_segments"
+
+	// invalidateAll is the message purging the whole cache, other messages are user IDs
+	invalidateAll = "*"
+)
+
+// Bus broadcasts invalidations to other replicas
+type Bus interface {
+	Publish(ctx context.Context, payload string) error
+}
+
+// Segments is a read-through cache of user segments and active segments with rollouts and rules. It wraps
+// the repository and invalidates users on changes. Invalidations are broadcast by the bus, and messages
+// of other replicas are applied by Invalidate
+type Segments struct {
+	service.SegmentsRepo
+	users *LRU[int64, []segments.Segment]
+	// dynamic is dropped with all users, since every change of segments invalidates all users
+	dynamic *LRU[struct{}, []service.DynamicSegment]
+	bus     Bus
+	// generation grows on every invalidation, so results read from the database before it are not cached
+	mu         sync.Mutex
+	generation uint64
+}
+
+func (s *Segments) GetUserSegments(ctx context.Context, userID int64) ([]segments.Segment, error) {
+	if res, ok := s.users.Get(userID); ok {
+		metrics.CountCache(userSegmentsCache, true)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", true))
+		return slices.Clone(res), nil
+	}
+	metrics.CountCache(userSegmentsCache, false)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", false))
+	gen := s.currentGeneration()
+	res, err := s.SegmentsRepo.GetUserSegments(ctx, userID)
+	if err != nil {
+		return res, err
+	}
+	s.mu.Lock()
+	if gen == s.generation {
+		s.users.Add(userID, slices.Clone(res))
+	}
+	s.mu.Unlock()
+	return res, nil
+}
+
+func (s *Segments) ActiveDynamic(ctx context.Context) ([]service.DynamicSegment, error) {
+	if res, ok := s.dynamic.Get(struct{}{}); ok {
+		metrics.CountCache(dynamicSegmentCache, true)
+		return slices.Clone(res), nil
+	}
+	metrics.CountCache(dynamicSegmentCache, false)
+	gen := s.currentGeneration()
+	res, err := s.SegmentsRepo.ActiveDynamic(ctx)
+	if err != nil {
+		return res, err
+	}
+	s.mu.Lock()
+	if gen == s.generation {
+		s.dynamic.Add(struct{}{}, slices.Clone(res))
+	}
+	s.mu.Unlock()
+	return res, nil
+}
+
+func (s *Segments) Store(ctx context.Context, seg segments.Segment) error {
+	err := s.SegmentsRepo.Store(ctx, seg)
+	if err == nil && seg.Rule != "" {
+		// users are matched by the rule on reads
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return err
+}
+
+func (s *Segments) ChangeUserSegments(ctx context.Context, userID int64, add []segments.Segment, remove []segments.Segment) service.ChangeErrors {
+	// a part of changes may be applied even if some failed. Reads must not refill the cache
+	// with data of the uncommitted transaction, so invalidation waits for the commit
+	defer repo.AfterCommit(ctx, func() { s.invalidate(ctx, strconv.FormatInt(userID, 10)) })
+	return s.SegmentsRepo.ChangeUserSegments(ctx, userID, add, remove)
+}
+
+func (s *Segments) Delete(ctx context.Context, seg segments.Segment) error {
+	err := s.SegmentsRepo.Delete(ctx, seg)
+	if err == nil {
+		// members of the segment are unknown, so every user may be stale
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return err
+}
+
+func (s *Segments) Rename(ctx context.Context, from string, to string, expiresAt time.Time) (segments.Segment, error) {
+	seg, err := s.SegmentsRepo.Rename(ctx, from, to, expiresAt)
+	if err == nil {
+		// cached users contain the old slug
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, err
+}
+
+func (s *Segments) SetState(ctx context.Context, slug string, from []segments.State, to segments.State) (segments.Segment, segments.State, error) {
+	seg, prev, err := s.SegmentsRepo.SetState(ctx, slug, from, to)
+	if err == nil {
+		// only active segments are returned to users
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, prev, err
+}
+
+func (s *Segments) Restore(ctx context.Context, slug string) (segments.Segment, error) {
+	seg, err := s.SegmentsRepo.Restore(ctx, slug)
+	if err == nil {
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, err
+}
+
+func (s *Segments) SetSchedule(ctx context.Context, slug string, schedule segments.Schedule) (segments.Segment, error) {
+	seg, err := s.SegmentsRepo.SetSchedule(ctx, slug, schedule)
+	if err == nil {
+		// segments out of their schedules are not returned to users
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, err
+}
+
+func (s *Segments) SwitchSchedules(ctx context.Context, now time.Time) ([]segments.Segment, error) {
+	res, err := s.SegmentsRepo.SwitchSchedules(ctx, now)
+	if err == nil && len(res) != 0 {
+		// cached users were read before the segments entered or left their schedules
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return res, err
+}
+
+func (s *Segments) SetRollout(ctx context.Context, slug string, percent *float64, salt string, ramp []segments.RampStep) (segments.Segment, *float64, error) {
+	seg, prev, err := s.SegmentsRepo.SetRollout(ctx, slug, percent, salt, ramp)
+	if err == nil {
+		// members follow the percent, and readers must not keep membership of the previous one
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, prev, err
+}
+
+func (s *Segments) AdvanceRollouts(ctx context.Context, now time.Time) ([]segments.RolloutChange, error) {
+	res, err := s.SegmentsRepo.AdvanceRollouts(ctx, now)
+	if err == nil && len(res) != 0 {
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return res, err
+}
+
+func (s *Segments) SetRule(ctx context.Context, slug string, rule string) (segments.Segment, error) {
+	seg, err := s.SegmentsRepo.SetRule(ctx, slug, rule)
+	if err == nil {
+		// members follow the rule
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, err
+}
+
+func (s *Segments) SetParent(ctx context.Context, slug string, parent string) (segments.Segment, error) {
+	seg, err := s.SegmentsRepo.SetParent(ctx, slug, parent)
+	if err == nil {
+		// members of the segment imply other ancestors now
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, err
+}
+
+func (s *Segments) SetGroup(ctx context.Context, slug string, group string) (segments.Segment, error) {
+	seg, err := s.SegmentsRepo.SetGroup(ctx, slug, group)
+	if err == nil {
+		// exclusive groups decide which segments users may hold together
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, err
+}
+
+func (s *Segments) SetPayload(ctx context.Context, slug string, payload *segments.Payload) (segments.Segment, error) {
+	seg, err := s.SegmentsRepo.SetPayload(ctx, slug, payload)
+	if err == nil {
+		// configs of users are built from their segments
+		repo.AfterCommit(ctx, func() { s.invalidate(ctx, invalidateAll) })
+	}
+	return seg, err
+}
+
+// Invalidate applies the message of invalidation: user ID or "*" for all users
+func (s *Segments) Invalidate(payload string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	if payload == invalidateAll {
+		s.users.Purge()
+		s.dynamic.Purge()
+		return
+	}
+	userID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		// unknown message, stay safe
+		s.users.Purge()
+		s.dynamic.Purge()
+		return
+	}
+	s.users.Remove(userID)
+}
+
+// Purge drops all cached users, e.g. when invalidations could be missed
+func (s *Segments) Purge() {
+	s.Invalidate(invalidateAll)
+}
+
+func (s *Segments) invalidate(ctx context.Context, payload string) {
+	s.Invalidate(payload)
+	if s.bus == nil {
+		return
+	}
+	if err := s.bus.Publish(ctx, payload); err != nil {
+		logger.InternalErr(ctx, err, "cache.Segments.invalidate")
+	}
+}
+
+func (s *Segments) currentGeneration() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation
+}
+
+// NewSegments caches up to size users for ttl. Nil bus is allowed for a single replica
+func NewSegments(repo service.SegmentsRepo, size int, ttl time.Duration, bus Bus) *Segments {
+	return &Segments{
+		SegmentsRepo: repo,
+		users:        NewLRU[int64, []segments.Segment](size, ttl),
+		dynamic:      NewLRU[struct{}, []service.DynamicSegment](1, ttl),
+		bus:          bus,
+	}
+}
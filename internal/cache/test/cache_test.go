@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"user-segmentation/internal/cache"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+type bus []string
+
+func (b *bus) Publish(_ context.Context, payload string) error {
+	*b = append(*b, payload)
+	return nil
+}
+
+func TestLRU(t *testing.T) {
+	c := cache.NewLRU[int, string](2, time.Hour)
+	c.Add(1, "a")
+	c.Add(2, "b")
+	_, ok := c.Get(1)
+	require.True(t, ok)
+	c.Add(3, "c")
+	_, ok = c.Get(2)
+	require.False(t, ok, "least recently used entry must be evicted")
+	v, ok := c.Get(1)
+	require.True(t, ok)
+	require.Equal(t, "a", v)
+	require.Equal(t, 2, c.Len())
+
+	c = cache.NewLRU[int, string](2, 10*time.Millisecond)
+	c.Add(1, "a")
+	time.Sleep(20 * time.Millisecond)
+	_, ok = c.Get(1)
+	require.False(t, ok, "entry must expire")
+}
+
+func TestSegments_ReadThrough(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("GetUserSegments", mock.Anything, int64(1)).
+		Return([]segments.Segment{{Slug: "slug-1"}}, nil).
+		Twice()
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(1), mock.Anything, mock.Anything).
+		Return(service.ChangeErrors{})
+	b := &bus{}
+	c := cache.NewSegments(r, 10, time.Hour, b)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := c.GetUserSegments(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, []segments.Segment{{Slug: "slug-1"}}, res)
+	}
+	r.AssertNumberOfCalls(t, "GetUserSegments", 1)
+
+	c.ChangeUserSegments(ctx, 1, []segments.Segment{{Slug: "slug-2"}}, nil)
+	require.Equal(t, []string{"1"}, []string(*b))
+	_, err := c.GetUserSegments(ctx, 1)
+	require.NoError(t, err)
+	r.AssertNumberOfCalls(t, "GetUserSegments", 2)
+}
+
+func TestSegments_Invalidate(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("GetUserSegments", mock.Anything, mock.AnythingOfType("int64")).
+		Return([]segments.Segment{{Slug: "slug-1"}}, nil)
+	r.
+		On("Delete", mock.Anything, segments.Segment{Slug: "slug-1"}).
+		Return(nil)
+	b := &bus{}
+	c := cache.NewSegments(r, 10, time.Hour, b)
+	ctx := context.Background()
+	get := func(userID int64) {
+		_, err := c.GetUserSegments(ctx, userID)
+		require.NoError(t, err)
+	}
+
+	get(1)
+	get(2)
+	c.Invalidate("1")
+	get(1)
+	get(2)
+	r.AssertNumberOfCalls(t, "GetUserSegments", 3)
+
+	require.NoError(t, c.Delete(ctx, segments.Segment{Slug: "slug-1"}))
+	require.Equal(t, []string{"*"}, []string(*b))
+	get(1)
+	get(2)
+	r.AssertNumberOfCalls(t, "GetUserSegments", 5)
+}
+
+func TestSegments_ActiveDynamic(t *testing.T) {
+	dynamic := []service.DynamicSegment{{Segment: segments.Segment{Slug: "beta", Rollout: &segments.Rollout{Percent: 10}}}}
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return(dynamic, nil).
+		Twice()
+	c := cache.NewSegments(r, 10, time.Hour, nil)
+	ctx := context.Background()
+	get := func() {
+		t.Helper()
+		res, err := c.ActiveDynamic(ctx)
+		require.NoError(t, err)
+		require.Equal(t, dynamic, res)
+	}
+
+	get()
+	c.Invalidate("1")
+	get()
+	r.AssertNumberOfCalls(t, "ActiveDynamic", 1)
+	c.Invalidate("*")
+	get()
+	r.AssertNumberOfCalls(t, "ActiveDynamic", 2)
+}
+
+func TestSegments_InvalidateOnSegmentChanges(t *testing.T) {
+	ctx := context.Background()
+	percent := 10.0
+	for name, change := range map[string]func(c *cache.Segments) error{
+		"SetRollout": func(c *cache.Segments) error {
+			_, _, err := c.SetRollout(ctx, "slug", &percent, "salt", nil)
+			return err
+		},
+		"AdvanceRollouts": func(c *cache.Segments) error {
+			_, err := c.AdvanceRollouts(ctx, time.Time{})
+			return err
+		},
+		"Store": func(c *cache.Segments) error {
+			return c.Store(ctx, segments.Segment{Slug: "slug", Rule: `country == "RU"`})
+		},
+		"SetRule": func(c *cache.Segments) error {
+			_, err := c.SetRule(ctx, "slug", `country == "RU"`)
+			return err
+		},
+		"SetParent": func(c *cache.Segments) error {
+			_, err := c.SetParent(ctx, "slug", "parent")
+			return err
+		},
+		"SetGroup": func(c *cache.Segments) error {
+			_, err := c.SetGroup(ctx, "slug", "layer")
+			return err
+		},
+		"SetPayload": func(c *cache.Segments) error {
+			_, err := c.SetPayload(ctx, "slug", nil)
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := mocks.NewSegmentsRepo(t)
+			r.
+				On("GetUserSegments", mock.Anything, int64(1)).
+				Return([]segments.Segment{{Slug: "slug"}}, nil).
+				Twice()
+			r.On("SetRollout", mock.Anything, "slug", &percent, "salt", []segments.RampStep(nil)).Return(segments.Segment{}, nil, nil).Maybe()
+			r.On("AdvanceRollouts", mock.Anything, time.Time{}).Return([]segments.RolloutChange{{Segment: "slug"}}, nil).Maybe()
+			r.On("Store", mock.Anything, segments.Segment{Slug: "slug", Rule: `country == "RU"`}).Return(nil).Maybe()
+			r.On("SetRule", mock.Anything, "slug", `country == "RU"`).Return(segments.Segment{}, nil).Maybe()
+			r.On("SetParent", mock.Anything, "slug", "parent").Return(segments.Segment{}, nil).Maybe()
+			r.On("SetGroup", mock.Anything, "slug", "layer").Return(segments.Segment{}, nil).Maybe()
+			r.On("SetPayload", mock.Anything, "slug", (*segments.Payload)(nil)).Return(segments.Segment{}, nil).Maybe()
+			b := &bus{}
+			c := cache.NewSegments(r, 10, time.Hour, b)
+			_, err := c.GetUserSegments(ctx, 1)
+			require.NoError(t, err)
+			require.NoError(t, change(c))
+			require.Equal(t, []string{"*"}, []string(*b))
+			_, err = c.GetUserSegments(ctx, 1)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExperiments_List(t *testing.T) {
+	exp := experiments.Experiment{Key: "checkout"}
+	r := mocks.NewExperimentsRepo(t)
+	r.
+		On("List", mock.Anything).
+		Return([]experiments.Experiment{exp}, nil).
+		Twice()
+	r.
+		On("Store", mock.Anything, exp).
+		Return(nil).
+		Once()
+	c := cache.NewExperiments(r, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := c.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []experiments.Experiment{exp}, res)
+	}
+	require.NoError(t, c.Store(ctx, exp))
+	_, err := c.List(ctx)
+	require.NoError(t, err, "stored experiments are listed at once")
+}
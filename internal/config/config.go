@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/ilyakaznacheev/cleanenv"
 	"os"
+	"time"
 )
 
 const (
@@ -13,9 +14,79 @@ const (
 )
 
 type Config struct {
-	Env      string `env:"ENV" env-default:"release"`
-	DbConn   string `env:"DB_CONN" env-required:"true"`
-	HTTPAddr string `env:"HTTP_ADDR" env-default:":8888"`
+	Env         string `env:"ENV" env-default:"release"`
+	DbConn      string `env:"DB_CONN" env-required:"true"`
+	HTTPAddr    string `env:"HTTP_ADDR" env-default:":8888"`
+	GRPCAddr    string `env:"GRPC_ADDR" env-default:":9090"`
+	AuthEnabled bool   `env:"AUTH_ENABLED" env-default:"true"`
+	AdminAPIKey string `env:"ADMIN_API_KEY"`
+	JWT         JWT
+	RateLimit   RateLimit
+	// TrustedProxies are allowed to pass client IP in X-Forwarded-For and X-Real-IP headers
+	TrustedProxies []string `env:"TRUSTED_PROXIES" env-separator:","`
+	// MembersRefresh is the interval of counting segment members for metrics
+	MembersRefresh time.Duration `env:"METRICS_MEMBERS_REFRESH" env-default:"1m"`
+	// AliasTTL is the time the old slug of a renamed segment resolves to the segment
+	AliasTTL time.Duration `env:"SEGMENT_ALIAS_TTL" env-default:"720h"`
+	// ScheduleInterval is the interval of recording segments entering and leaving their schedules
+	ScheduleInterval time.Duration `env:"SCHEDULER_INTERVAL" env-default:"1m"`
+	Tracing          Tracing
+	UserCache        UserCache
+	Webhooks         Webhooks
+	Outbox           Outbox
+	// DrainDelay is the time between failing readiness on shutdown and stopping the servers,
+	// it lets load balancers notice the readiness and stop routing requests
+	DrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" env-default:"5s"`
+}
+
+// Tracing configures OpenTelemetry exporter: none, stdout, file or otlp.
+// OTLP exporter is configured by standard OTEL_EXPORTER_OTLP_* variables
+type Tracing struct {
+	Exporter    string  `env:"TRACING_EXPORTER" env-default:"none"`
+	File        string  `env:"TRACING_FILE" env-default:"traces.json"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+}
+
+// UserCache configures in-process cache of user segments. Zero size disables the cache
+type UserCache struct {
+	Size int           `env:"USER_CACHE_SIZE" env-default:"10000"`
+	TTL  time.Duration `env:"USER_CACHE_TTL" env-default:"1m"`
+}
+
+// Webhooks configures sending of webhook deliveries
+type Webhooks struct {
+	PollInterval time.Duration `env:"WEBHOOKS_POLL_INTERVAL" env-default:"1s"`
+	Timeout      time.Duration `env:"WEBHOOKS_TIMEOUT" env-default:"10s"`
+	BatchSize    int           `env:"WEBHOOKS_BATCH_SIZE" env-default:"100"`
+	Concurrency  int           `env:"WEBHOOKS_CONCURRENCY" env-default:"8"`
+	MaxAttempts  int           `env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"10"`
+	Backoff      time.Duration `env:"WEBHOOKS_BACKOFF" env-default:"10s"`
+}
+
+// Outbox configures publishing of membership events: none, stdout, file or http. None disables the outbox
+type Outbox struct {
+	Publisher string        `env:"OUTBOX_PUBLISHER" env-default:"none"`
+	File      string        `env:"OUTBOX_FILE" env-default:"outbox.jsonl"`
+	URL       string        `env:"OUTBOX_URL"`
+	Timeout   time.Duration `env:"OUTBOX_TIMEOUT" env-default:"10s"`
+	Interval  time.Duration `env:"OUTBOX_INTERVAL" env-default:"1s"`
+	BatchSize int           `env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+}
+
+// RateLimit configures token buckets of every client. Zero rate disables the limit
+type RateLimit struct {
+	ReadRPS    float64 `env:"RATE_LIMIT_READ_RPS" env-default:"50"`
+	ReadBurst  int     `env:"RATE_LIMIT_READ_BURST" env-default:"100"`
+	WriteRPS   float64 `env:"RATE_LIMIT_WRITE_RPS" env-default:"10"`
+	WriteBurst int     `env:"RATE_LIMIT_WRITE_BURST" env-default:"20"`
+}
+
+// JWT configures authentication by tokens of OIDC provider. It is enabled when JWKS is set
+type JWT struct {
+	JWKS     string `env:"JWT_JWKS"`
+	Issuer   string `env:"JWT_ISSUER"`
+	Audience string `env:"JWT_AUDIENCE"`
+	Claim    string `env:"JWT_PERMISSIONS_CLAIM" env-default:"scope"`
 }
 
 func MustLoad() Config {
@@ -0,0 +1,91 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+var (
+	ErrEmptyName   = errors.New("name cannot be empty")
+	ErrNameToLong  = errors.New("name is too long")
+	ErrUnknownRole = errors.New("unknown role")
+)
+
+type Role int16
+
+const (
+	None Role = iota
+	Reader
+	Editor
+	Admin
+)
+
+var roleNames = map[Role]string{
+	None:   "none",
+	Reader: "reader",
+	Editor: "editor",
+	Admin:  "admin",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+func ParseRole(s string) (Role, error) {
+	for role, name := range roleNames {
+		if role != None && name == s {
+			return role, nil
+		}
+	}
+	return None, ErrUnknownRole
+}
+
+const (
+	secretPrefix = "sk_"
+	secretSize   = 32
+	prefixLen    = len(secretPrefix) + 6
+)
+
+type APIKey struct {
+	ID        int64
+	Name      string
+	Role      Role
+	Prefix    string
+	Hash      []byte
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// New generates a key and returns it with the secret, which is not stored anywhere
+func New(name string, role Role) (APIKey, string, error) {
+	if len(name) == 0 {
+		return APIKey{}, "", ErrEmptyName
+	}
+	if len(name) > 255 {
+		return APIKey{}, "", ErrNameToLong
+	}
+	if _, ok := roleNames[role]; !ok || role == None {
+		return APIKey{}, "", ErrUnknownRole
+	}
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := secretPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return APIKey{
+		Name:      name,
+		Role:      role,
+		Prefix:    secret[:prefixLen],
+		Hash:      Hash(secret),
+		CreatedAt: time.Now().UTC(),
+	}, secret, nil
+}
+
+// Hash returns the value stored instead of the secret. Secrets are random, so there is no need in slow hashes
+func Hash(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
@@ -0,0 +1,111 @@
+package attributes
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxAttributes = 100
+	maxValueLen   = 1024
+	maxListLen    = 100
+)
+
+var (
+	ErrInvalidName   = errors.New("attribute name must be 1-64 characters: lowercase letters, digits and '_', starting with a letter")
+	ErrInvalidValue  = errors.New("attribute value does not match the type of the attribute")
+	ErrTooManyValues = errors.New("too many attributes")
+	// ErrUnknownAttribute rejects values of attributes missing in the schema
+	ErrUnknownAttribute = errors.New("attribute is not defined")
+)
+
+var nameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Attributes of the user by their names. Values are as decoded from JSON: strings and dates in the YYYY-MM-DD
+// form are string, numbers are float64, booleans are bool and string lists are []any of strings
+type Attributes map[string]any
+
+// Change of an attribute of the user, Old is nil for a new attribute and New is nil for a removed one
+type Change struct {
+	UserID int64
+	Name   string
+	Old    any
+	New    any
+	Time   time.Time
+}
+
+// ValidName reports whether the name may be used for an attribute
+func ValidName(name string) bool {
+	return nameRe.MatchString(name)
+}
+
+// Validate checks the values decoded from JSON against the schema. Integers are converted to float64.
+// Nil values are kept, they remove the attributes when merged
+func (s Schema) Validate(values map[string]any) (Attributes, error) {
+	if len(values) > maxAttributes {
+		return nil, ErrTooManyValues
+	}
+	res := make(Attributes, len(values))
+	for name, v := range values {
+		if !ValidName(name) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+		def, ok := s[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAttribute, name)
+		}
+		if v == nil {
+			res[name] = nil
+			continue
+		}
+		value, ok := def.Type.convert(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s must be %s", ErrInvalidValue, name, def.Type)
+		}
+		res[name] = value
+	}
+	return res, nil
+}
+
+// Merge returns the current attributes with the changes applied, nil values remove attributes
+func Merge(current Attributes, changes Attributes) (Attributes, error) {
+	res := make(Attributes, len(current)+len(changes))
+	for name, v := range current {
+		res[name] = v
+	}
+	for name, v := range changes {
+		if v == nil {
+			delete(res, name)
+			continue
+		}
+		res[name] = v
+	}
+	if len(res) > maxAttributes {
+		return nil, ErrTooManyValues
+	}
+	return res, nil
+}
+
+// Diff returns the changes of attributes of the user ordered by name
+func Diff(userID int64, old, new Attributes) []Change {
+	var res []Change
+	for name, v := range old {
+		if n, ok := new[name]; !ok || !reflect.DeepEqual(v, n) {
+			res = append(res, Change{UserID: userID, Name: name, Old: v, New: new[name]})
+		}
+	}
+	for name, v := range new {
+		if _, ok := old[name]; !ok {
+			res = append(res, Change{UserID: userID, Name: name, New: v})
+		}
+	}
+	slices.SortFunc(res, func(a, b Change) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
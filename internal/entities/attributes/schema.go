@@ -0,0 +1,123 @@
+package attributes
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const maxDescriptionLen = 1024
+
+var (
+	ErrUnknownType        = errors.New("attribute type must be string, number, bool, date or string_list")
+	ErrDescriptionTooLong = errors.New("attribute description is too long")
+	// ErrInUse rejects deleting definitions of attributes set for users
+	ErrInUse = errors.New("attribute is set for users")
+)
+
+// Type of values of the attribute
+type Type string
+
+const (
+	String     Type = "string"
+	Number     Type = "number"
+	Bool       Type = "bool"
+	Date       Type = "date"
+	StringList Type = "string_list"
+)
+
+func ParseType(s string) (Type, error) {
+	switch t := Type(s); t {
+	case String, Number, Bool, Date, StringList:
+		return t, nil
+	}
+	return "", ErrUnknownType
+}
+
+// convert returns the value decoded from JSON in the form stored for the type
+func (t Type) convert(v any) (any, bool) {
+	switch t {
+	case String:
+		s, ok := v.(string)
+		return s, ok && len(s) <= maxValueLen
+	case Number:
+		switch v := v.(type) {
+		case float64:
+			return v, true
+		case int:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		}
+		return nil, false
+	case Bool:
+		b, ok := v.(bool)
+		return b, ok
+	case Date:
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		d, err := time.Parse(time.DateOnly, s)
+		return d.Format(time.DateOnly), err == nil
+	case StringList:
+		var items []any
+		switch v := v.(type) {
+		case []any:
+			items = v
+		case []string:
+			for _, s := range v {
+				items = append(items, s)
+			}
+		default:
+			return nil, false
+		}
+		if len(items) > maxListLen {
+			return nil, false
+		}
+		res := make([]any, 0, len(items))
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || len(s) > maxValueLen {
+				return nil, false
+			}
+			res = append(res, s)
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// Definition of the attribute in the schema
+type Definition struct {
+	Name        string
+	Type        Type
+	Description string
+	CreatedAt   time.Time
+}
+
+func NewDefinition(name string, typ string, description string) (Definition, error) {
+	if !ValidName(name) {
+		return Definition{}, ErrInvalidName
+	}
+	t, err := ParseType(typ)
+	if err != nil {
+		return Definition{}, err
+	}
+	description = strings.TrimSpace(description)
+	if len(description) > maxDescriptionLen {
+		return Definition{}, ErrDescriptionTooLong
+	}
+	return Definition{Name: name, Type: t, Description: description}, nil
+}
+
+// Schema is the definitions of attributes by their names. Users have only the attributes defined in the schema
+type Schema map[string]Definition
+
+func NewSchema(defs []Definition) Schema {
+	res := make(Schema, len(defs))
+	for _, d := range defs {
+		res[d.Name] = d
+	}
+	return res
+}
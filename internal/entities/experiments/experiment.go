@@ -0,0 +1,117 @@
+package experiments
+
+import (
+	"errors"
+	"regexp"
+	"time"
+	"user-segmentation/internal/entities/segments"
+)
+
+const (
+	maxVariants = 20
+	maxWeight   = 10000
+)
+
+var (
+	ErrInvalidKey      = errors.New("key must be 1-100 characters: lowercase letters, digits, '-' and '_'")
+	ErrInvalidVariant  = errors.New("variant name must be 1-100 characters: lowercase letters, digits, '-' and '_'")
+	ErrDuplicateName   = errors.New("variant names must be unique")
+	ErrInvalidWeight   = errors.New("weight must be from 0 to 10000")
+	ErrTooFewVariants  = errors.New("experiment needs at least 2 variants")
+	ErrTooManyVariants = errors.New("too many variants")
+	ErrZeroWeights     = errors.New("at least one variant must have positive weight")
+	// ErrSegmentInUse rejects purging a segment of a variant
+	ErrSegmentInUse = errors.New("segment is used by experiment")
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,100}$`)
+
+// Variant is a group of the experiment. Users of the variant are members of its segment
+type Variant struct {
+	Name    string
+	Weight  int
+	Segment segments.Segment
+}
+
+// Experiment splits users between variants in proportion to their weights
+type Experiment struct {
+	Key         string
+	Description string
+	Variants    []Variant
+	CreatedAt   time.Time
+}
+
+// Assignment is the variant of the experiment the user belongs to
+type Assignment struct {
+	Experiment string
+	Variant    string
+	Segment    string
+}
+
+// SegmentSlug returns the slug of the segment of the variant
+func SegmentSlug(key string, variant string) string {
+	return key + "." + variant
+}
+
+// New validates the experiment and names segments of its variants
+func New(key string, description string, variants []Variant) (Experiment, error) {
+	if !namePattern.MatchString(key) {
+		return Experiment{}, ErrInvalidKey
+	}
+	meta, err := segments.NewMetadata(description, "", nil)
+	if err != nil {
+		return Experiment{}, err
+	}
+	if len(variants) < 2 {
+		return Experiment{}, ErrTooFewVariants
+	}
+	if len(variants) > maxVariants {
+		return Experiment{}, ErrTooManyVariants
+	}
+	res := Experiment{Key: key, Description: meta.Description, Variants: make([]Variant, len(variants))}
+	names := make(map[string]bool, len(variants))
+	total := 0
+	for i, v := range variants {
+		if !namePattern.MatchString(v.Name) {
+			return Experiment{}, ErrInvalidVariant
+		}
+		if names[v.Name] {
+			return Experiment{}, ErrDuplicateName
+		}
+		names[v.Name] = true
+		if v.Weight < 0 || v.Weight > maxWeight {
+			return Experiment{}, ErrInvalidWeight
+		}
+		total += v.Weight
+		seg, err := segments.New(SegmentSlug(key, v.Name))
+		if err != nil {
+			return Experiment{}, err
+		}
+		seg.Description = meta.Description
+		res.Variants[i] = Variant{Name: v.Name, Weight: v.Weight, Segment: seg}
+	}
+	if total == 0 {
+		return Experiment{}, ErrZeroWeights
+	}
+	return res, nil
+}
+
+// Assign picks the variant of the user. The choice depends only on the key, the user and the weights,
+// so it is the same on every replica
+func (e Experiment) Assign(userID int64) Variant {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total == 0 {
+		return Variant{}
+	}
+	b := segments.Bucket(e.Key, userID, total)
+	for _, v := range e.Variants {
+		if b < v.Weight {
+			return v
+		}
+		b -= v.Weight
+	}
+	return Variant{}
+}
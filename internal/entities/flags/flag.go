@@ -0,0 +1,107 @@
+// Package flags evaluates feature flags of users. Keys of flags are slugs of segments and keys of experiments
+package flags
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+const (
+	maxKeys   = 100
+	maxKeyLen = 255
+)
+
+var (
+	ErrInvalidKeys     = errors.New("keys must be 1-100 comma-separated names of at most 255 characters")
+	ErrInvalidOverride = errors.New("override must be a boolean or a non-empty string of at most 255 characters")
+)
+
+// Source tells where the value of the flag comes from
+type Source string
+
+const (
+	// SourceOverride is the value set for the user
+	SourceOverride Source = "override"
+	// SourceExperiment is the variant of the experiment the user is assigned to, false for users out of the experiment
+	SourceExperiment Source = "experiment"
+	// SourceSegment is the membership of the user in the active segment
+	SourceSegment Source = "segment"
+	// SourceDefault is false for keys matching no segment or experiment
+	SourceDefault Source = "default"
+)
+
+// Flag of the user. Value is bool or the name of the variant
+type Flag struct {
+	Key    string
+	Value  any
+	Source Source
+}
+
+// ParseKeys parses comma-separated keys keeping their order and dropping duplicates
+func ParseKeys(s string) ([]string, error) {
+	var res []string
+	for _, key := range strings.Split(s, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" || len(key) > maxKeyLen {
+			return nil, ErrInvalidKeys
+		}
+		if !slices.Contains(res, key) {
+			res = append(res, key)
+		}
+	}
+	if len(res) > maxKeys {
+		return nil, ErrInvalidKeys
+	}
+	return res, nil
+}
+
+// NewOverride validates the value of the flag set for the user
+func NewOverride(key string, value any) (any, error) {
+	if key == "" || len(key) > maxKeyLen {
+		return nil, ErrInvalidKeys
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if v != "" && len(v) <= maxKeyLen {
+			return v, nil
+		}
+	}
+	return nil, ErrInvalidOverride
+}
+
+// User is what flags of the user are evaluated from
+type User struct {
+	// Segments are the slugs of the active segments of the user
+	Segments []string
+	// Variants are the variants of the user by experiment keys
+	Variants map[string]string
+	// Overrides are the values set for the user by flag keys
+	Overrides map[string]any
+}
+
+// Evaluate returns the flags in the order of the keys. Overrides win, then experiments and segments.
+// Known are the sources of keys matching existing experiments and segments
+func Evaluate(keys []string, user User, known map[string]Source) []Flag {
+	res := make([]Flag, len(keys))
+	for i, key := range keys {
+		res[i] = Flag{Key: key, Value: false, Source: SourceDefault}
+		if v, ok := user.Overrides[key]; ok {
+			res[i].Value, res[i].Source = v, SourceOverride
+			continue
+		}
+		switch known[key] {
+		case SourceExperiment:
+			res[i].Source = SourceExperiment
+			if v, ok := user.Variants[key]; ok {
+				res[i].Value = v
+			}
+		case SourceSegment:
+			res[i].Source = SourceSegment
+			res[i].Value = slices.Contains(user.Segments, key)
+		}
+	}
+	return res
+}
@@ -15,13 +15,83 @@ type Type int64
 const (
 	Add Type = iota
 	Remove
+	// Rename changes the slug of the segment, Details contain DetailFrom and DetailTo
+	Rename
+	// Transition changes the state of the segment, Details contain DetailFrom and DetailTo
+	Transition
+	// Activate and Deactivate record the segment entering and leaving its schedule,
+	// Details contain DetailActiveFrom and DetailActiveUntil of the schedule
+	Activate
+	Deactivate
+	// Rollout changes the rollout percent of the segment, Details contain DetailFrom and DetailTo
+	Rollout
 )
 
+const (
+	DetailFrom        = "from"
+	DetailTo          = "to"
+	DetailActiveFrom  = "active_from"
+	DetailActiveUntil = "active_until"
+	// DetailExperiment and DetailVariant describe additions of users assigned to experiment variants
+	DetailExperiment = "experiment"
+	DetailVariant    = "variant"
+	// DetailRollout is the rollout percent that added the user to the segment or removed from it
+	DetailRollout = "rollout"
+	// DetailRule is the rule of the segment the user entered or left
+	DetailRule = "rule"
+)
+
+// Operation changes membership of the user in the segment. Operations of the segment itself
+// have zero UserID and describe the change in Details
 type Operation struct {
+	ID      int64
 	UserID  int64
 	Segment segments.Segment
 	Type    Type
 	Time    time.Time
+	Details map[string]string
+}
+
+func (t Type) String() string {
+	switch t {
+	case Remove:
+		return "remove"
+	case Rename:
+		return "rename"
+	case Transition:
+		return "state"
+	case Activate:
+		return "activate"
+	case Deactivate:
+		return "deactivate"
+	case Rollout:
+		return "rollout"
+	}
+	return "add"
+}
+
+// IsMembership reports whether operations of the type change membership of a user
+func (t Type) IsMembership() bool {
+	return t == Add || t == Remove
+}
+
+func ParseType(s string) (Type, error) {
+	for _, t := range []Type{Add, Remove, Rename, Transition, Activate, Deactivate, Rollout} {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return 0, ErrIncorrectType
+}
+
+// Filter selects operations of the user and of the segment. Zero fields match any value
+type Filter struct {
+	UserID  int64
+	Segment string
+}
+
+func (f Filter) Match(op Operation) bool {
+	return (f.UserID == 0 || f.UserID == op.UserID) && (f.Segment == "" || f.Segment == op.Segment.Slug)
 }
 
 func New(userID int64, seg segments.Segment, opType Type) (Operation, error) {
@@ -35,3 +105,53 @@ func New(userID int64, seg segments.Segment, opType Type) (Operation, error) {
 		Time:    time.Now().UTC(),
 	}, nil
 }
+
+// NewRenaming records renaming of the segment with the slug from to the slug of seg
+func NewRenaming(from string, seg segments.Segment) Operation {
+	return Operation{
+		Segment: seg,
+		Type:    Rename,
+		Time:    time.Now().UTC(),
+		Details: map[string]string{DetailFrom: from, DetailTo: seg.Slug},
+	}
+}
+
+// NewTransition records moving of the segment from the state to the state of seg
+func NewTransition(from segments.State, seg segments.Segment) Operation {
+	return Operation{
+		Segment: seg,
+		Type:    Transition,
+		Time:    time.Now().UTC(),
+		Details: map[string]string{DetailFrom: from.String(), DetailTo: seg.State.String()},
+	}
+}
+
+// NewScheduled records the transition of the segment by its schedule
+func NewScheduled(tr segments.Transition, schedule segments.Schedule) Operation {
+	op := Operation{
+		Segment: segments.Segment{Slug: tr.Segment, Schedule: schedule},
+		Type:    Deactivate,
+		Time:    tr.At,
+		Details: map[string]string{},
+	}
+	if tr.Activate {
+		op.Type = Activate
+	}
+	if schedule.ActiveFrom != nil {
+		op.Details[DetailActiveFrom] = schedule.ActiveFrom.Format(time.RFC3339)
+	}
+	if schedule.ActiveUntil != nil {
+		op.Details[DetailActiveUntil] = schedule.ActiveUntil.Format(time.RFC3339)
+	}
+	return op
+}
+
+// NewRolloutChange records the change of the rollout percent of the segment
+func NewRolloutChange(ch segments.RolloutChange) Operation {
+	return Operation{
+		Segment: segments.Segment{Slug: ch.Segment},
+		Type:    Rollout,
+		Time:    ch.At,
+		Details: map[string]string{DetailFrom: segments.FormatPercent(ch.From), DetailTo: segments.FormatPercent(ch.To)},
+	}
+}
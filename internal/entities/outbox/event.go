@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+	"user-segmentation/internal/entities/operations"
+)
+
+// TopicMembership is the topic of adding users to segments and removing them
+const TopicMembership = "segments.membership"
+
+const keySize = 16
+
+// Event is the message waiting in the outbox to be published. Key is unique for the event,
+// consumers deduplicate by it, because the event is published at least once
+type Event struct {
+	ID        int64
+	Key       string
+	Topic     string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+type membership struct {
+	UserID    int64     `json:"user_id"`
+	Segment   string    `json:"segment"`
+	Operation string    `json:"operation"`
+	Time      time.Time `json:"time"`
+}
+
+func NewKey() (string, error) {
+	buf := make([]byte, keySize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// NewMembership creates the event of the operation
+func NewMembership(op operations.Operation) (Event, error) {
+	key, err := NewKey()
+	if err != nil {
+		return Event{}, err
+	}
+	payload, err := json.Marshal(membership{
+		UserID:    op.UserID,
+		Segment:   op.Segment.Slug,
+		Operation: op.Type.String(),
+		Time:      op.Time,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		Key:       key,
+		Topic:     TopicMembership,
+		Payload:   payload,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"user-segmentation/internal/entities/attributes"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenValue
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenIn
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value value
+}
+
+var (
+	wordRe   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	dateRe   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	numberRe = regexp.MustCompile(`^-?\d+(\.\d+)?`)
+)
+
+var keywords = map[string]tokenKind{"and": tokenAnd, "or": tokenOr, "not": tokenNot, "in": tokenIn}
+
+// lex splits the rule into tokens. Keywords are case-insensitive, attribute names are lowercase
+func lex(text string) ([]token, error) {
+	var res []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		rest := text[i:]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			res = append(res, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			res = append(res, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			res = append(res, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case strings.HasPrefix(rest, "==") || strings.HasPrefix(rest, "!=") ||
+			strings.HasPrefix(rest, "<=") || strings.HasPrefix(rest, ">="):
+			res = append(res, token{kind: tokenOp, text: rest[:2], pos: i})
+			i += 2
+		case c == '<' || c == '>':
+			res = append(res, token{kind: tokenOp, text: rest[:1], pos: i})
+			i++
+		case c == '"':
+			s, n, err := lexString(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s at %d", ErrInvalidRule, err.Error(), i)
+			}
+			res = append(res, token{kind: tokenValue, text: rest[:n], pos: i, value: value{kind: kindString, str: s}})
+			i += n
+		case dateRe.MatchString(rest):
+			s := dateRe.FindString(rest)
+			t, err := time.Parse(time.DateOnly, s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid date %q at %d", ErrInvalidRule, s, i)
+			}
+			res = append(res, token{kind: tokenValue, text: s, pos: i, value: value{kind: kindDate, date: t}})
+			i += len(s)
+		case numberRe.MatchString(rest):
+			s := numberRe.FindString(rest)
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid number %q at %d", ErrInvalidRule, s, i)
+			}
+			res = append(res, token{kind: tokenValue, text: s, pos: i, value: value{kind: kindNumber, num: f}})
+			i += len(s)
+		case wordRe.MatchString(rest):
+			s := wordRe.FindString(rest)
+			lower := strings.ToLower(s)
+			if kind, ok := keywords[lower]; ok {
+				res = append(res, token{kind: kind, text: s, pos: i})
+			} else if lower == "true" || lower == "false" {
+				res = append(res, token{kind: tokenValue, text: s, pos: i, value: value{kind: kindBool, b: lower == "true"}})
+			} else if attributes.ValidName(s) {
+				res = append(res, token{kind: tokenIdent, text: s, pos: i})
+			} else {
+				return nil, fmt.Errorf("%w: invalid attribute name %q at %d", ErrInvalidRule, s, i)
+			}
+			i += len(s)
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidRule, c, i)
+		}
+	}
+	return append(res, token{kind: tokenEOF, text: "end of rule", pos: len(text)}), nil
+}
+
+// lexString reads the double-quoted string at the start of s with \" and \\ escapes
+// and returns its value and length in s
+func lexString(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 == len(s) || (s[i+1] != '"' && s[i+1] != '\\') {
+				return "", 0, errors.New("invalid escape")
+			}
+			i++
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
@@ -0,0 +1,381 @@
+// Package rules parses and evaluates rules of dynamic segments over attributes of users
+package rules
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"user-segmentation/internal/entities/attributes"
+)
+
+const (
+	maxRuleLen = 4096
+	maxDepth   = 32
+	maxValues  = 1000
+)
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+// Rule is a boolean expression over attributes of the user, e.g.
+// `country in ("RU", "KZ") and platform == "ios" and signup_date > 2024-01-01`.
+//
+// Comparisons are ==, !=, <, <=, >, >=, in and not in with a list of values, and they are combined
+// by and, or, not and parentheses. Values are double-quoted strings, numbers, true, false and dates
+// in the YYYY-MM-DD form; dates are compared with string attributes in YYYY-MM-DD or RFC 3339.
+// A string list attribute equals a value it contains. A comparison with a missing attribute
+// or an attribute of another type is false
+type Rule struct {
+	text string
+	root node
+}
+
+type node interface {
+	match(attrs attributes.Attributes) bool
+}
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+	kindDate
+)
+
+type value struct {
+	kind valueKind
+	str  string
+	num  float64
+	b    bool
+	date time.Time
+}
+
+// Parse parses and validates the rule
+func Parse(text string) (Rule, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Rule{}, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+	if len(text) > maxRuleLen {
+		return Rule{}, fmt.Errorf("%w: rule is too long", ErrInvalidRule)
+	}
+	tokens, err := lex(text)
+	if err != nil {
+		return Rule{}, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return Rule{}, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return Rule{}, unexpected(t)
+	}
+	return Rule{text: text, root: root}, nil
+}
+
+// String returns the text of the rule
+func (r Rule) String() string {
+	return r.text
+}
+
+// Match reports whether the attributes satisfy the rule. The zero Rule matches nothing
+func (r Rule) Match(attrs attributes.Attributes) bool {
+	return r.root != nil && r.root.match(attrs)
+}
+
+// Attributes returns the sorted names of attributes the rule refers to
+func (r Rule) Attributes() []string {
+	var res []string
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case and:
+			walk(n.left)
+			walk(n.right)
+		case or:
+			walk(n.left)
+			walk(n.right)
+		case not:
+			walk(n.node)
+		case comparison:
+			res = append(res, n.attr)
+		}
+	}
+	if r.root != nil {
+		walk(r.root)
+	}
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return token{}, unexpected(t)
+	}
+	return t, nil
+}
+
+func unexpected(t token) error {
+	return fmt.Errorf("%w: unexpected %s at %d", ErrInvalidRule, t.text, t.pos)
+}
+
+// or := and {"or" and}
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left: left, right: right}
+	}
+	return left, nil
+}
+
+// and := unary {"and" unary}
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left: left, right: right}
+	}
+	return left, nil
+}
+
+// unary := "not" unary | "(" or ")" | comparison
+func (p *parser) unary() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, fmt.Errorf("%w: rule is nested too deep", ErrInvalidRule)
+	}
+	switch p.peek().kind {
+	case tokenNot:
+		p.next()
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{node: n}, nil
+	case tokenLParen:
+		p.next()
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return p.comparison()
+}
+
+// comparison := ident op value | ident ["not"] "in" "(" value {"," value} ")"
+func (p *parser) comparison() (node, error) {
+	attr, err := p.expect(tokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	t := p.next()
+	switch t.kind {
+	case tokenOp:
+		v, err := p.expect(tokenValue)
+		if err != nil {
+			return nil, err
+		}
+		if v.value.kind == kindBool && t.text != "==" && t.text != "!=" {
+			return nil, fmt.Errorf("%w: booleans are compared only by == and != at %d", ErrInvalidRule, t.pos)
+		}
+		return comparison{attr: attr.text, op: t.text, values: []value{v.value}}, nil
+	case tokenNot:
+		if _, err := p.expect(tokenIn); err != nil {
+			return nil, err
+		}
+		values, err := p.values()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{attr: attr.text, op: "not in", values: values}, nil
+	case tokenIn:
+		values, err := p.values()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{attr: attr.text, op: "in", values: values}, nil
+	}
+	return nil, unexpected(t)
+}
+
+func (p *parser) values() ([]value, error) {
+	if _, err := p.expect(tokenLParen); err != nil {
+		return nil, err
+	}
+	var res []value
+	for {
+		v, err := p.expect(tokenValue)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v.value)
+		if len(res) > maxValues {
+			return nil, fmt.Errorf("%w: too many values at %d", ErrInvalidRule, v.pos)
+		}
+		t := p.next()
+		if t.kind == tokenRParen {
+			return res, nil
+		}
+		if t.kind != tokenComma {
+			return nil, unexpected(t)
+		}
+	}
+}
+
+type and struct{ left, right node }
+
+func (n and) match(attrs attributes.Attributes) bool {
+	return n.left.match(attrs) && n.right.match(attrs)
+}
+
+type or struct{ left, right node }
+
+func (n or) match(attrs attributes.Attributes) bool {
+	return n.left.match(attrs) || n.right.match(attrs)
+}
+
+type not struct{ node node }
+
+func (n not) match(attrs attributes.Attributes) bool {
+	return !n.node.match(attrs)
+}
+
+type comparison struct {
+	attr   string
+	op     string
+	values []value
+}
+
+func (n comparison) match(attrs attributes.Attributes) bool {
+	attr, ok := attrs[n.attr]
+	if !ok {
+		return false
+	}
+	if list, ok := attr.([]any); ok {
+		return n.matchList(list)
+	}
+	switch n.op {
+	case "in", "not in":
+		typed := false
+		for _, v := range n.values {
+			c, ok := compare(attr, v)
+			if ok && c == 0 {
+				return n.op == "in"
+			}
+			typed = typed || ok
+		}
+		// an attribute of another type than all the values is not comparable, like with other operators
+		return typed && n.op == "not in"
+	}
+	c, ok := compare(attr, n.values[0])
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// matchList matches a string list attribute: == and in are true if the list contains any of the values,
+// != and not in are true if it contains none of them. Lists are not ordered
+func (n comparison) matchList(list []any) bool {
+	if n.op != "==" && n.op != "!=" && n.op != "in" && n.op != "not in" {
+		return false
+	}
+	for _, v := range n.values {
+		if v.kind == kindNumber || v.kind == kindBool {
+			return false
+		}
+	}
+	contains := false
+	for _, item := range list {
+		for _, v := range n.values {
+			if c, ok := compare(item, v); ok && c == 0 {
+				contains = true
+			}
+		}
+	}
+	return contains == (n.op == "==" || n.op == "in")
+}
+
+// compare compares the attribute with the value. It is false if their types differ
+func compare(attr any, v value) (int, bool) {
+	switch v.kind {
+	case kindString:
+		s, ok := attr.(string)
+		return strings.Compare(s, v.str), ok
+	case kindNumber:
+		f, ok := attr.(float64)
+		return cmp.Compare(f, v.num), ok
+	case kindBool:
+		b, ok := attr.(bool)
+		if b == v.b {
+			return 0, ok
+		}
+		return 1, ok
+	}
+	s, ok := attr.(string)
+	if !ok {
+		return 0, false
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return 0, false
+		}
+	}
+	return t.Compare(v.date), true
+}
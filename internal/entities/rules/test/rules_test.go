@@ -0,0 +1,80 @@
+package test
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/entities/rules"
+)
+
+func TestRule_Match(t *testing.T) {
+	attrs := attributes.Attributes{
+		"country":     "RU",
+		"platform":    "ios",
+		"age":         float64(30),
+		"premium":     true,
+		"signup_date": "2024-03-15",
+		"last_seen":   "2024-01-01T10:00:00Z",
+		"tags":        []any{"beta", "vip"},
+	}
+	cases := []struct {
+		rule string
+		want bool
+	}{
+		{`country in ("RU","KZ") and platform == "ios" and signup_date > 2024-01-01`, true},
+		{`country in ("BY", "KZ")`, false},
+		{`country not in ("BY", "KZ")`, true},
+		{`age >= 30 and age < 31`, true},
+		{`age > 30 or premium == false`, false},
+		{`not premium == false`, true},
+		{`NOT (platform == "android" OR age <= -1)`, true},
+		{`last_seen >= 2024-01-01`, true},
+		{`signup_date < 2024-03-15`, false},
+		{`missing == "x"`, false},
+		{`missing != "x"`, false},
+		{`not missing == "x"`, true},
+		{`age == "30"`, false},
+		{`age not in ("30", "31")`, false},
+		{`age not in ("30", 31)`, true},
+		{`platform > "android"`, true},
+		{`country == "R\"U"`, false},
+		{`tags == "vip"`, true},
+		{`tags != "vip"`, false},
+		{`tags in ("alpha", "beta")`, true},
+		{`tags not in ("alpha", "gamma")`, true},
+		{`tags > "a"`, false},
+		{`tags != 1`, false},
+	}
+	for _, c := range cases {
+		r, err := rules.Parse(c.rule)
+		require.NoError(t, err, c.rule)
+		require.Equal(t, c.want, r.Match(attrs), c.rule)
+	}
+}
+
+func TestRule_Parse(t *testing.T) {
+	r, err := rules.Parse(`  b == 1 and (a in (1, 2) or not b != 2)  `)
+	require.NoError(t, err)
+	require.Equal(t, `b == 1 and (a in (1, 2) or not b != 2)`, r.String())
+	require.Equal(t, []string{"a", "b"}, r.Attributes())
+
+	for _, rule := range []string{
+		``,
+		`country`,
+		`country ==`,
+		`country = "RU"`,
+		`Country == "RU"`,
+		`country == "RU`,
+		`country in ()`,
+		`country in ("RU" "KZ")`,
+		`premium > true`,
+		`(country == "RU"`,
+		`country == "RU")`,
+		`country == "RU" and`,
+		`signup_date > 2024-13-01`,
+		`a == 1 a == 2`,
+	} {
+		_, err := rules.Parse(rule)
+		require.ErrorIs(t, err, rules.ErrInvalidRule, rule)
+	}
+}
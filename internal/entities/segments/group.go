@@ -0,0 +1,38 @@
+package segments
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const maxGroupLen = 255
+
+var (
+	ErrEmptyGroup       = errors.New("group name cannot be empty")
+	ErrGroupNameTooLong = errors.New("group name is too long")
+	// ErrExclusiveGroup rejects adding a user to a segment of the exclusive group the user is already in
+	ErrExclusiveGroup = errors.New("user is already in another segment of the exclusive group")
+	// ErrGroupConflict rejects moving a segment to the exclusive group when its users are in other segments of the group
+	ErrGroupConflict = errors.New("users of the segment are in other segments of the exclusive group")
+)
+
+// Group unites segments, e.g. experiments of one layer. A user is in at most one segment of an exclusive group
+type Group struct {
+	Name      string
+	Exclusive bool
+	// Segments are slugs of the segments in the group ordered by slug
+	Segments  []string
+	CreatedAt time.Time
+}
+
+func NewGroup(name string, exclusive bool) (Group, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return Group{}, ErrEmptyGroup
+	}
+	if len(name) > maxGroupLen {
+		return Group{}, ErrGroupNameTooLong
+	}
+	return Group{Name: name, Exclusive: exclusive, Segments: []string{}}, nil
+}
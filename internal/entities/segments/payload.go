@@ -0,0 +1,117 @@
+package segments
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xeipuuv/gojsonschema"
+	"slices"
+	"strings"
+)
+
+const maxPayloadSize = 64 << 10
+
+var (
+	ErrInvalidPayload       = errors.New("payload must be a JSON object matching its schema")
+	ErrInvalidPayloadSchema = errors.New("payload schema must be a valid JSON Schema")
+	ErrPayloadTooLarge      = errors.New("payload and its schema must be at most 64 KiB each")
+)
+
+// Payload is the configuration the segment gives to its members. Configurations of the segments of the user
+// are merged by Priority
+type Payload struct {
+	// Value is a JSON object
+	Value json.RawMessage
+	// Schema is the JSON Schema of the value, nil for payloads without a schema
+	Schema   json.RawMessage
+	Priority int
+}
+
+// NewPayload validates the value against the schema, empty schema allows any object
+func NewPayload(value json.RawMessage, schema json.RawMessage, priority int) (Payload, error) {
+	if len(value) > maxPayloadSize || len(schema) > maxPayloadSize {
+		return Payload{}, ErrPayloadTooLarge
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(value, &obj); err != nil || obj == nil {
+		return Payload{}, ErrInvalidPayload
+	}
+	p := Payload{Value: compact(value), Priority: priority}
+	if len(bytes.TrimSpace(schema)) == 0 || string(bytes.TrimSpace(schema)) == "null" {
+		return p, nil
+	}
+	s, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return Payload{}, fmt.Errorf("%w: %s", ErrInvalidPayloadSchema, err)
+	}
+	res, err := s.Validate(gojsonschema.NewBytesLoader(value))
+	if err != nil {
+		return Payload{}, fmt.Errorf("%w: %s", ErrInvalidPayload, err)
+	}
+	if !res.Valid() {
+		errs := make([]string, len(res.Errors()))
+		for i, e := range res.Errors() {
+			errs[i] = e.String()
+		}
+		return Payload{}, fmt.Errorf("%w: %s", ErrInvalidPayload, strings.Join(errs, "; "))
+	}
+	p.Schema = compact(schema)
+	return p, nil
+}
+
+func compact(raw json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// Config of the user merged from payloads of the segments of the user
+type Config struct {
+	Values map[string]any
+	// Segments are the slugs of the segments with payloads from the highest priority
+	Segments []string
+}
+
+// MergePayloads merges the payloads of the segments into one configuration. Objects are merged key by key,
+// other values of the segment with the higher priority win, and of equal priorities the lesser slug wins
+func MergePayloads(segs []Segment) Config {
+	withPayload := make([]Segment, 0, len(segs))
+	for _, seg := range segs {
+		if seg.Payload != nil {
+			withPayload = append(withPayload, seg)
+		}
+	}
+	slices.SortFunc(withPayload, func(a, b Segment) int {
+		if c := cmp.Compare(b.Payload.Priority, a.Payload.Priority); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Slug, b.Slug)
+	})
+	config := make(map[string]any)
+	sources := make([]string, len(withPayload))
+	// the lowest priority goes first, so higher ones overwrite it
+	for i := len(withPayload) - 1; i >= 0; i-- {
+		var obj map[string]any
+		// stored payloads are valid objects
+		_ = json.Unmarshal(withPayload[i].Payload.Value, &obj)
+		mergeObjects(config, obj)
+		sources[i] = withPayload[i].Slug
+	}
+	return Config{Values: config, Segments: sources}
+}
+
+func mergeObjects(dst map[string]any, src map[string]any) {
+	for k, v := range src {
+		srcObj, ok := v.(map[string]any)
+		dstObj, dstOk := dst[k].(map[string]any)
+		if ok && dstOk {
+			mergeObjects(dstObj, srcObj)
+			continue
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,125 @@
+package segments
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RolloutBuckets is the number of buckets users are spread over by rollouts, a bucket is 0.01% of users
+const RolloutBuckets = 10000
+
+const maxRampSteps = 100
+
+var (
+	ErrInvalidPercent = errors.New("percent must be from 0 to 100 with at most 2 decimals")
+	ErrInvalidRamp    = errors.New("ramp steps must be in the future at different times")
+	ErrTooManySteps   = errors.New("too many ramp steps")
+	// ErrDynamicMembership rejects explicit changes of segments with rollouts and rules
+	ErrDynamicMembership = errors.New("members of the segment are computed by its rollout or rule")
+)
+
+// Rollout includes the percent of users in the segment. Users are included by their buckets in ascending order,
+// so users stay included when the percent grows, and lowering the percent removes the highest buckets first
+type Rollout struct {
+	Percent float64
+	// Salt makes buckets of users independent between segments
+	Salt string
+}
+
+// Includes reports whether the bucket of the user is within the percent
+func (r Rollout) Includes(userID int64) bool {
+	return Bucket(r.Salt, userID, RolloutBuckets) < Buckets(r.Percent)
+}
+
+// RampStep sets the percent of the rollout at the time
+type RampStep struct {
+	At      time.Time
+	Percent float64
+}
+
+// RolloutChange is the change of the rollout percent of the segment. Nil percents mean the rollout is disabled
+type RolloutChange struct {
+	Segment string
+	From    *float64
+	To      *float64
+	At      time.Time
+}
+
+// Resync is the recomputation of members of the segment after its rollout or rule changed
+type Resync struct {
+	Segment string
+	Added   int
+	Removed int
+}
+
+func ValidatePercent(percent float64) error {
+	if percent < 0 || percent > 100 || math.Abs(percent*100-math.Round(percent*100)) > 1e-6 {
+		return ErrInvalidPercent
+	}
+	return nil
+}
+
+// Buckets returns the number of buckets of the percent
+func Buckets(percent float64) int {
+	return int(math.Round(percent * 100))
+}
+
+// PercentOf returns the percent of the number of buckets
+func PercentOf(buckets int) float64 {
+	return float64(buckets) / 100
+}
+
+// FormatPercent formats the percent for history details, nil is "off"
+func FormatPercent(percent *float64) string {
+	if percent == nil {
+		return "off"
+	}
+	return strconv.FormatFloat(*percent, 'f', -1, 64)
+}
+
+// NewRamp validates the steps and orders them by time
+func NewRamp(steps []RampStep, now time.Time) ([]RampStep, error) {
+	if len(steps) > maxRampSteps {
+		return nil, ErrTooManySteps
+	}
+	res := make([]RampStep, len(steps))
+	for i, step := range steps {
+		if err := ValidatePercent(step.Percent); err != nil {
+			return nil, err
+		}
+		if !step.At.After(now) {
+			return nil, ErrInvalidRamp
+		}
+		res[i] = RampStep{At: step.At.UTC(), Percent: step.Percent}
+	}
+	slices.SortFunc(res, func(a, b RampStep) int { return a.At.Compare(b.At) })
+	for i := 1; i < len(res); i++ {
+		if res[i].At.Equal(res[i-1].At) {
+			return nil, ErrInvalidRamp
+		}
+	}
+	return res, nil
+}
+
+// NewSalt returns a random salt for a new rollout
+func NewSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Bucket returns the stable position of the user in [0, n): the first 8 bytes of SHA-256 of "key:user_id" modulo n.
+// Low bits of simpler hashes such as FNV repeat between keys, so different keys would split users in the same way
+func Bucket(key string, userID int64, n int) int {
+	sum := sha256.Sum256([]byte(key + ":" + strconv.FormatInt(userID, 10)))
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(n))
+}
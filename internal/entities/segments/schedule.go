@@ -0,0 +1,42 @@
+package segments
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("active_until must be after active_from")
+
+// Schedule limits the time the segment is returned to users. Nil bounds are open
+type Schedule struct {
+	ActiveFrom  *time.Time
+	ActiveUntil *time.Time
+}
+
+func NewSchedule(from *time.Time, until *time.Time) (Schedule, error) {
+	if from != nil && until != nil && !until.After(*from) {
+		return Schedule{}, ErrInvalidSchedule
+	}
+	s := Schedule{}
+	if from != nil {
+		t := from.UTC()
+		s.ActiveFrom = &t
+	}
+	if until != nil {
+		t := until.UTC()
+		s.ActiveUntil = &t
+	}
+	return s, nil
+}
+
+// ActiveAt reports whether the time is within the schedule
+func (s Schedule) ActiveAt(t time.Time) bool {
+	return (s.ActiveFrom == nil || !s.ActiveFrom.After(t)) && (s.ActiveUntil == nil || s.ActiveUntil.After(t))
+}
+
+// Transition is the activation or deactivation of the segment by its schedule
+type Transition struct {
+	Segment  string
+	Activate bool
+	At       time.Time
+}
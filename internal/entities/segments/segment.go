@@ -1,14 +1,64 @@
 package segments
 
-import "errors"
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxDescriptionLen = 1024
+	maxOwnerLen       = 255
+	maxTagLen         = 64
+	maxTags           = 32
+)
 
 var (
-	ErrEmptySlug  = errors.New("slug cannot be empty")
-	ErrSlugToLong = errors.New("slug is too long")
+	ErrEmptySlug          = errors.New("slug cannot be empty")
+	ErrSlugToLong         = errors.New("slug is too long")
+	ErrDescriptionTooLong = errors.New("description is too long")
+	ErrOwnerTooLong       = errors.New("owner is too long")
+	ErrInvalidTag         = errors.New("tag must be non-empty and at most 64 characters")
+	ErrTooManyTags        = errors.New("too many tags")
 )
 
+// Metadata describes the purpose of the segment and does not affect its members
+type Metadata struct {
+	Description string
+	// Owner is the team responsible for the segment
+	Owner string
+	// Tags are unique and sorted
+	Tags []string
+}
+
 type Segment struct {
 	Slug string
+	Metadata
+	Schedule
+	State State
+	// Group is the name of the group of the segment, empty if the segment is not in a group
+	Group string
+	// Parent is the slug of the parent segment, empty for roots of trees
+	Parent string
+	// Implied is set for segments of users who are members of their descendants only
+	Implied bool
+	// Variant is set for segments of experiment variants
+	Variant *VariantOf
+	// Rollout is set for segments with membership by the percent of users
+	Rollout *Rollout
+	// Rule is the rule of attributes of members of the segment, empty for segments with explicit members
+	Rule string
+	// Payload is set for segments giving configuration to their members
+	Payload   *Payload
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// VariantOf names the experiment and the variant the segment belongs to
+type VariantOf struct {
+	Experiment string
+	Variant    string
 }
 
 func New(slug string) (Segment, error) {
@@ -22,3 +72,104 @@ func New(slug string) (Segment, error) {
 		Slug: slug,
 	}, nil
 }
+
+func NewMetadata(description string, owner string, tags []string) (Metadata, error) {
+	if len(description) > maxDescriptionLen {
+		return Metadata{}, ErrDescriptionTooLong
+	}
+	owner = strings.TrimSpace(owner)
+	if len(owner) > maxOwnerLen {
+		return Metadata{}, ErrOwnerTooLong
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Metadata{Description: description, Owner: owner, Tags: tags}, nil
+}
+
+// NormalizeTag returns the tag in the form it is stored in
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if len(tag) == 0 || len(tag) > maxTagLen {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, tag)
+	}
+	slices.Sort(res)
+	res = slices.Compact(res)
+	if len(res) > maxTags {
+		return nil, ErrTooManyTags
+	}
+	return res, nil
+}
+
+// Patch changes metadata fields that are not nil
+type Patch struct {
+	Description *string
+	Owner       *string
+	Tags        *[]string
+}
+
+func NewPatch(description *string, owner *string, tags *[]string) (Patch, error) {
+	var m Metadata
+	if description != nil {
+		m.Description = *description
+	}
+	if owner != nil {
+		m.Owner = *owner
+	}
+	if tags != nil {
+		m.Tags = *tags
+	}
+	m, err := NewMetadata(m.Description, m.Owner, m.Tags)
+	if err != nil {
+		return Patch{}, err
+	}
+	var p Patch
+	if description != nil {
+		p.Description = &m.Description
+	}
+	if owner != nil {
+		p.Owner = &m.Owner
+	}
+	if tags != nil {
+		p.Tags = &m.Tags
+	}
+	return p, nil
+}
+
+// Filter selects segments having the tag, the owner and the state. Empty fields match any segment
+type Filter struct {
+	Tag   string
+	Owner string
+	State *State
+}
+
+func NewFilter(tag string, owner string, state string) (Filter, error) {
+	var err error
+	if tag != "" {
+		if tag, err = NormalizeTag(tag); err != nil {
+			return Filter{}, err
+		}
+	}
+	f := Filter{Tag: tag, Owner: strings.TrimSpace(owner)}
+	if state != "" {
+		st, err := ParseState(state)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.State = &st
+	}
+	return f, nil
+}
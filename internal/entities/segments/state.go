@@ -0,0 +1,65 @@
+package segments
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrUnknownState      = errors.New("unknown segment state")
+	ErrInvalidTransition = errors.New("segment cannot move to this state")
+	ErrArchived          = errors.New("segment is archived")
+	ErrNotArchived       = errors.New("only archived segments can be purged")
+)
+
+// State is the lifecycle state of the segment. Only active segments are returned to users,
+// archived segments also reject new members. Memberships and history are kept in every state
+type State int16
+
+const (
+	Active State = iota
+	Draft
+	Paused
+	Archived
+)
+
+var stateNames = [...]string{Active: "active", Draft: "draft", Paused: "paused", Archived: "archived"}
+
+// transitions lists states the segment may move to from every state. Archived segments return by restoring
+var transitions = map[State][]State{
+	Draft:  {Active, Archived},
+	Active: {Paused, Archived},
+	Paused: {Active, Archived},
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return "unknown"
+	}
+	return stateNames[s]
+}
+
+func ParseState(s string) (State, error) {
+	for i, name := range stateNames {
+		if name == s {
+			return State(i), nil
+		}
+	}
+	return 0, ErrUnknownState
+}
+
+// CanMoveTo reports whether the segment in the state may move to the state to
+func (s State) CanMoveTo(to State) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+// SourcesOf returns states the segment may move to the state from
+func SourcesOf(to State) []State {
+	var res []State
+	for _, from := range []State{Active, Draft, Paused, Archived} {
+		if from.CanMoveTo(to) {
+			res = append(res, from)
+		}
+	}
+	return res
+}
@@ -0,0 +1,12 @@
+package segments
+
+import "errors"
+
+var (
+	// ErrCycle rejects a parent that is the segment itself or one of its descendants
+	ErrCycle = errors.New("segment cannot be a descendant of itself")
+	// ErrHasChildren rejects archiving or purging a segment while it has child segments
+	ErrHasChildren = errors.New("segment has child segments")
+	// ErrParentArchived rejects archived parents and restoring children of archived parents
+	ErrParentArchived = errors.New("parent segment is archived")
+)
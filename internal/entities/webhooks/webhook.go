@@ -0,0 +1,141 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+	"user-segmentation/internal/entities/operations"
+)
+
+var (
+	ErrInvalidURL       = errors.New("url must be absolute http or https url")
+	ErrSecretTooShort   = errors.New("secret must be at least 16 characters")
+	ErrUnknownOperation = errors.New("unknown operation type")
+	ErrUnknownStatus    = errors.New("unknown delivery status")
+)
+
+const (
+	secretPrefix   = "whsec_"
+	secretSize     = 32
+	minSecretLen   = 16
+	maxURLLen      = 2048
+	SignatureAlgo  = "sha256"
+	SignatureLabel = SignatureAlgo + "="
+)
+
+// Webhook subscribes URL to membership changes and changes of segments. Empty Segments or Operations match any value
+type Webhook struct {
+	ID         int64
+	URL        string
+	Secret     string
+	Segments   []string
+	Operations []operations.Type
+	CreatedAt  time.Time
+}
+
+func ParseOperation(s string) (operations.Type, error) {
+	t, err := operations.ParseType(s)
+	if err != nil {
+		return 0, ErrUnknownOperation
+	}
+	return t, nil
+}
+
+// New validates the subscription. If secret is empty, a random one is generated
+func New(rawURL string, secret string, segments []string, ops []string) (Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(rawURL) > maxURLLen || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, ErrInvalidURL
+	}
+	if secret == "" {
+		buf := make([]byte, secretSize)
+		if _, err := rand.Read(buf); err != nil {
+			return Webhook{}, err
+		}
+		secret = secretPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	}
+	if len(secret) < minSecretLen {
+		return Webhook{}, ErrSecretTooShort
+	}
+	types := make([]operations.Type, len(ops))
+	for i := range ops {
+		if types[i], err = ParseOperation(ops[i]); err != nil {
+			return Webhook{}, err
+		}
+	}
+	if segments == nil {
+		segments = []string{}
+	}
+	return Webhook{
+		URL:        rawURL,
+		Secret:     secret,
+		Segments:   segments,
+		Operations: types,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// Sign returns the signature of the delivery: HMAC-SHA256 of "<unix timestamp>.<body>" with the secret.
+// Receivers compute it the same way and reject old timestamps to prevent replays
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return SignatureLabel + hex.EncodeToString(mac.Sum(nil))
+}
+
+type Status int16
+
+const (
+	Pending Status = iota
+	Delivered
+	Dead
+)
+
+var statusNames = map[Status]string{
+	Pending:   "pending",
+	Delivered: "delivered",
+	Dead:      "dead",
+}
+
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+func ParseStatus(s string) (Status, error) {
+	for status, name := range statusNames {
+		if name == s {
+			return status, nil
+		}
+	}
+	return 0, ErrUnknownStatus
+}
+
+// Delivery is the notification about the operation queued for the webhook
+type Delivery struct {
+	ID            int64
+	WebhookID     int64
+	OperationID   int64
+	Payload       []byte
+	Status        Status
+	Attempts      int
+	NextAttemptAt time.Time
+	LastStatus    *int
+	LastError     *string
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+}
+
+// Job is the claimed delivery with the subscription it is sent to
+type Job struct {
+	Delivery
+	URL    string
+	Secret string
+}
@@ -0,0 +1,223 @@
+// Package feed streams committed operations to subscribers
+package feed
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/logger"
+)
+
+const (
+	// Channel is the Postgres channel where the trigger of operations table sends IDs of inserted rows
+	Channel = "operations"
+
+	pageSize      = 1000
+	batchSize     = 500
+	subBufferSize = 256
+	pending       = 4096
+	keepAlive     = 15 * time.Second
+)
+
+var (
+	ErrLagging = errors.New("subscriber is too slow or notifications were lost, resume from the last event")
+	ErrClosed  = errors.New("feed is closed")
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=OperationsRepo
+type OperationsRepo interface {
+	GetByIDs(ctx context.Context, ids []int64) ([]operations.Operation, error)
+	After(ctx context.Context, afterID int64, filter operations.Filter, limit int) ([]operations.Operation, error)
+}
+
+// Sink receives operations of the stream
+type Sink interface {
+	Send(op operations.Operation) error
+	// KeepAlive is called when the stream is idle, so proxies do not close the connection
+	KeepAlive() error
+}
+
+type subscription struct {
+	filter operations.Filter
+	ch     chan operations.Operation
+	err    error
+}
+
+// Feed fans out notifications of committed operations to subscribers.
+// Notifications carry only IDs, operations are loaded once for all subscribers
+type Feed struct {
+	repo   OperationsRepo
+	ids    chan int64
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+func New(repo OperationsRepo) *Feed {
+	return &Feed{
+		repo: repo,
+		ids:  make(chan int64, pending),
+		subs: make(map[*subscription]struct{}),
+	}
+}
+
+// Notify handles the payload of the notification, i.e. ID of the inserted operation
+func (f *Feed) Notify(payload string) {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return
+	}
+	select {
+	case f.ids <- id:
+	default:
+		f.Reset()
+	}
+}
+
+// Reset disconnects all subscribers, e.g. when notifications could be lost. They resume by Last-Event-ID
+func (f *Feed) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		f.drop(sub, ErrLagging)
+	}
+}
+
+// Run loads notified operations and sends them to subscribers until ctx is done
+func (f *Feed) Run(ctx context.Context) error {
+	defer func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.closed = true
+		for sub := range f.subs {
+			f.drop(sub, ErrClosed)
+		}
+	}()
+	batch := make([]int64, 0, batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case id := <-f.ids:
+			batch = append(batch[:0], id)
+		}
+		for len(batch) < batchSize && len(f.ids) > 0 {
+			batch = append(batch, <-f.ids)
+		}
+		ops, err := f.repo.GetByIDs(ctx, batch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			logger.InternalErr(ctx, err, "feed.Run")
+			f.Reset()
+			continue
+		}
+		f.publish(ops)
+	}
+}
+
+func (f *Feed) publish(ops []operations.Operation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		for _, op := range ops {
+			if !sub.filter.Match(op) {
+				continue
+			}
+			select {
+			case sub.ch <- op:
+			default:
+				f.drop(sub, ErrLagging)
+			}
+			if sub.err != nil {
+				break
+			}
+		}
+	}
+}
+
+// drop must be called with mu locked
+func (f *Feed) drop(sub *subscription, err error) {
+	if _, ok := f.subs[sub]; !ok {
+		return
+	}
+	delete(f.subs, sub)
+	sub.err = err
+	close(sub.ch)
+}
+
+func (f *Feed) subscribe(filter operations.Filter) (*subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, ErrClosed
+	}
+	sub := &subscription{filter: filter, ch: make(chan operations.Operation, subBufferSize)}
+	f.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+func (f *Feed) unsubscribe(sub *subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drop(sub, ErrClosed)
+}
+
+// Stream sends operations matching the filter to the sink until ctx is done.
+// If lastID is set, operations after it are sent first. Operations are delivered at least once:
+// IDs of concurrent transactions may commit out of order, so a resumed stream can repeat some of them
+func (f *Feed) Stream(ctx context.Context, filter operations.Filter, lastID int64, sink Sink) error {
+	// subscribe before catching up, so no operation is committed in between unnoticed
+	sub, err := f.subscribe(filter)
+	if err != nil {
+		return err
+	}
+	defer f.unsubscribe(sub)
+
+	sent := make(map[int64]struct{})
+	for lastID > 0 {
+		ops, err := f.repo.After(ctx, lastID, filter, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if err := sink.Send(op); err != nil {
+				return err
+			}
+			sent[op.ID] = struct{}{}
+			lastID = op.ID
+		}
+		if len(ops) < pageSize {
+			break
+		}
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case op, ok := <-sub.ch:
+			if !ok {
+				f.mu.Lock()
+				defer f.mu.Unlock()
+				return sub.err
+			}
+			if _, ok := sent[op.ID]; ok {
+				continue
+			}
+			if err := sink.Send(op); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := sink.KeepAlive(); err != nil {
+				return err
+			}
+		}
+	}
+}
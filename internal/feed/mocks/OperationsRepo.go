@@ -0,0 +1,82 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	operations "user-segmentation/internal/entities/operations"
+)
+
+// OperationsRepo is an autogenerated mock type for the OperationsRepo type
+type OperationsRepo struct {
+	mock.Mock
+}
+
+// After provides a mock function with given fields: ctx, afterID, filter, limit
+func (_m *OperationsRepo) After(ctx context.Context, afterID int64, filter operations.Filter, limit int) ([]operations.Operation, error) {
+	ret := _m.Called(ctx, afterID, filter, limit)
+
+	var r0 []operations.Operation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, operations.Filter, int) ([]operations.Operation, error)); ok {
+		return rf(ctx, afterID, filter, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, operations.Filter, int) []operations.Operation); ok {
+		r0 = rf(ctx, afterID, filter, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]operations.Operation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, operations.Filter, int) error); ok {
+		r1 = rf(ctx, afterID, filter, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetByIDs provides a mock function with given fields: ctx, ids
+func (_m *OperationsRepo) GetByIDs(ctx context.Context, ids []int64) ([]operations.Operation, error) {
+	ret := _m.Called(ctx, ids)
+
+	var r0 []operations.Operation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) ([]operations.Operation, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) []operations.Operation); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]operations.Operation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewOperationsRepo creates a new instance of OperationsRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOperationsRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OperationsRepo {
+	mock := &OperationsRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
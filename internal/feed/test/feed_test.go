@@ -0,0 +1,104 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/feed"
+	"user-segmentation/internal/feed/mocks"
+)
+
+type sink chan operations.Operation
+
+func (s sink) Send(op operations.Operation) error {
+	s <- op
+	return nil
+}
+
+func (s sink) KeepAlive() error {
+	return nil
+}
+
+func (s sink) next(t *testing.T) operations.Operation {
+	select {
+	case op := <-s:
+		return op
+	case <-time.After(time.Second):
+		t.Fatal("no operation received")
+		return operations.Operation{}
+	}
+}
+
+func op(id int64, userID int64, slug string) operations.Operation {
+	return operations.Operation{ID: id, UserID: userID, Segment: segments.Segment{Slug: slug}, Type: operations.Add}
+}
+
+type stream struct {
+	sink sink
+	done chan error
+}
+
+func startStream(ctx context.Context, f *feed.Feed, filter operations.Filter, lastID int64) stream {
+	s := stream{sink: make(sink, 10), done: make(chan error, 1)}
+	go func() { s.done <- f.Stream(ctx, filter, lastID, s.sink) }()
+	return s
+}
+
+func TestFeed_Stream(t *testing.T) {
+	r := mocks.NewOperationsRepo(t)
+	r.
+		On("After", mock.Anything, int64(2), operations.Filter{UserID: 1}, mock.Anything).
+		Return([]operations.Operation{op(4, 1, "slug-1")}, nil)
+	stored := map[int64]operations.Operation{3: op(3, 2, "slug-1"), 4: op(4, 1, "slug-1"), 5: op(5, 1, "slug-2")}
+	r.
+		On("GetByIDs", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, ids []int64) []operations.Operation {
+			res := make([]operations.Operation, 0, len(ids))
+			for _, id := range ids {
+				res = append(res, stored[id])
+			}
+			return res
+		}, nil)
+	f := feed.New(r)
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := make(chan error)
+	go func() { runDone <- f.Run(ctx) }()
+
+	resumed := startStream(ctx, f, operations.Filter{UserID: 1}, 2)
+	require.Equal(t, int64(4), resumed.sink.next(t).ID)
+	all := startStream(ctx, f, operations.Filter{}, 0)
+	// let the stream subscribe
+	time.Sleep(50 * time.Millisecond)
+
+	f.Notify("3")
+	f.Notify("4")
+	f.Notify("5")
+	require.Equal(t, int64(3), all.sink.next(t).ID)
+	require.Equal(t, int64(4), all.sink.next(t).ID)
+	require.Equal(t, int64(5), all.sink.next(t).ID)
+	// 3 is of other user, 4 was sent on resume
+	require.Equal(t, int64(5), resumed.sink.next(t).ID)
+
+	cancel()
+	require.NoError(t, <-runDone)
+	require.NoError(t, <-resumed.done)
+	require.NoError(t, <-all.done)
+}
+
+func TestFeed_Reset(t *testing.T) {
+	f := feed.New(mocks.NewOperationsRepo(t))
+	s := startStream(context.Background(), f, operations.Filter{}, 0)
+	time.Sleep(50 * time.Millisecond)
+
+	f.Reset()
+	select {
+	case err := <-s.done:
+		require.ErrorIs(t, err, feed.ErrLagging)
+	case <-time.After(time.Second):
+		t.Fatal("stream is not closed")
+	}
+}
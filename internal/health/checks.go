@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrDirtySchema = errors.New("last migration failed, schema is dirty")
+	ErrOldSchema   = errors.New("schema is behind the application")
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Ping checks that the database is reachable
+func Ping(db Pinger) Check {
+	return db.Ping
+}
+
+// Migrations checks that golang-migrate applied migrations up to the expected version.
+// Newer schema is accepted, because during rolling updates new replicas migrate the database
+// while old ones still serve requests
+func Migrations(db Querier, expected uint) Check {
+	return func(ctx context.Context) error {
+		var version uint
+		var dirty bool
+		err := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		if err != nil {
+			return fmt.Errorf("cannot get schema version: %w", err)
+		}
+		if dirty {
+			return fmt.Errorf("%w: version %d", ErrDirtySchema, version)
+		}
+		if version < expected {
+			return fmt.Errorf("%w: version %d, expected %d", ErrOldSchema, version, expected)
+		}
+		return nil
+	}
+}
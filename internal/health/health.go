@@ -0,0 +1,130 @@
+// Package health reports liveness and readiness of the application
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK = "ok"
+
+	checkTimeout = 3 * time.Second
+)
+
+var (
+	ErrDraining      = errors.New("server is shutting down")
+	ErrWorkerStopped = errors.New("worker is not running")
+)
+
+type Check func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   Check
+}
+
+// Health collects readiness checks of dependencies and states of background workers
+type Health struct {
+	mu       sync.RWMutex
+	checks   []check
+	workers  map[string]bool
+	draining atomic.Bool
+}
+
+func New() *Health {
+	return &Health{workers: make(map[string]bool)}
+}
+
+// Add registers the readiness check
+func (h *Health) Add(name string, fn Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, check{name: name, fn: fn})
+}
+
+// Worker wraps the background worker, so the application is not ready once the worker returns
+func (h *Health) Worker(name string, run func() error) func() error {
+	h.mu.Lock()
+	h.workers[name] = true
+	h.mu.Unlock()
+	return func() error {
+		defer func() {
+			h.mu.Lock()
+			h.workers[name] = false
+			h.mu.Unlock()
+		}()
+		return run()
+	}
+}
+
+// Drain makes the application not ready, so load balancers stop routing new requests to it
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
+
+// Ready runs all checks concurrently and returns their statuses by name.
+// The error is not nil if any check failed or the application is draining
+func (h *Health) Ready(ctx context.Context) (map[string]string, error) {
+	h.mu.RLock()
+	checks := h.checks
+	statuses := make(map[string]string, len(checks)+len(h.workers)+1)
+	var failed []string
+	for name, running := range h.workers {
+		statuses["worker:"+name] = StatusOK
+		if !running {
+			statuses["worker:"+name] = ErrWorkerStopped.Error()
+			failed = append(failed, "worker:"+name)
+		}
+	}
+	h.mu.RUnlock()
+	if h.Draining() {
+		statuses["shutdown"] = ErrDraining.Error()
+		failed = append(failed, "shutdown")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			errs[i] = c.fn(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+	for i, c := range checks {
+		statuses[c.name] = StatusOK
+		if errs[i] != nil {
+			statuses[c.name] = errs[i].Error()
+			failed = append(failed, c.name)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return statuses, &NotReadyError{Failed: failed}
+	}
+	return statuses, nil
+}
+
+type NotReadyError struct {
+	Failed []string
+}
+
+func (e *NotReadyError) Error() string {
+	msg := "not ready:"
+	for _, name := range e.Failed {
+		msg += " " + name
+	}
+	return msg
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/health"
+	"user-segmentation/migrations"
+)
+
+func TestHealth_Ready(t *testing.T) {
+	h := health.New()
+	dbErr := errors.New("connection refused")
+	var dbDown bool
+	h.Add("postgres", func(ctx context.Context) error {
+		if dbDown {
+			return dbErr
+		}
+		return nil
+	})
+	stop := make(chan struct{})
+	worker := h.Worker("refresh", func() error {
+		<-stop
+		return nil
+	})
+	done := make(chan error)
+	go func() { done <- worker() }()
+
+	checks, err := h.Ready(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"postgres": health.StatusOK, "worker:refresh": health.StatusOK}, checks)
+
+	dbDown = true
+	checks, err = h.Ready(context.Background())
+	var notReady *health.NotReadyError
+	require.ErrorAs(t, err, &notReady)
+	require.Equal(t, []string{"postgres"}, notReady.Failed)
+	require.Equal(t, dbErr.Error(), checks["postgres"])
+
+	dbDown = false
+	close(stop)
+	require.NoError(t, <-done)
+	_, err = h.Ready(context.Background())
+	require.ErrorAs(t, err, &notReady)
+	require.Equal(t, []string{"worker:refresh"}, notReady.Failed)
+}
+
+func TestHealth_Drain(t *testing.T) {
+	h := health.New()
+	_, err := h.Ready(context.Background())
+	require.NoError(t, err)
+
+	h.Drain()
+	checks, err := h.Ready(context.Background())
+	require.Error(t, err)
+	require.Equal(t, health.ErrDraining.Error(), checks["shutdown"])
+}
+
+type row struct {
+	version uint
+	dirty   bool
+}
+
+func (r row) Scan(dest ...any) error {
+	*dest[0].(*uint) = r.version
+	*dest[1].(*bool) = r.dirty
+	return nil
+}
+
+type db struct {
+	row row
+}
+
+func (d db) QueryRow(context.Context, string, ...any) pgx.Row {
+	return d.row
+}
+
+func TestMigrations(t *testing.T) {
+	expected, err := migrations.Version()
+	require.NoError(t, err)
+	require.NotZero(t, expected)
+
+	tests := []struct {
+		name    string
+		row     row
+		wantErr error
+	}{
+		{name: "expected", row: row{version: expected}},
+		{name: "newer", row: row{version: expected + 1}},
+		{name: "older", row: row{version: expected - 1}, wantErr: health.ErrOldSchema},
+		{name: "dirty", row: row{version: expected, dirty: true}, wantErr: health.ErrDirtySchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := health.Migrations(db{row: tt.row}, expected)(context.Background())
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
@@ -2,7 +2,10 @@ package logger
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 	"net/http"
 	"os"
@@ -10,48 +13,133 @@ import (
 	"user-segmentation/internal/config"
 )
 
+// traceHandler adds ids of the current trace and span to records logged with context
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
 func Create(env string) *slog.Logger {
 	var log *slog.Logger
 	switch env {
 	case config.EnvDebug:
-		log = slog.New(
+		log = slog.New(traceHandler{
 			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 				Level: slog.LevelDebug,
 			}),
-		)
+		})
 	case config.EnvRelease:
 		fallthrough
 	default:
-		log = slog.New(
+		log = slog.New(traceHandler{
 			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 				Level: slog.LevelInfo,
 			}),
-		)
+		})
 	}
 	return log
 }
 
+type ctxKey int
+
+const (
+	logKey ctxKey = iota
+	requestIDKey
+)
+
+// RequestIDHeader is used to pass the request ID between services and to return it to the client
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen limits IDs coming from clients, longer IDs are replaced
+const maxRequestIDLen = 128
+
+func WithLogger(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, logKey, log)
+}
+
+// Log returns the request-scoped logger. Outside requests (e.g. in workers) it returns slog.Default()
 func Log(ctx context.Context) *slog.Logger {
-	log, ok := ctx.Value("log").(*slog.Logger)
-	if !ok {
-		panic("logger has not been set")
+	if log, ok := ctx.Value(logKey).(*slog.Logger); ok {
+		return log
 	}
-	return log
+	return slog.Default()
 }
 
 func InternalErr(ctx context.Context, err error, fn string) {
-	Log(ctx).Error(err.Error(), slog.String("fn", fn))
+	Log(ctx).ErrorContext(ctx, err.Error(), slog.String("fn", fn))
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns ID of the current request or empty string outside requests
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func NewRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// ValidRequestID reports whether the ID received from the client may be logged and passed further
+func ValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// StartRequest takes the request ID passed by the client or generates a new one,
+// and stores it in ctx with the logger including the ID
+func StartRequest(ctx context.Context, log *slog.Logger, id string) (context.Context, string) {
+	if !ValidRequestID(id) {
+		id = NewRequestID()
+	}
+	ctx = WithRequestID(ctx, id)
+	return WithLogger(ctx, log.With(slog.String("request_id", id))), id
 }
 
-type MiddlewareFunc func(req *http.Request, ctxStore func(key string, value any), handle func())
+// MiddlewareFunc prepares the request context and logs handled requests.
+// setCtx replaces the context of the request, handle processes the request and returns its final context,
+// so middlewares below may replace the logger (e.g. to add the caller identity) and the record will contain their attributes
+type MiddlewareFunc func(req *http.Request, header http.Header, setCtx func(ctx context.Context), handle func() context.Context)
 
 func Middleware(log *slog.Logger) MiddlewareFunc {
 	log.Info("logger middleware enabled")
-	return func(req *http.Request, ctxStore func(key string, value any), handle func()) {
+	return func(req *http.Request, header http.Header, setCtx func(ctx context.Context), handle func() context.Context) {
 		start := time.Now()
-		ctxStore("log", log)
-		handle()
-		log.Info(
+		ctx, id := StartRequest(req.Context(), log, req.Header.Get(RequestIDHeader))
+		header.Set(RequestIDHeader, id)
+		setCtx(ctx)
+		ctx = handle()
+		Log(ctx).InfoContext(
+			ctx,
 			"request handled",
 			slog.String("path", req.URL.Path),
 			slog.String("query", req.URL.RawQuery),
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+	"user-segmentation/internal/logger"
+)
+
+var segmentMembers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "segment_members",
+	Help: "Users in the segment, refreshed periodically",
+}, []string{"segment"})
+
+type MembersCounter interface {
+	CountMembers(ctx context.Context) (map[string]int64, error)
+}
+
+func refreshMembers(ctx context.Context, counter MembersCounter) {
+	counts, err := counter.CountMembers(ctx)
+	if err != nil {
+		logger.InternalErr(ctx, err, "metrics.refreshMembers")
+		return
+	}
+	// deleted segments must disappear from the metric
+	segmentMembers.Reset()
+	for slug, n := range counts {
+		segmentMembers.WithLabelValues(slug).Set(float64(n))
+	}
+}
+
+// RefreshMembers updates member counts of segments every interval until ctx is done
+func RefreshMembers(ctx context.Context, counter MembersCounter, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		refreshMembers(ctx, counter)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Handled HTTP requests by method, route and status",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by method, route and status",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Latency of database queries by repository function",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"fn"})
+	membershipChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "segment_membership_changes_total",
+		Help: "Requested changes of user segments by operation (add or remove) and outcome (applied, rejected or failed)",
+	}, []string{"operation", "outcome"})
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Lookups in in-process caches by cache and result (hit or miss)",
+	}, []string{"cache", "result"})
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_delivery_attempts_total",
+		Help: "Attempts to send webhook deliveries by result (delivered, failed or dead)",
+	}, []string{"result"})
+	outboxPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_events_published_total",
+		Help: "Attempts to publish outbox events by outcome (applied or failed)",
+	}, []string{"outcome"})
+)
+
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	DeliveryDead      = "dead"
+)
+
+const (
+	OutcomeApplied  = "applied"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+)
+
+// ObserveRequest records the handled HTTP request. Route is the pattern, not the actual path, to keep cardinality low
+func ObserveRequest(method string, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveQuery records latency of the repository function. Use it as defer metrics.ObserveQuery(fn, time.Now())
+func ObserveQuery(fn string, start time.Time) {
+	dbDuration.WithLabelValues(fn).Observe(time.Since(start).Seconds())
+}
+
+// CountChanges records outcome of adding and removing user segments
+func CountChanges(add int, remove int, outcome string) {
+	membershipChanges.WithLabelValues("add", outcome).Add(float64(add))
+	membershipChanges.WithLabelValues("remove", outcome).Add(float64(remove))
+}
+
+// CountCache records the lookup in the cache
+func CountCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// CountDelivery records the attempt to send the webhook delivery
+func CountDelivery(result string) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+}
+
+// CountPublished records the attempt to publish the outbox event
+func CountPublished(outcome string) {
+	outboxPublished.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exposes statistics of the database connection pool
+type PoolCollector struct {
+	pool            *pgxpool.Pool
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	acquires        *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	canceled        *prometheus.Desc
+	acquireDuration *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	return &PoolCollector{
+		pool:            pool,
+		acquired:        prometheus.NewDesc("db_pool_acquired_conns", "Connections currently in use", nil, nil),
+		idle:            prometheus.NewDesc("db_pool_idle_conns", "Idle connections", nil, nil),
+		total:           prometheus.NewDesc("db_pool_total_conns", "All open connections", nil, nil),
+		max:             prometheus.NewDesc("db_pool_max_conns", "Maximum size of the pool", nil, nil),
+		acquires:        prometheus.NewDesc("db_pool_acquires_total", "Successful acquires of connections", nil, nil),
+		emptyAcquires:   prometheus.NewDesc("db_pool_empty_acquires_total", "Acquires which waited for a connection", nil, nil),
+		canceled:        prometheus.NewDesc("db_pool_canceled_acquires_total", "Acquires canceled by context", nil, nil),
+		acquireDuration: prometheus.NewDesc("db_pool_acquire_duration_seconds_total", "Time spent on acquiring connections", nil, nil),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"user-segmentation/internal/entities/outbox"
+)
+
+const (
+	PublisherNone   = "none"
+	PublisherStdout = "stdout"
+	PublisherFile   = "file"
+	PublisherHTTP   = "http"
+
+	// HeaderIdempotencyKey carries Event.Key in requests of HTTPPublisher
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderTopic          = "X-Event-Topic"
+)
+
+var ErrUnknownPublisher = errors.New("unknown outbox publisher")
+
+// message is the envelope of the event written by stream publishers
+type message struct {
+	Key       string          `json:"key"`
+	Topic     string          `json:"topic"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func toMessage(e outbox.Event) message {
+	return message{Key: e.Key, Topic: e.Topic, Payload: e.Payload, CreatedAt: e.CreatedAt}
+}
+
+// WriterPublisher writes events as JSON lines, e.g. to stdout
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (p *WriterPublisher) Publish(_ context.Context, e outbox.Event) error {
+	line, err := json.Marshal(toMessage(e))
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// FilePublisher appends events as JSON lines to the file and syncs it after every event
+type FilePublisher struct {
+	WriterPublisher
+	f *os.File
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, e outbox.Event) error {
+	if err := p.WriterPublisher.Publish(ctx, e); err != nil {
+		return err
+	}
+	return p.f.Sync()
+}
+
+func (p *FilePublisher) Close() error {
+	return p.f.Close()
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{WriterPublisher: WriterPublisher{w: f}, f: f}, nil
+}
+
+// HTTPPublisher posts the payload of every event to the URL, e.g. to a bus gateway.
+// Any 2xx status means the event is accepted
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p HTTPPublisher) Publish(ctx context.Context, e outbox.Event) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(e.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderIdempotencyKey, e.Key)
+	req.Header.Set(HeaderTopic, e.Topic)
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package outbox publishes events written to the outbox in transactions of the changes
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"user-segmentation/internal/entities/outbox"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+)
+
+const maxErrorLen = 1024
+
+// Publisher sends events to the message bus. Events may be published more than once,
+// so the bus or consumers deduplicate them by Event.Key
+type Publisher interface {
+	Publish(ctx context.Context, e outbox.Event) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=Repo
+type Repo interface {
+	Pending(ctx context.Context, limit int) ([]outbox.Event, error)
+	MarkSent(ctx context.Context, ids []int64) error
+	MarkFailed(ctx context.Context, id int64, reason string) error
+}
+
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Relay publishes events in the order of writing. Events are locked while they are published,
+// so relays of several replicas do not publish the same event concurrently
+type Relay struct {
+	Repo      Repo
+	Tx        Transactor
+	Publisher Publisher
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Run publishes pending events every interval until ctx is done
+func (r Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			n, err := r.relay(ctx)
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes one batch and returns the number of published events.
+// Publishing stops at the first failure, so the order of events is kept
+func (r Relay) relay(ctx context.Context) (int, error) {
+	const fn = "outbox.Relay.relay"
+	var sent []int64
+	var failed error
+	err := r.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		events, err := r.Repo.Pending(ctx, r.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if failed = r.Publisher.Publish(ctx, e); failed != nil {
+				metrics.CountPublished(metrics.OutcomeFailed)
+				reason := failed.Error()
+				if len(reason) > maxErrorLen {
+					reason = reason[:maxErrorLen]
+				}
+				logger.Log(ctx).WarnContext(ctx, "cannot publish event", slog.String("key", e.Key), slog.String("error", reason))
+				if err := r.Repo.MarkFailed(ctx, e.ID, reason); err != nil {
+					return err
+				}
+				break
+			}
+			metrics.CountPublished(metrics.OutcomeApplied)
+			sent = append(sent, e.ID)
+		}
+		if len(sent) == 0 {
+			return nil
+		}
+		return r.Repo.MarkSent(ctx, sent)
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.InternalErr(ctx, err, fn)
+		}
+		// the transaction is rolled back, so sent events are published again
+		return 0, err
+	}
+	return len(sent), failed
+}
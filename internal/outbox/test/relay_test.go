@@ -0,0 +1,103 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	entities "user-segmentation/internal/entities/outbox"
+	"user-segmentation/internal/outbox"
+	"user-segmentation/internal/outbox/mocks"
+)
+
+type transactor struct{}
+
+func (transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// publisher fails on the event with the key
+type publisher struct {
+	failOn    string
+	published []string
+}
+
+func (p *publisher) Publish(_ context.Context, e entities.Event) error {
+	if e.Key == p.failOn {
+		return errors.New("bus is unavailable")
+	}
+	p.published = append(p.published, e.Key)
+	return nil
+}
+
+func event(id int64, key string) entities.Event {
+	return entities.Event{ID: id, Key: key, Topic: entities.TopicMembership, Payload: []byte(`{"user_id":1}`)}
+}
+
+func TestRelay_StopsAtFailure(t *testing.T) {
+	r := mocks.NewRepo(t)
+	r.
+		On("Pending", mock.Anything, 10).
+		Return([]entities.Event{event(1, "a"), event(2, "b"), event(3, "c")}, nil).
+		Once()
+	r.On("MarkSent", mock.Anything, []int64{1}).Return(nil).Once()
+	r.On("MarkFailed", mock.Anything, int64(2), "bus is unavailable").Return(nil).Once()
+	p := &publisher{failOn: "b"}
+	relay := outbox.Relay{Repo: r, Tx: transactor{}, Publisher: p, Interval: time.Hour, BatchSize: 10}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, relay.Run(ctx))
+	require.Equal(t, []string{"a"}, p.published, "events after the failed one wait to keep the order")
+}
+
+func TestRelay_Batches(t *testing.T) {
+	r := mocks.NewRepo(t)
+	r.On("Pending", mock.Anything, 2).Return([]entities.Event{event(1, "a"), event(2, "b")}, nil).Once()
+	r.On("Pending", mock.Anything, 2).Return([]entities.Event{event(3, "c")}, nil).Once()
+	r.On("MarkSent", mock.Anything, []int64{1, 2}).Return(nil).Once()
+	r.On("MarkSent", mock.Anything, []int64{3}).Return(nil).Once()
+	p := &publisher{}
+	relay := outbox.Relay{Repo: r, Tx: transactor{}, Publisher: p, Interval: time.Hour, BatchSize: 2}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, relay.Run(ctx))
+	require.Equal(t, []string{"a", "b", "c"}, p.published)
+}
+
+func TestPublishers(t *testing.T) {
+	e := event(1, "key-1")
+	var buf bytes.Buffer
+	require.NoError(t, outbox.NewWriterPublisher(&buf).Publish(context.Background(), e))
+	var msg map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &msg))
+	require.Equal(t, "key-1", msg["key"])
+	require.Equal(t, entities.TopicMembership, msg["topic"])
+	require.Equal(t, map[string]any{"user_id": float64(1)}, msg["payload"])
+
+	status := http.StatusAccepted
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	p := outbox.HTTPPublisher{URL: srv.URL, Client: srv.Client()}
+	require.NoError(t, p.Publish(context.Background(), e))
+	require.Equal(t, "key-1", got.Header.Get(outbox.HeaderIdempotencyKey))
+	require.Equal(t, entities.TopicMembership, got.Header.Get(outbox.HeaderTopic))
+	require.JSONEq(t, `{"user_id":1}`, string(body))
+
+	status = http.StatusServiceUnavailable
+	require.Error(t, p.Publish(context.Background(), e))
+}
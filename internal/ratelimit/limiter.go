@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// idleTTL is the time after which buckets of inactive clients are forgotten. Forgotten bucket is full anyway
+const idleTTL = 10 * time.Minute
+
+var requests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ratelimit_requests_total",
+	Help: "Requests checked by the rate limiter by budget and result (allowed or limited)",
+}, []string{"budget", "result"})
+
+type client struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// Limiter is a set of token buckets, one per client
+type Limiter struct {
+	name        string
+	limit       rate.Limit
+	burst       int
+	mu          sync.Mutex
+	clients     map[string]*client
+	clientsDesc *prometheus.Desc
+}
+
+// New creates the limiter allowing rps requests per second with bursts up to burst requests for every client
+func New(name string, rps float64, burst int) *Limiter {
+	return &Limiter{
+		name:    name,
+		limit:   rate.Limit(rps),
+		burst:   burst,
+		clients: make(map[string]*client),
+		clientsDesc: prometheus.NewDesc(
+			"ratelimit_clients",
+			"Clients having a token bucket in the rate limiter",
+			nil, prometheus.Labels{"budget": name},
+		),
+	}
+}
+
+// Allow takes a token from the client's bucket. If the bucket is empty, it returns the time to wait for the token
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[key] = c
+	}
+	c.seen = now
+	l.mu.Unlock()
+
+	r := c.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		requests.WithLabelValues(l.name, "limited").Inc()
+		return false, idleTTL
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		requests.WithLabelValues(l.name, "limited").Inc()
+		return false, delay
+	}
+	requests.WithLabelValues(l.name, "allowed").Inc()
+	return true, 0
+}
+
+func (l *Limiter) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, c := range l.clients {
+		if now.Sub(c.seen) > idleTTL {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// Run forgets inactive clients until ctx is done
+func (l *Limiter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(idleTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			l.cleanup(now)
+		}
+	}
+}
+
+func (l *Limiter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.clientsDesc
+}
+
+func (l *Limiter) Collect(ch chan<- prometheus.Metric) {
+	l.mu.Lock()
+	n := len(l.clients)
+	l.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(l.clientsDesc, prometheus.GaugeValue, float64(n))
+}
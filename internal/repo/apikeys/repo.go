@@ -0,0 +1,88 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+	"user-segmentation/internal/entities/apikeys"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+func (r Repo) Store(ctx context.Context, key apikeys.APIKey) (int64, error) {
+	const fn = "repo.apikeys.Store"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `INSERT INTO api_keys (name, role, prefix, key_hash, created_at) VALUES ($1, $2, $3, $4, $5)
+                   RETURNING id`
+	var id int64
+	err := r.db.QueryRow(ctx, query, key.Name, key.Role, key.Prefix, key.Hash, key.CreatedAt).Scan(&id)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return id, err
+}
+
+func (r Repo) List(ctx context.Context) ([]apikeys.APIKey, error) {
+	const fn = "repo.apikeys.List"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT id, name, role, prefix, created_at, revoked_at FROM api_keys ORDER BY id"
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]apikeys.APIKey, 0)
+	for rows.Next() {
+		var key apikeys.APIKey
+		err := rows.Scan(&key.ID, &key.Name, &key.Role, &key.Prefix, &key.CreatedAt, &key.RevokedAt)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, key)
+	}
+	return res, nil
+}
+
+func (r Repo) Revoke(ctx context.Context, id int64) error {
+	const fn = "repo.apikeys.Revoke"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "UPDATE api_keys SET revoked_at=$2 WHERE id=$1 AND revoked_at IS NULL"
+	cmd, err := r.db.Exec(ctx, query, id, time.Now().UTC())
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repo.ErrKeyNotFound
+	}
+	return nil
+}
+
+func (r Repo) GetByHash(ctx context.Context, hash []byte) (apikeys.APIKey, error) {
+	const fn = "repo.apikeys.GetByHash"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT id, name, role, prefix, created_at FROM api_keys 
+                   WHERE key_hash=$1 AND revoked_at IS NULL`
+	key := apikeys.APIKey{Hash: hash}
+	err := r.db.QueryRow(ctx, query, hash).Scan(&key.ID, &key.Name, &key.Role, &key.Prefix, &key.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apikeys.APIKey{}, repo.ErrKeyNotFound
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return key, err
+}
+
+func New(db *pgxpool.Pool) Repo {
+	return Repo{db: db}
+}
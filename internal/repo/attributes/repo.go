@@ -0,0 +1,167 @@
+package attributes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
+	"time"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+// Upsert applies the changes to attributes of the users, nil values remove attributes. With replace the changes
+// replace all attributes of the users. Every changed attribute is recorded to the attribute history.
+// It returns the resulting attributes of the users
+func (r Repo) Upsert(ctx context.Context, changes map[int64]attributes.Attributes, replace bool) (map[int64]attributes.Attributes, error) {
+	const fn = "repo.attributes.Upsert"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const (
+		createQuery = `INSERT INTO user_attributes (user_id, attributes) SELECT unnest($1::BIGINT[]), '{}'
+                       ON CONFLICT (user_id) DO NOTHING`
+		// users are locked in the order of their IDs, so concurrent upserts do not deadlock
+		lockQuery = `SELECT user_id, attributes FROM user_attributes WHERE user_id = ANY($1)
+                     ORDER BY user_id FOR UPDATE`
+		updateQuery  = "UPDATE user_attributes SET attributes=$2, updated_at=now() WHERE user_id=$1"
+		historyQuery = `INSERT INTO user_attribute_history (user_id, name, old_value, new_value)
+                        SELECT $1::BIGINT, unnest($2::TEXT[]), unnest($3::TEXT[])::JSONB, unnest($4::TEXT[])::JSONB`
+	)
+	ids := make([]int64, 0, len(changes))
+	for id := range changes {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	res := make(map[int64]attributes.Attributes, len(changes))
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		if _, err := db.Exec(ctx, createQuery, ids); err != nil {
+			return err
+		}
+		rows, err := db.Query(ctx, lockQuery, ids)
+		if err != nil {
+			return err
+		}
+		current := make(map[int64]attributes.Attributes, len(ids))
+		for rows.Next() {
+			var id int64
+			var attrs map[string]any
+			if err := rows.Scan(&id, &attrs); err != nil {
+				rows.Close()
+				return err
+			}
+			current[id] = attrs
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			old := current[id]
+			base := old
+			if replace {
+				base = nil
+			}
+			attrs, err := attributes.Merge(base, changes[id])
+			if err != nil {
+				return err
+			}
+			res[id] = attrs
+			diff := attributes.Diff(id, old, attrs)
+			if len(diff) == 0 {
+				continue
+			}
+			if _, err := db.Exec(ctx, updateQuery, id, attrs); err != nil {
+				return err
+			}
+			names := make([]string, len(diff))
+			olds, news := make([]*string, len(diff)), make([]*string, len(diff))
+			for i, ch := range diff {
+				names[i] = ch.Name
+				if olds[i], err = jsonValue(ch.Old); err != nil {
+					return err
+				}
+				if news[i], err = jsonValue(ch.New); err != nil {
+					return err
+				}
+			}
+			if _, err := db.Exec(ctx, historyQuery, id, names, olds, news); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, attributes.ErrTooManyValues) {
+			return nil, err
+		}
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	return res, nil
+}
+
+// jsonValue encodes the value of the attribute for the history, nil is NULL
+func jsonValue(v any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
+
+// Get returns the attributes of the user, users without attributes have none
+func (r Repo) Get(ctx context.Context, userID int64) (attributes.Attributes, error) {
+	const fn = "repo.attributes.Get"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT attributes FROM user_attributes WHERE user_id=$1"
+	var res map[string]any
+	err := repo.Conn(ctx, r.db).QueryRow(ctx, query, userID).Scan(&res)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return attributes.Attributes{}, nil
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	return res, nil
+}
+
+// History returns the latest changes of attributes of the user, newest first
+func (r Repo) History(ctx context.Context, userID int64, limit int) ([]attributes.Change, error) {
+	const fn = "repo.attributes.History"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT name, old_value, new_value, changed_at FROM user_attribute_history
+                   WHERE user_id=$1 ORDER BY id DESC LIMIT $2`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, userID, limit)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]attributes.Change, 0)
+	for rows.Next() {
+		ch := attributes.Change{UserID: userID}
+		if err := rows.Scan(&ch.Name, &ch.Old, &ch.New, &ch.Time); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, ch)
+	}
+	return res, rows.Err()
+}
+
+func New(db *pgxpool.Pool) Repo {
+	return Repo{db: db}
+}
@@ -0,0 +1,87 @@
+package attributes
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+const constrDefinitionExists = "attribute_definitions_pkey"
+
+func (r Repo) Define(ctx context.Context, d attributes.Definition) (attributes.Definition, error) {
+	const fn = "repo.attributes.Define"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "INSERT INTO attribute_definitions (name, type, description) VALUES ($1, $2, $3) RETURNING created_at"
+	err := repo.Conn(ctx, r.db).QueryRow(ctx, query, d.Name, d.Type, d.Description).Scan(&d.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == constrDefinitionExists {
+			return attributes.Definition{}, repo.ErrAttributeAlreadyExists
+		}
+		logger.InternalErr(ctx, err, fn)
+		return attributes.Definition{}, err
+	}
+	return d, nil
+}
+
+// Definitions returns the schema of attributes ordered by name
+func (r Repo) Definitions(ctx context.Context) ([]attributes.Definition, error) {
+	const fn = "repo.attributes.Definitions"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT name, type, description, created_at FROM attribute_definitions ORDER BY name"
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]attributes.Definition, 0)
+	for rows.Next() {
+		var d attributes.Definition
+		if err := rows.Scan(&d.Name, &d.Type, &d.Description, &d.CreatedAt); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+// DeleteDefinition removes the attribute from the schema unless it is set for any user
+func (r Repo) DeleteDefinition(ctx context.Context, name string) error {
+	const fn = "repo.attributes.DeleteDefinition"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const (
+		lockQuery   = "SELECT name FROM attribute_definitions WHERE name=$1 FOR UPDATE"
+		usedQuery   = "SELECT EXISTS (SELECT 1 FROM user_attributes WHERE attributes ? $1)"
+		deleteQuery = "DELETE FROM attribute_definitions WHERE name=$1"
+	)
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		if err := db.QueryRow(ctx, lockQuery, name).Scan(&name); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return repo.ErrAttributeNotFound
+			}
+			return err
+		}
+		var used bool
+		if err := db.QueryRow(ctx, usedQuery, name).Scan(&used); err != nil {
+			return err
+		}
+		if used {
+			return attributes.ErrInUse
+		}
+		_, err := db.Exec(ctx, deleteQuery, name)
+		return err
+	})
+	if err != nil && !errors.Is(err, repo.ErrAttributeNotFound) && !errors.Is(err, attributes.ErrInUse) {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
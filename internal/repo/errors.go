@@ -3,9 +3,21 @@ package repo
 import "errors"
 
 var (
-	ErrSegmentAlreadyExists = errors.New("segment already exists")
-	ErrSegmentNotFound      = errors.New("segment not found")
-	ErrRelationNotFound     = errors.New("user is not in this segment")
-	ErrNoSegments           = errors.New("users not found")
-	ErrRelationExists       = errors.New("relation already exists")
+	ErrSegmentAlreadyExists   = errors.New("segment already exists")
+	ErrSegmentNotFound        = errors.New("segment not found")
+	ErrRelationNotFound       = errors.New("user is not in this segment")
+	ErrNoSegments             = errors.New("users not found")
+	ErrRelationExists         = errors.New("relation already exists")
+	ErrKeyNotFound            = errors.New("api key not found")
+	ErrWebhookNotFound        = errors.New("webhook not found")
+	ErrDeliveryNotFound       = errors.New("delivery not found")
+	ErrExperimentNotFound     = errors.New("experiment not found")
+	ErrGroupNotFound          = errors.New("group not found")
+	ErrGroupAlreadyExists     = errors.New("group already exists")
+	ErrAttributeNotFound      = errors.New("attribute not found")
+	ErrAttributeAlreadyExists = errors.New("attribute already exists")
+	ErrOverrideNotFound       = errors.New("flag override not found")
+	ErrParentNotFound         = errors.New("parent segment not found")
+	// ErrExperimentAlreadyExists is also returned when a segment of the variants belongs to another experiment
+	ErrExperimentAlreadyExists = errors.New("experiment already exists")
 )
@@ -0,0 +1,132 @@
+package experiments
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+const (
+	constrKey       = "experiments_key_key"
+	constrSegmentID = "experiment_variants_segment_id_key"
+)
+
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+// Store saves the experiment. Segments of its variants must be stored in the same transaction before
+func (r Repo) Store(ctx context.Context, exp experiments.Experiment) error {
+	const fn = "repo.experiments.Store"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `INSERT INTO experiments (key, description) VALUES ($1, $2) RETURNING id`
+	const variantQuery = `INSERT INTO experiment_variants (experiment_id, position, name, weight, segment_id)
+                          VALUES ($1, $2, $3, $4, resolve_segment($5))`
+	conn := repo.Conn(ctx, r.db)
+	var id int64
+	if err := conn.QueryRow(ctx, query, exp.Key, exp.Description).Scan(&id); err != nil {
+		return storeErr(ctx, err, fn)
+	}
+	batch := &pgx.Batch{}
+	for i, v := range exp.Variants {
+		batch.Queue(variantQuery, id, i, v.Name, v.Weight, v.Segment.Slug)
+	}
+	br := conn.SendBatch(ctx, batch)
+	defer func(br pgx.BatchResults) {
+		_ = br.Close()
+	}(br)
+	for range exp.Variants {
+		if _, err := br.Exec(); err != nil {
+			return storeErr(ctx, err, fn)
+		}
+	}
+	return nil
+}
+
+func storeErr(ctx context.Context, err error, fn string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.ConstraintName == constrKey || pgErr.ConstraintName == constrSegmentID) {
+		return repo.ErrExperimentAlreadyExists
+	}
+	logger.InternalErr(ctx, err, fn)
+	return err
+}
+
+func (r Repo) Get(ctx context.Context, key string) (experiments.Experiment, error) {
+	res, _, err := r.load(ctx, "repo.experiments.Get", key, nil)
+	if err != nil {
+		return experiments.Experiment{}, err
+	}
+	if len(res) == 0 {
+		return experiments.Experiment{}, repo.ErrExperimentNotFound
+	}
+	return res[0], nil
+}
+
+// List returns experiments ordered by key
+func (r Repo) List(ctx context.Context) ([]experiments.Experiment, error) {
+	res, _, err := r.load(ctx, "repo.experiments.List", "", nil)
+	return res, err
+}
+
+// ForUser returns experiments ordered by key and variants the user is assigned to by experiment keys.
+// Membership is checked in segments of any state
+func (r Repo) ForUser(ctx context.Context, userID int64) ([]experiments.Experiment, map[string]string, error) {
+	return r.load(ctx, "repo.experiments.ForUser", "", &userID)
+}
+
+func (r Repo) load(ctx context.Context, fn string, key string, userID *int64) ([]experiments.Experiment, map[string]string, error) {
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT e.key, e.description, e.created_at, v.name, v.weight, s.slug, s.state,
+                       $2::BIGINT IS NOT NULL AND EXISTS (SELECT 1 FROM user_segments
+                                                          WHERE user_id=$2 AND segment_id=v.segment_id)
+                   FROM experiments e
+                   JOIN experiment_variants v ON v.experiment_id = e.id
+                   JOIN segments s ON s.id = v.segment_id
+                   WHERE $1::TEXT = '' OR e.key = $1
+                   ORDER BY e.key, v.position`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, key, userID)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, nil, err
+	}
+	defer rows.Close()
+	res := make([]experiments.Experiment, 0)
+	assigned := make(map[string]string)
+	for rows.Next() {
+		var exp experiments.Experiment
+		var v experiments.Variant
+		var member bool
+		err := rows.Scan(&exp.Key, &exp.Description, &exp.CreatedAt, &v.Name, &v.Weight,
+			&v.Segment.Slug, &v.Segment.State, &member)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, nil, err
+		}
+		if len(res) == 0 || res[len(res)-1].Key != exp.Key {
+			res = append(res, exp)
+		}
+		last := &res[len(res)-1]
+		last.Variants = append(last.Variants, v)
+		// a user added to several variants by hand stays in the first one
+		if _, ok := assigned[exp.Key]; member && !ok {
+			assigned[exp.Key] = v.Name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, nil, err
+	}
+	return res, assigned, nil
+}
+
+func New(db *pgxpool.Pool) Repo {
+	return Repo{db}
+}
@@ -0,0 +1,105 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+	"user-segmentation/internal/entities/flags"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+// SetOverride sets the value of the flag for the user
+func (r Repo) SetOverride(ctx context.Context, userID int64, key string, value any) error {
+	const fn = "repo.flags.SetOverride"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `INSERT INTO flag_overrides (user_id, key, value) VALUES ($1, $2, $3)
+                   ON CONFLICT (user_id, key) DO UPDATE SET value=excluded.value, updated_at=now()`
+	// strings are passed to JSONB as they are, so the value is encoded beforehand
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, err := repo.Conn(ctx, r.db).Exec(ctx, query, userID, key, b); err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	return nil
+}
+
+func (r Repo) DeleteOverride(ctx context.Context, userID int64, key string) error {
+	const fn = "repo.flags.DeleteOverride"
+	defer metrics.ObserveQuery(fn, time.Now())
+	cmd, err := repo.Conn(ctx, r.db).Exec(ctx, "DELETE FROM flag_overrides WHERE user_id=$1 AND key=$2", userID, key)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repo.ErrOverrideNotFound
+	}
+	return nil
+}
+
+// Overrides returns the values of the flags with the keys set for the user
+func (r Repo) Overrides(ctx context.Context, userID int64, keys []string) (map[string]any, error) {
+	const fn = "repo.flags.Overrides"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT key, value FROM flag_overrides WHERE user_id=$1 AND key = ANY($2)"
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, userID, keys)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[string]any)
+	for rows.Next() {
+		var key string
+		var value any
+		if err := rows.Scan(&key, &value); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res[key] = value
+	}
+	return res, rows.Err()
+}
+
+// Known returns the sources of the keys matching experiments and not archived segments.
+// Experiments win over segments with the same slug
+func (r Repo) Known(ctx context.Context, keys []string) (map[string]flags.Source, error) {
+	const fn = "repo.flags.Known"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT slug, 'segment' FROM segments WHERE slug = ANY($1) AND state <> $2
+                   UNION ALL
+                   SELECT key, 'experiment' FROM experiments WHERE key = ANY($1)`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, keys, segments.Archived)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[string]flags.Source)
+	for rows.Next() {
+		var key, source string
+		if err := rows.Scan(&key, &source); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		if res[key] != flags.SourceExperiment {
+			res[key] = flags.Source(source)
+		}
+	}
+	return res, rows.Err()
+}
+
+func New(db *pgxpool.Pool) Repo {
+	return Repo{db: db}
+}
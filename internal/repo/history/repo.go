@@ -5,27 +5,28 @@ import (
 	"errors"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"time"
 	"user-segmentation/internal/entities/operations"
 	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
 	"user-segmentation/internal/repo"
 )
 
 const constrSegmentID = "operations_segment_id_key"
 
 type Repo struct {
-	db *pgx.Conn
+	db *pgxpool.Pool
 }
 
 func (r Repo) Get(ctx context.Context, year int, month int) ([]operations.Operation, error) {
 	const fn = "repo.history.Get"
-	const query = `SELECT user_id, segments.slug, type, time FROM operations 
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT user_id, segments.slug, type, time, details FROM operations 
                    JOIN segments ON operations.segment_id = segments.id
                    WHERE time BETWEEN $1 AND $2`
-	m := time.Month(month)
-	minTime := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
-	maxTime := time.Date(year, m+1, 0, 23, 59, 59, 1e9-1, time.UTC)
-	rows, err := r.db.Query(ctx, query, minTime, maxTime)
+	minTime, maxTime := monthBounds(year, month)
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, minTime, maxTime)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return []operations.Operation{}, nil
 	}
@@ -33,7 +34,7 @@ func (r Repo) Get(ctx context.Context, year int, month int) ([]operations.Operat
 	var res []operations.Operation
 	for rows.Next() {
 		op := operations.Operation{}
-		err := rows.Scan(&op.UserID, &op.Segment.Slug, &op.Type, &op.Time)
+		err := rows.Scan(&op.UserID, &op.Segment.Slug, &op.Type, &op.Time, &op.Details)
 		if err != nil {
 			logger.InternalErr(ctx, err, fn)
 			return nil, err
@@ -43,15 +44,27 @@ func (r Repo) Get(ctx context.Context, year int, month int) ([]operations.Operat
 	return res, nil
 }
 
+// monthBounds returns the first and the last instants of the month in UTC
+func monthBounds(year int, month int) (time.Time, time.Time) {
+	m := time.Month(month)
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC), time.Date(year, m+1, 0, 23, 59, 59, 1e9-1, time.UTC)
+}
+
 func (r Repo) Put(ctx context.Context, ops []operations.Operation) error {
 	const fn = "repo.history.Put"
-	const query = `INSERT INTO operations (user_id, segment_id, type, time) VALUES 
-				   ($1, (SELECT id FROM segments WHERE slug=$2), $3, $4)`
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `INSERT INTO operations (user_id, segment_id, type, time, details) VALUES 
+				   ($1, resolve_segment($2), $3, $4, $5)`
 	batch := &pgx.Batch{}
 	for _, op := range ops {
-		batch.Queue(query, op.UserID, op.Segment.Slug, op.Type, op.Time)
+		// empty details are stored as NULL rather than JSON null
+		var details any
+		if len(op.Details) != 0 {
+			details = op.Details
+		}
+		batch.Queue(query, op.UserID, op.Segment.Slug, op.Type, op.Time, details)
 	}
-	br := r.db.SendBatch(ctx, batch)
+	br := repo.Conn(ctx, r.db).SendBatch(ctx, batch)
 	defer func(br pgx.BatchResults) {
 		_ = br.Close()
 	}(br)
@@ -70,6 +83,72 @@ func (r Repo) Put(ctx context.Context, ops []operations.Operation) error {
 	return nil
 }
 
-func New(db *pgx.Conn) Repo {
+const selectOperations = `SELECT operations.id, user_id, segments.slug, type, time, details FROM operations
+                          JOIN segments ON operations.segment_id = segments.id`
+
+func scanOperations(ctx context.Context, fn string, rows pgx.Rows) ([]operations.Operation, error) {
+	defer rows.Close()
+	res := make([]operations.Operation, 0)
+	for rows.Next() {
+		op := operations.Operation{}
+		err := rows.Scan(&op.ID, &op.UserID, &op.Segment.Slug, &op.Type, &op.Time, &op.Details)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, op)
+	}
+	if err := rows.Err(); err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetByIDs returns existing operations with the IDs ordered by ID
+func (r Repo) GetByIDs(ctx context.Context, ids []int64) ([]operations.Operation, error) {
+	const fn = "repo.history.GetByIDs"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = selectOperations + " WHERE operations.id=ANY($1) ORDER BY operations.id"
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, ids)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	return scanOperations(ctx, fn, rows)
+}
+
+// After returns up to limit operations matching the filter with ID greater than afterID ordered by ID
+func (r Repo) After(ctx context.Context, afterID int64, filter operations.Filter, limit int) ([]operations.Operation, error) {
+	const fn = "repo.history.After"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = selectOperations + `
+                   WHERE operations.id > $1 AND ($2::BIGINT = 0 OR user_id = $2) AND ($3::TEXT = '' OR segments.slug = $3)
+                   ORDER BY operations.id LIMIT $4`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, afterID, filter.UserID, filter.Segment, limit)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	return scanOperations(ctx, fn, rows)
+}
+
+// GetPage returns up to limit operations made during the month with ID greater than afterID ordered by ID
+func (r Repo) GetPage(ctx context.Context, year int, month int, afterID int64, limit int) ([]operations.Operation, error) {
+	const fn = "repo.history.GetPage"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = selectOperations + `
+                   WHERE time BETWEEN $1 AND $2 AND operations.id > $3
+                   ORDER BY operations.id LIMIT $4`
+	minTime, maxTime := monthBounds(year, month)
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, minTime, maxTime, afterID, limit)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	return scanOperations(ctx, fn, rows)
+}
+
+func New(db *pgxpool.Pool) Repo {
 	return Repo{db}
 }
@@ -0,0 +1,92 @@
+// Package notify broadcasts messages between replicas by Postgres LISTEN/NOTIFY
+package notify
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+)
+
+const maxBackoff = 30 * time.Second
+
+type Channel struct {
+	db   *pgxpool.Pool
+	name string
+}
+
+// Publish sends the payload to every listener of the channel. Payload must be shorter than 8000 bytes
+func (c Channel) Publish(ctx context.Context, payload string) error {
+	const fn = "repo.notify.Publish"
+	defer metrics.ObserveQuery(fn, time.Now())
+	_, err := c.db.Exec(ctx, "SELECT pg_notify($1, $2)", c.name, payload)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
+
+// Listen calls handle for every message until ctx is done. The connection is reestablished on failures,
+// and reconnected is called once listening is resumed, because messages sent in between are lost
+func (c Channel) Listen(ctx context.Context, handle func(payload string), reconnected func()) error {
+	const fn = "repo.notify.Listen"
+	backoff := time.Second
+	var listened bool
+	for {
+		start := time.Now()
+		err := c.listen(ctx, handle, func() {
+			if listened && reconnected != nil {
+				reconnected()
+			}
+			listened = true
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(start) > maxBackoff {
+			backoff = time.Second
+		}
+		logger.Log(ctx).WarnContext(
+			ctx,
+			"listening interrupted",
+			slog.String("fn", fn),
+			slog.String("channel", c.name),
+			slog.String("error", err.Error()),
+			slog.String("retry", backoff.String()),
+		)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c Channel) listen(ctx context.Context, handle func(payload string), listening func()) error {
+	pooled, err := c.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// the connection stays in the listening state, so it must not return to the pool
+	conn := pooled.Hijack()
+	defer func() { _ = conn.Close(context.Background()) }()
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{c.name}.Sanitize()); err != nil {
+		return err
+	}
+	listening()
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle(n.Payload)
+	}
+}
+
+func New(db *pgxpool.Pool, name string) Channel {
+	return Channel{db: db, name: name}
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+	"user-segmentation/internal/entities/outbox"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+// Put stores events in the transaction of ctx, so they are published only if it commits
+func (r Repo) Put(ctx context.Context, events []outbox.Event) error {
+	const fn = "repo.outbox.Put"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "INSERT INTO outbox (key, topic, payload, created_at) VALUES ($1, $2, $3, $4)"
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		batch.Queue(query, e.Key, e.Topic, e.Payload, e.CreatedAt)
+	}
+	err := repo.Conn(ctx, r.db).SendBatch(ctx, batch).Close()
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
+
+// Pending locks up to limit unsent events in the order of writing. Locked events are skipped,
+// so relays of other replicas take the next ones. It must be called in a transaction
+func (r Repo) Pending(ctx context.Context, limit int) ([]outbox.Event, error) {
+	const fn = "repo.outbox.Pending"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT id, key, topic, payload, created_at FROM outbox
+                   WHERE sent_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, limit)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]outbox.Event, 0)
+	for rows.Next() {
+		var e outbox.Event
+		if err := rows.Scan(&e.ID, &e.Key, &e.Topic, &e.Payload, &e.CreatedAt); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) MarkSent(ctx context.Context, ids []int64) error {
+	const fn = "repo.outbox.MarkSent"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "UPDATE outbox SET sent_at=$2, attempts=attempts+1, last_error=NULL WHERE id=ANY($1)"
+	_, err := repo.Conn(ctx, r.db).Exec(ctx, query, ids, time.Now().UTC())
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
+
+func (r Repo) MarkFailed(ctx context.Context, id int64, reason string) error {
+	const fn = "repo.outbox.MarkFailed"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "UPDATE outbox SET attempts=attempts+1, last_error=$2 WHERE id=$1"
+	_, err := repo.Conn(ctx, r.db).Exec(ctx, query, id, reason)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
+
+func New(db *pgxpool.Pool) Repo {
+	return Repo{db: db}
+}
@@ -0,0 +1,155 @@
+package segments
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+const constrGroupExists = "segment_groups_name_key"
+
+func (r Repo) StoreGroup(ctx context.Context, g segments.Group) error {
+	const fn = "repo.segments.StoreGroup"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "INSERT INTO segment_groups (name, exclusive) VALUES ($1, $2)"
+	_, err := repo.Conn(ctx, r.db).Exec(ctx, query, g.Name, g.Exclusive)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == constrGroupExists {
+			return repo.ErrGroupAlreadyExists
+		}
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
+
+// ListGroups returns groups with their segments ordered by name
+func (r Repo) ListGroups(ctx context.Context) ([]segments.Group, error) {
+	const fn = "repo.segments.ListGroups"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT name, exclusive, created_at,
+                       ARRAY(SELECT slug FROM segments WHERE group_id = segment_groups.id ORDER BY slug)
+                   FROM segment_groups ORDER BY name`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.Group, 0)
+	for rows.Next() {
+		var g segments.Group
+		if err := rows.Scan(&g.Name, &g.Exclusive, &g.CreatedAt, &g.Segments); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, g)
+	}
+	return res, rows.Err()
+}
+
+// DeleteGroup deletes the group, its segments stay without a group
+func (r Repo) DeleteGroup(ctx context.Context, name string) error {
+	const fn = "repo.segments.DeleteGroup"
+	defer metrics.ObserveQuery(fn, time.Now())
+	cmd, err := repo.Conn(ctx, r.db).Exec(ctx, "DELETE FROM segment_groups WHERE name=$1", name)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repo.ErrGroupNotFound
+	}
+	return nil
+}
+
+// SetGroup moves the segment to the group, empty group removes the segment from its group.
+// The segment is not moved to an exclusive group if any of its users is in another segment of the group
+func (r Repo) SetGroup(ctx context.Context, slug string, group string) (segments.Segment, error) {
+	const fn = "repo.segments.SetGroup"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const (
+		lockQuery = "SELECT id FROM segments WHERE id=resolve_segment($1) FOR UPDATE"
+		// locking the group serializes moving of segments to it
+		groupQuery    = "SELECT id, exclusive FROM segment_groups WHERE name=$1 FOR UPDATE"
+		conflictQuery = `SELECT EXISTS (SELECT 1 FROM user_segments members
+                         JOIN user_segments others ON others.user_id = members.user_id AND others.segment_id <> $1
+                         JOIN segments ON segments.id = others.segment_id AND segments.group_id = $2
+                         WHERE members.segment_id = $1)`
+		updateQuery = `UPDATE segments SET group_id=$2, updated_at=now() WHERE id=$1 RETURNING ` + segmentColumns
+	)
+	var seg segments.Segment
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		var id int64
+		if err := db.QueryRow(ctx, lockQuery, slug).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return repo.ErrSegmentNotFound
+			}
+			return err
+		}
+		var groupID *int64
+		if group != "" {
+			var gid int64
+			var exclusive bool
+			if err := db.QueryRow(ctx, groupQuery, group).Scan(&gid, &exclusive); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return repo.ErrGroupNotFound
+				}
+				return err
+			}
+			if exclusive {
+				var conflict bool
+				if err := db.QueryRow(ctx, conflictQuery, id, gid).Scan(&conflict); err != nil {
+					return err
+				}
+				if conflict {
+					return segments.ErrGroupConflict
+				}
+			}
+			groupID = &gid
+		}
+		var err error
+		seg, err = scanSegment(db.QueryRow(ctx, updateQuery, id, groupID))
+		return err
+	})
+	if err != nil && !errors.Is(err, repo.ErrSegmentNotFound) && !errors.Is(err, repo.ErrGroupNotFound) &&
+		!errors.Is(err, segments.ErrGroupConflict) {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// ExclusiveMembers returns, by the slugs of segments, the other segments of their exclusive groups the user is in
+func (r Repo) ExclusiveMembers(ctx context.Context, userID int64, slugs []string) (map[string][]string, error) {
+	const fn = "repo.segments.ExclusiveMembers"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT added.slug, others.slug FROM unnest($2::TEXT[]) added(slug)
+                   JOIN segments ON segments.id = resolve_segment(added.slug)
+                   JOIN segment_groups ON segment_groups.id = segments.group_id AND segment_groups.exclusive
+                   JOIN segments others ON others.group_id = segments.group_id AND others.id <> segments.id
+                   JOIN user_segments ON user_segments.segment_id = others.id AND user_segments.user_id = $1
+                   ORDER BY others.slug`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, userID, slugs)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[string][]string)
+	for rows.Next() {
+		var slug, other string
+		if err := rows.Scan(&slug, &other); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res[slug] = append(res[slug], other)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,56 @@
+package segments
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"time"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+// SetPayload replaces the payload of the segment, nil removes it
+func (r Repo) SetPayload(ctx context.Context, slug string, payload *segments.Payload) (segments.Segment, error) {
+	const fn = "repo.segments.SetPayload"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET payload=$2, payload_schema=$3, payload_priority=$4, updated_at=now()
+                   WHERE id=resolve_segment($1) RETURNING ` + segmentColumns
+	// nil byte slices are stored as NULL, unlike nil json.RawMessage encoded as JSON null
+	var value, schema []byte
+	priority := 0
+	if payload != nil {
+		value, schema, priority = payload.Value, payload.Schema, payload.Priority
+	}
+	seg, err := scanSegment(repo.Conn(ctx, r.db).QueryRow(ctx, query, slug, value, schema, priority))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return segments.Segment{}, repo.ErrSegmentNotFound
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// Payloads returns the segments with payloads among the slugs
+func (r Repo) Payloads(ctx context.Context, slugs []string) ([]segments.Segment, error) {
+	const fn = "repo.segments.Payloads"
+	defer metrics.ObserveQuery(fn, time.Now())
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, selectSegments+" WHERE slug = ANY($1) AND payload IS NOT NULL", slugs)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.Segment, 0)
+	for rows.Next() {
+		seg, err := scanSegment(rows)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, seg)
+	}
+	return res, rows.Err()
+}
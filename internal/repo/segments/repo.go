@@ -3,29 +3,49 @@ package segments
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"log/slog"
+	"time"
+	"user-segmentation/internal/entities/experiments"
 	"user-segmentation/internal/entities/segments"
 	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
 	"user-segmentation/internal/repo"
 	"user-segmentation/internal/service"
 )
 
 const (
+	// codeArchived is raised by addable_segment, codeExclusive by check_exclusive_group
+	codeArchived         = "SG001"
+	codeExclusive        = "SG002"
 	constrSegmentID      = "user_segments_segment_id_key"
 	constrSegmentExists  = "segments_slug_key"
 	constrRelationExists = "user_segments_pkey"
+	constrVariantSegment = "experiment_variants_segment_id_fkey"
 )
 
 type Repo struct {
-	db *pgx.Conn
+	db *pgxpool.Pool
 }
 
 func (r Repo) Store(ctx context.Context, seg segments.Segment) error {
 	const fn = "repo.segments.Store"
-	const query = "INSERT INTO segments (slug) VALUES ($1)"
-	_, err := r.db.Exec(ctx, query, seg.Slug)
+	defer metrics.ObserveQuery(fn, time.Now())
+	// the slug of a renamed segment is taken until its alias expires
+	// the scheduler records only later transitions, so the segment starts in the current state of its schedule
+	// members of a segment with a rule are computed for known users by the scheduler
+	const query = `INSERT INTO segments (slug, description, owner, tags, state, active_from, active_until, scheduled_active, rule, resync_at)
+                   SELECT $1, $2, $3, $4, $5, $6, $7, ($6::TIMESTAMPTZ IS NULL OR $6 <= now()) AND ($7::TIMESTAMPTZ IS NULL OR $7 > now()),
+                          NULLIF($8, ''), CASE WHEN $8 <> '' THEN now() END
+                   WHERE NOT EXISTS (SELECT 1 FROM segment_aliases WHERE slug=$1 AND expires_at > now())`
+	tags := seg.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	cmd, err := repo.Conn(ctx, r.db).Exec(ctx, query, seg.Slug, seg.Description, seg.Owner, tags, seg.State, seg.ActiveFrom, seg.ActiveUntil, seg.Rule)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.ConstraintName == constrSegmentExists {
@@ -33,29 +53,373 @@ func (r Repo) Store(ctx context.Context, seg segments.Segment) error {
 		} else {
 			logger.InternalErr(ctx, err, fn)
 		}
+		return err
 	}
-	return err
+	if cmd.RowsAffected() == 0 {
+		return repo.ErrSegmentAlreadyExists
+	}
+	return nil
 }
 
+// Delete purges the archived segment with its memberships and history. Segments with children are not purged
 func (r Repo) Delete(ctx context.Context, seg segments.Segment) error {
-	const fn = "repo.segments.Remove"
-	const query = "DELETE FROM segments WHERE slug=$1"
-	cmd, err := r.db.Exec(ctx, query, seg.Slug)
-	if errors.Is(err, pgx.ErrNoRows) || cmd.RowsAffected() == 0 {
-		return repo.ErrSegmentNotFound
-	}
+	const fn = "repo.segments.Delete"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "DELETE FROM segments WHERE id=resolve_segment($1) AND state=$2"
+	cmd, err := repo.Conn(ctx, r.db).Exec(ctx, query, seg.Slug, segments.Archived)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == constrParent {
+			return segments.ErrHasChildren
+		}
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == constrVariantSegment {
+			return experiments.ErrSegmentInUse
+		}
 		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return r.whyNotChanged(ctx, seg.Slug, segments.ErrNotArchived)
+	}
+	return nil
+}
+
+// whyNotChanged returns ErrSegmentNotFound if the segment does not exist, or err otherwise
+func (r Repo) whyNotChanged(ctx context.Context, slug string, err error) error {
+	if _, getErr := r.Get(ctx, slug); getErr != nil {
+		return getErr
 	}
 	return err
 }
 
+const (
+	segmentColumns = `segments.slug, segments.description, segments.owner, segments.tags, segments.state,
+                      segments.active_from, segments.active_until,
+                      COALESCE((SELECT name FROM segment_groups WHERE segment_groups.id = segments.group_id), ''),
+                      COALESCE((SELECT parents.slug FROM segments parents WHERE parents.id = segments.parent_id), ''),
+                      segments.rollout_buckets, segments.rollout_salt, COALESCE(segments.rule, ''),
+                      segments.payload, segments.payload_schema, segments.payload_priority,
+                      segments.created_at, segments.updated_at`
+	selectSegments = "SELECT " + segmentColumns + " FROM segments"
+)
+
+func scanSegment(row pgx.Row, dest ...any) (segments.Segment, error) {
+	var seg segments.Segment
+	var buckets *int
+	var salt *string
+	var payload, schema []byte
+	var priority int
+	dest = append([]any{
+		&seg.Slug, &seg.Description, &seg.Owner, &seg.Tags, &seg.State, &seg.ActiveFrom, &seg.ActiveUntil,
+		&seg.Group, &seg.Parent, &buckets, &salt, &seg.Rule, &payload, &schema, &priority, &seg.CreatedAt, &seg.UpdatedAt,
+	}, dest...)
+	err := row.Scan(dest...)
+	if err == nil && buckets != nil && salt != nil {
+		seg.Rollout = &segments.Rollout{Percent: segments.PercentOf(*buckets), Salt: *salt}
+	}
+	if err == nil && payload != nil {
+		seg.Payload = &segments.Payload{Value: payload, Schema: schema, Priority: priority}
+	}
+	return seg, err
+}
+
+func (r Repo) Get(ctx context.Context, slug string) (segments.Segment, error) {
+	const fn = "repo.segments.Get"
+	defer metrics.ObserveQuery(fn, time.Now())
+	seg, err := scanSegment(repo.Conn(ctx, r.db).QueryRow(ctx, selectSegments+" WHERE id=resolve_segment($1)", slug))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return segments.Segment{}, repo.ErrSegmentNotFound
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// List returns segments matching the filter ordered by slug
+func (r Repo) List(ctx context.Context, filter segments.Filter) ([]segments.Segment, error) {
+	const fn = "repo.segments.List"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = selectSegments + ` WHERE ($1::TEXT = '' OR $1 = ANY(tags)) AND ($2::TEXT = '' OR owner = $2)
+                   AND ($3::SMALLINT IS NULL OR state = $3) ORDER BY slug`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, filter.Tag, filter.Owner, filter.State)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.Segment, 0)
+	for rows.Next() {
+		seg, err := scanSegment(rows)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, seg)
+	}
+	return res, rows.Err()
+}
+
+// Update changes the metadata of the segment and returns the updated segment
+func (r Repo) Update(ctx context.Context, slug string, patch segments.Patch) (segments.Segment, error) {
+	const fn = "repo.segments.Update"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET description=COALESCE($2, description), owner=COALESCE($3, owner),
+                   tags=COALESCE($4, tags), updated_at=now() WHERE id=resolve_segment($1)
+                   RETURNING ` + segmentColumns
+	seg, err := scanSegment(repo.Conn(ctx, r.db).QueryRow(ctx, query, slug, patch.Description, patch.Owner, patch.Tags))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return segments.Segment{}, repo.ErrSegmentNotFound
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// Rename changes the slug of the segment. The old slug stays an alias of the segment until expiresAt,
+// webhooks subscribed to the old slug are subscribed to the new one
+func (r Repo) Rename(ctx context.Context, from string, to string, expiresAt time.Time) (segments.Segment, error) {
+	const fn = "repo.segments.Rename"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const (
+		lockQuery = "SELECT id FROM segments WHERE slug=$1 FOR UPDATE"
+		// expired aliases and the alias of the segment itself do not reserve the slug
+		aliasQuery  = `DELETE FROM segment_aliases WHERE expires_at <= now() OR (slug=$1 AND segment_id=$2)`
+		takenQuery  = "SELECT EXISTS (SELECT 1 FROM segment_aliases WHERE slug=$1)"
+		renameQuery = `UPDATE segments SET slug=$2, updated_at=now() WHERE id=$1
+                       RETURNING ` + segmentColumns
+		storeAliasQuery = "INSERT INTO segment_aliases (slug, segment_id, expires_at) VALUES ($1, $2, $3)"
+		webhooksQuery   = "UPDATE webhooks SET segments=array_replace(segments, $1, $2) WHERE $1 = ANY(segments)"
+	)
+	var seg segments.Segment
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		var id int64
+		if err := db.QueryRow(ctx, lockQuery, from).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return repo.ErrSegmentNotFound
+			}
+			return err
+		}
+		if _, err := db.Exec(ctx, aliasQuery, to, id); err != nil {
+			return err
+		}
+		var taken bool
+		if err := db.QueryRow(ctx, takenQuery, to).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return repo.ErrSegmentAlreadyExists
+		}
+		var err error
+		if seg, err = scanSegment(db.QueryRow(ctx, renameQuery, id, to)); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.ConstraintName == constrSegmentExists {
+				return repo.ErrSegmentAlreadyExists
+			}
+			return err
+		}
+		if _, err := db.Exec(ctx, storeAliasQuery, from, id, expiresAt); err != nil {
+			return err
+		}
+		_, err = db.Exec(ctx, webhooksQuery, from, to)
+		return err
+	})
+	if err != nil && !errors.Is(err, repo.ErrSegmentNotFound) && !errors.Is(err, repo.ErrSegmentAlreadyExists) {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// SetState moves the segment to the state if the current state is one of from and returns the previous state.
+// Archiving remembers the previous state for Restore. Segments with children that are not archived are not archived
+func (r Repo) SetState(ctx context.Context, slug string, from []segments.State, to segments.State) (segments.Segment, segments.State, error) {
+	const fn = "repo.segments.SetState"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET state=$2, updated_at=now(),
+                   archived_from=CASE WHEN $2=$4 THEN prev.state END
+                   FROM (SELECT id, state FROM segments WHERE id=resolve_segment($1) FOR UPDATE) prev
+                   WHERE segments.id=prev.id AND prev.state=ANY($3)
+                   AND ($2<>$4 OR NOT EXISTS (SELECT 1 FROM segments children WHERE children.parent_id=prev.id AND children.state<>$4))
+                   RETURNING ` + segmentColumns + ", prev.state"
+	states := make([]int16, len(from))
+	for i := range from {
+		states[i] = int16(from[i])
+	}
+	var seg segments.Segment
+	var prev segments.State
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		if to == segments.Archived {
+			if err := lockTrees(ctx, db); err != nil {
+				return err
+			}
+		}
+		var err error
+		seg, err = scanSegment(db.QueryRow(ctx, query, slug, to, states, segments.Archived), &prev)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		reason := segments.ErrInvalidTransition
+		if to == segments.Archived {
+			children, err := r.hasLiveChildren(ctx, slug)
+			if err != nil {
+				return err
+			}
+			if children {
+				reason = segments.ErrHasChildren
+			}
+		}
+		return r.whyNotChanged(ctx, slug, reason)
+	})
+	if err != nil && !errors.Is(err, repo.ErrSegmentNotFound) && !errors.Is(err, segments.ErrInvalidTransition) &&
+		!errors.Is(err, segments.ErrHasChildren) {
+		logger.InternalErr(ctx, err, fn)
+	}
+	if err != nil {
+		return segments.Segment{}, 0, err
+	}
+	return seg, prev, nil
+}
+
+// Restore moves the archived segment to the state it had before archiving. Children of archived parents are not restored.
+// Members of a restored segment with a rollout or a rule are recomputed, since attributes of users changed meanwhile
+func (r Repo) Restore(ctx context.Context, slug string) (segments.Segment, error) {
+	const fn = "repo.segments.Restore"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET state=COALESCE(archived_from, $3), archived_from=NULL, updated_at=now(),
+                   resync_at=CASE WHEN rollout_buckets IS NOT NULL OR rule IS NOT NULL THEN now() ELSE resync_at END
+                   WHERE id=resolve_segment($1) AND state=$2
+                   AND NOT EXISTS (SELECT 1 FROM segments parents WHERE parents.id=segments.parent_id AND parents.state=$2)
+                   RETURNING ` + segmentColumns
+	var seg segments.Segment
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		if err := lockTrees(ctx, db); err != nil {
+			return err
+		}
+		var err error
+		seg, err = scanSegment(db.QueryRow(ctx, query, slug, segments.Archived, segments.Active))
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		archived, err := r.hasArchivedParent(ctx, slug)
+		if err != nil {
+			return err
+		}
+		if archived {
+			return segments.ErrParentArchived
+		}
+		return r.whyNotChanged(ctx, slug, segments.ErrInvalidTransition)
+	})
+	if err != nil && !errors.Is(err, repo.ErrSegmentNotFound) && !errors.Is(err, segments.ErrInvalidTransition) &&
+		!errors.Is(err, segments.ErrParentArchived) {
+		logger.InternalErr(ctx, err, fn)
+	}
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return seg, nil
+}
+
+// SetSchedule replaces the schedule of the segment
+func (r Repo) SetSchedule(ctx context.Context, slug string, schedule segments.Schedule) (segments.Segment, error) {
+	const fn = "repo.segments.SetSchedule"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET active_from=$2, active_until=$3, updated_at=now() WHERE id=resolve_segment($1)
+                   RETURNING ` + segmentColumns
+	seg, err := scanSegment(repo.Conn(ctx, r.db).QueryRow(ctx, query, slug, schedule.ActiveFrom, schedule.ActiveUntil))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return segments.Segment{}, repo.ErrSegmentNotFound
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// SetRule replaces the rule of the segment, empty rule makes members of the segment explicit.
+// Members are recomputed by the scheduler
+func (r Repo) SetRule(ctx context.Context, slug string, rule string) (segments.Segment, error) {
+	const fn = "repo.segments.SetRule"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET rule=NULLIF($2, ''), updated_at=now(), resync_at=now() WHERE id=resolve_segment($1)
+                   RETURNING ` + segmentColumns
+	seg, err := scanSegment(repo.Conn(ctx, r.db).QueryRow(ctx, query, slug, rule))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return segments.Segment{}, repo.ErrSegmentNotFound
+	}
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// Upcoming returns up to limit transitions of not archived segments by their schedules
+// in the interval (from, until] ordered by time
+func (r Repo) Upcoming(ctx context.Context, from time.Time, until time.Time, limit int) ([]segments.Transition, error) {
+	const fn = "repo.segments.Upcoming"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT slug, TRUE, active_from FROM segments WHERE state<>$4 AND active_from > $1 AND active_from <= $2
+                   UNION ALL
+                   SELECT slug, FALSE, active_until FROM segments WHERE state<>$4 AND active_until > $1 AND active_until <= $2
+                   ORDER BY 3, 1 LIMIT $3`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, from, until, limit, segments.Archived)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.Transition, 0)
+	for rows.Next() {
+		var tr segments.Transition
+		if err := rows.Scan(&tr.Segment, &tr.Activate, &tr.At); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, tr)
+	}
+	return res, rows.Err()
+}
+
+// SwitchSchedules marks not archived segments that entered or left their schedules by now
+// and returns them. Concurrent callers get different segments
+func (r Repo) SwitchSchedules(ctx context.Context, now time.Time) ([]segments.Segment, error) {
+	const fn = "repo.segments.SwitchSchedules"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `WITH due AS (
+                       SELECT id FROM segments WHERE state<>$2 AND scheduled_active <>
+                           ((active_from IS NULL OR active_from <= $1) AND (active_until IS NULL OR active_until > $1))
+                       FOR UPDATE SKIP LOCKED
+                   )
+                   UPDATE segments SET scheduled_active=NOT scheduled_active FROM due WHERE segments.id=due.id
+                   RETURNING ` + segmentColumns
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, now, segments.Archived)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.Segment, 0)
+	for rows.Next() {
+		seg, err := scanSegment(rows)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, seg)
+	}
+	return res, rows.Err()
+}
+
 var ErrChangingInternal = errors.New("internal error")
 
 func (r Repo) ChangeUserSegments(ctx context.Context, userID int64, add []segments.Segment, remove []segments.Segment) service.ChangeErrors {
 	const fn = "repo.segments.ChangeUserSegments"
-	const addQuery = `INSERT INTO user_segments (user_id, segment_id) VALUES ($1, (SELECT id FROM segments WHERE slug=$2))`
-	const rmQuery = `DELETE FROM user_segments WHERE user_id=$1 AND segment_id=(SELECT id FROM segments WHERE slug=$2)`
+	defer metrics.ObserveQuery(fn, time.Now())
+	const addQuery = `INSERT INTO user_segments (user_id, segment_id) VALUES ($1, addable_segment($2))`
+	const rmQuery = `DELETE FROM user_segments WHERE user_id=$1 AND segment_id=resolve_segment($2)`
 	batch := &pgx.Batch{}
 	for _, seg := range remove {
 		batch.Queue(rmQuery, userID, seg.Slug)
@@ -63,14 +427,18 @@ func (r Repo) ChangeUserSegments(ctx context.Context, userID int64, add []segmen
 	for _, seg := range add {
 		batch.Queue(addQuery, userID, seg.Slug)
 	}
-	br := r.db.SendBatch(ctx, batch)
+	br := repo.Conn(ctx, r.db).SendBatch(ctx, batch)
 	defer func(br pgx.BatchResults) {
 		_ = br.Close()
 	}(br)
 	errs := make(service.ChangeErrors)
 	for _, seg := range remove {
-		_, err := br.Exec()
-		logger.Log(ctx).Debug("exec rm", slog.Any("error", err), slog.String("segment", seg.Slug))
+		cmd, err := br.Exec()
+		logger.Log(ctx).DebugContext(ctx, "exec rm", slog.Any("error", err), slog.String("segment", seg.Slug))
+		if err == nil && cmd.RowsAffected() == 0 {
+			// the user is not in the segment, or a concurrent change has removed the user
+			err = pgx.ErrNoRows
+		}
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				err = repo.ErrRelationNotFound
@@ -83,13 +451,20 @@ func (r Repo) ChangeUserSegments(ctx context.Context, userID int64, add []segmen
 	}
 	for _, seg := range add {
 		_, err := br.Exec()
-		logger.Log(ctx).Debug("exec add", slog.Any("error", err), slog.String("segment", seg.Slug))
+		logger.Log(ctx).DebugContext(ctx, "exec add", slog.Any("error", err), slog.String("segment", seg.Slug))
 		if err != nil {
 			var pgErr *pgconn.PgError
-			if errors.As(err, &pgErr) && pgErr.ConstraintName == constrSegmentID {
+			if !errors.As(err, &pgErr) {
+				pgErr = &pgconn.PgError{}
+			}
+			if pgErr.ConstraintName == constrSegmentID {
 				err = repo.ErrSegmentNotFound
 			} else if pgErr.ConstraintName == constrRelationExists {
 				err = repo.ErrRelationExists
+			} else if pgErr.Code == codeArchived {
+				err = segments.ErrArchived
+			} else if pgErr.Code == codeExclusive {
+				err = fmt.Errorf("%w: %s", segments.ErrExclusiveGroup, pgErr.Detail)
 			} else {
 				logger.InternalErr(ctx, err, fn)
 				err = ErrChangingInternal
@@ -102,25 +477,146 @@ func (r Repo) ChangeUserSegments(ctx context.Context, userID int64, add []segmen
 
 func (r Repo) GetUserSegments(ctx context.Context, userID int64) ([]segments.Segment, error) {
 	const fn = "repo.segments.GetUserSegments"
-	const query = "SELECT slug FROM segments WHERE id=ANY (SELECT segment_id FROM user_segments WHERE user_id=$1)"
-	rows, err := r.db.Query(ctx, query, userID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return []segments.Segment{}, repo.ErrNoSegments
+	defer metrics.ObserveQuery(fn, time.Now())
+	// segments in other states or out of their schedule keep members, but are not returned to users
+	const query = `SELECT slug, COALESCE((SELECT name FROM segment_groups WHERE segment_groups.id = segments.group_id), ''),
+                          experiments.key, experiment_variants.name FROM segments
+                   LEFT JOIN experiment_variants ON experiment_variants.segment_id = segments.id
+                   LEFT JOIN experiments ON experiments.id = experiment_variants.experiment_id
+                   WHERE state=$2 AND (active_from IS NULL OR active_from <= now())
+                   AND (active_until IS NULL OR active_until > now())
+                   AND segments.id=ANY (SELECT segment_id FROM user_segments WHERE user_id=$1)`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, userID, segments.Active)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
 	}
 	defer rows.Close()
 	var res []segments.Segment
 	for rows.Next() {
-		var slug string
-		err := rows.Scan(&slug)
+		var slug, group string
+		var experiment, variant *string
+		err := rows.Scan(&slug, &group, &experiment, &variant)
 		if err != nil {
 			logger.InternalErr(ctx, err, fn)
 			return nil, err
 		}
-		res = append(res, segments.Segment{Slug: slug})
+		seg := segments.Segment{Slug: slug, Group: group}
+		if experiment != nil {
+			seg.Variant = &segments.VariantOf{Experiment: *experiment, Variant: *variant}
+		}
+		res = append(res, seg)
+	}
+	return res, rows.Err()
+}
+
+// DynamicForUser returns not archived segments with rollouts or rules and the set of their slugs the user is in
+func (r Repo) DynamicForUser(ctx context.Context, userID int64) ([]segments.Segment, map[string]bool, error) {
+	const fn = "repo.segments.DynamicForUser"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT " + segmentColumns + `,
+                   EXISTS (SELECT 1 FROM user_segments WHERE user_id=$1 AND segment_id=segments.id)
+                   FROM segments WHERE (rollout_buckets IS NOT NULL OR rule IS NOT NULL) AND state<>$2`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, userID, segments.Archived)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.Segment, 0)
+	members := make(map[string]bool)
+	for rows.Next() {
+		var member bool
+		seg, err := scanSegment(rows, &member)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, nil, err
+		}
+		res = append(res, seg)
+		if member {
+			members[seg.Slug] = true
+		}
+	}
+	return res, members, rows.Err()
+}
+
+// ActiveDynamic returns active segments with rollouts or rules ordered by slug. Schedules are not checked,
+// so the list stays valid when the segments enter or leave them
+func (r Repo) ActiveDynamic(ctx context.Context) ([]service.DynamicSegment, error) {
+	const fn = "repo.segments.ActiveDynamic"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT " + segmentColumns + `,
+                   COALESCE((SELECT exclusive FROM segment_groups WHERE segment_groups.id = segments.group_id), FALSE)
+                   FROM segments WHERE (rollout_buckets IS NOT NULL OR rule IS NOT NULL) AND state=$1 ORDER BY slug`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, segments.Active)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]service.DynamicSegment, 0)
+	for rows.Next() {
+		var exclusive bool
+		seg, err := scanSegment(rows, &exclusive)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, service.DynamicSegment{Segment: seg, Exclusive: exclusive})
+	}
+	return res, rows.Err()
+}
+
+// Dynamic returns the slugs of the segments with rollouts or rules, old slugs of renamed segments are resolved
+func (r Repo) Dynamic(ctx context.Context, slugs []string) ([]string, error) {
+	const fn = "repo.segments.Dynamic"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT slug FROM unnest($1::TEXT[]) AS slug
+                   WHERE EXISTS (SELECT 1 FROM segments WHERE id=resolve_segment(slug)
+                                 AND (rollout_buckets IS NOT NULL OR rule IS NOT NULL))`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, slugs)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]string, 0)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, slug)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) CountMembers(ctx context.Context) (map[string]int64, error) {
+	const fn = "repo.segments.CountMembers"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT slug, COUNT(user_id) FROM segments 
+                   LEFT JOIN user_segments ON segments.id = user_segments.segment_id
+                   GROUP BY slug`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[string]int64)
+	for rows.Next() {
+		var slug string
+		var n int64
+		if err := rows.Scan(&slug, &n); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res[slug] = n
 	}
-	return res, nil
+	return res, rows.Err()
 }
 
-func New(db *pgx.Conn) Repo {
+func New(db *pgxpool.Pool) Repo {
 	return Repo{db: db}
 }
@@ -0,0 +1,92 @@
+package segments
+
+import (
+	"context"
+	"time"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/service"
+)
+
+// ClaimResync returns up to limit not archived segments whose rollouts or rules changed after their members were
+// recomputed, ordered by the time of the change, and leases them until leaseUntil, so other replicas do not recompute
+// them at the same time. Archived segments keep members and are recomputed when restored
+func (r Repo) ClaimResync(ctx context.Context, limit int, leaseUntil time.Time) ([]service.PendingResync, error) {
+	const fn = "repo.segments.ClaimResync"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET resync_claimed_until=$3
+                   WHERE id IN (
+                       SELECT id FROM segments
+                       WHERE resync_at IS NOT NULL AND state<>$1 AND (resync_claimed_until IS NULL OR resync_claimed_until<=now())
+                       ORDER BY resync_at
+                       LIMIT $2 FOR UPDATE SKIP LOCKED
+                   )
+                   RETURNING ` + segmentColumns + ", segments.resync_at"
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, segments.Archived, limit, leaseUntil)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]service.PendingResync, 0)
+	for rows.Next() {
+		var p service.PendingResync
+		if p.Segment, err = scanSegment(rows, &p.At); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// Candidates returns up to limit users after the user ordered by id with their attributes and membership in the segment,
+// nil after starts from the first user. Users are known by their segments and attributes
+func (r Repo) Candidates(ctx context.Context, slug string, after *int64, limit int) ([]service.Candidate, error) {
+	const fn = "repo.segments.Candidates"
+	defer metrics.ObserveQuery(fn, time.Now())
+	// both sources are limited separately, so a page reads at most 2*limit users
+	const query = `WITH users AS (
+                       (SELECT DISTINCT user_id FROM user_segments WHERE $2::BIGINT IS NULL OR user_id > $2 ORDER BY user_id LIMIT $3)
+                       UNION
+                       (SELECT user_id FROM user_attributes WHERE $2::BIGINT IS NULL OR user_id > $2 ORDER BY user_id LIMIT $3)
+                   )
+                   SELECT users.user_id, user_attributes.attributes,
+                          EXISTS (SELECT 1 FROM user_segments WHERE user_id=users.user_id AND segment_id=resolve_segment($1))
+                   FROM users LEFT JOIN user_attributes ON user_attributes.user_id = users.user_id
+                   ORDER BY users.user_id LIMIT $3`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, slug, after, limit)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]service.Candidate, 0, limit)
+	for rows.Next() {
+		var c service.Candidate
+		var attrs map[string]any
+		if err := rows.Scan(&c.UserID, &attrs, &c.Member); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		c.Attributes = attrs
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+// FinishResync marks members of the segment recomputed, unless the segment changed again after the change at the time,
+// and releases the lease, so the next change is recomputed at once
+func (r Repo) FinishResync(ctx context.Context, slug string, at time.Time) error {
+	const fn = "repo.segments.FinishResync"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE segments SET resync_at=CASE WHEN resync_at=$2 THEN NULL ELSE resync_at END, resync_claimed_until=NULL
+                   WHERE id=resolve_segment($1)`
+	if _, err := repo.Conn(ctx, r.db).Exec(ctx, query, slug, at); err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	return nil
+}
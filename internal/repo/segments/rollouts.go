@@ -0,0 +1,145 @@
+package segments
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"time"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+// SetRollout sets the rollout percent of the segment and replaces its ramp, nil percent disables the rollout
+// and drops the ramp. The salt is used only by the first rollout of the segment. Members are recomputed by the scheduler.
+// Returns the previous percent
+func (r Repo) SetRollout(ctx context.Context, slug string, percent *float64, salt string, ramp []segments.RampStep) (segments.Segment, *float64, error) {
+	const fn = "repo.segments.SetRollout"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const (
+		lockQuery   = "SELECT id, rollout_buckets FROM segments WHERE id=resolve_segment($1) FOR UPDATE"
+		updateQuery = `UPDATE segments SET rollout_buckets=$2, rollout_salt=COALESCE(rollout_salt, $3), updated_at=now(),
+                       resync_at=now()
+                       WHERE id=$1 RETURNING ` + segmentColumns
+		clearQuery = "DELETE FROM segment_rollout_steps WHERE segment_id=$1"
+		rampQuery  = `INSERT INTO segment_rollout_steps (segment_id, apply_at, buckets)
+                      SELECT $1::BIGINT, apply_at, buckets FROM unnest($2::TIMESTAMPTZ[], $3::INTEGER[]) steps(apply_at, buckets)`
+	)
+	var buckets *int
+	if percent != nil {
+		b := segments.Buckets(*percent)
+		buckets = &b
+	}
+	at := make([]time.Time, len(ramp))
+	stepBuckets := make([]int, len(ramp))
+	for i, step := range ramp {
+		at[i], stepBuckets[i] = step.At, segments.Buckets(step.Percent)
+	}
+	var seg segments.Segment
+	var prev *float64
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		var id int64
+		var prevBuckets *int
+		if err := db.QueryRow(ctx, lockQuery, slug).Scan(&id, &prevBuckets); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return repo.ErrSegmentNotFound
+			}
+			return err
+		}
+		if prevBuckets != nil {
+			p := segments.PercentOf(*prevBuckets)
+			prev = &p
+		}
+		var err error
+		if seg, err = scanSegment(db.QueryRow(ctx, updateQuery, id, buckets, salt)); err != nil {
+			return err
+		}
+		if _, err := db.Exec(ctx, clearQuery, id); err != nil {
+			return err
+		}
+		if len(ramp) == 0 {
+			return nil
+		}
+		_, err = db.Exec(ctx, rampQuery, id, at, stepBuckets)
+		return err
+	})
+	if err != nil && !errors.Is(err, repo.ErrSegmentNotFound) {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, prev, err
+}
+
+// Ramp returns the steps of the rollout ramp of the segment that are not applied yet ordered by time
+func (r Repo) Ramp(ctx context.Context, slug string) ([]segments.RampStep, error) {
+	const fn = "repo.segments.Ramp"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT apply_at, buckets FROM segment_rollout_steps WHERE segment_id=resolve_segment($1) ORDER BY apply_at"
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, slug)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.RampStep, 0)
+	for rows.Next() {
+		var step segments.RampStep
+		var buckets int
+		if err := rows.Scan(&step.At, &buckets); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		step.Percent = segments.PercentOf(buckets)
+		res = append(res, step)
+	}
+	return res, rows.Err()
+}
+
+// AdvanceRollouts applies the ramp steps due by now and returns the changes. Only the last due step
+// of a segment is applied, and members of the segment are recomputed by the next resync. Concurrent callers get different steps
+func (r Repo) AdvanceRollouts(ctx context.Context, now time.Time) ([]segments.RolloutChange, error) {
+	const fn = "repo.segments.AdvanceRollouts"
+	defer metrics.ObserveQuery(fn, time.Now())
+	// the select of prev sees the percents before the update
+	const query = `WITH due AS (
+                       SELECT segment_id, apply_at, buckets FROM segment_rollout_steps WHERE apply_at <= $1
+                       FOR UPDATE SKIP LOCKED
+                   ), applied AS (
+                       DELETE FROM segment_rollout_steps steps USING due
+                       WHERE steps.segment_id = due.segment_id AND steps.apply_at = due.apply_at
+                   ), latest AS (
+                       SELECT DISTINCT ON (segment_id) segment_id, apply_at, buckets FROM due
+                       ORDER BY segment_id, apply_at DESC
+                   ), prev AS (
+                       SELECT segments.id, segments.rollout_buckets FROM segments
+                       JOIN latest ON latest.segment_id = segments.id FOR UPDATE OF segments
+                   )
+                   UPDATE segments SET rollout_buckets=latest.buckets, updated_at=now(), resync_at=now() FROM latest, prev
+                   WHERE segments.id = latest.segment_id AND prev.id = segments.id
+                   RETURNING segments.slug, prev.rollout_buckets, latest.buckets, latest.apply_at`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, now)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.RolloutChange, 0)
+	for rows.Next() {
+		var ch segments.RolloutChange
+		var from *int
+		var to int
+		if err := rows.Scan(&ch.Segment, &from, &to, &ch.At); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		if from != nil {
+			p := segments.PercentOf(*from)
+			ch.From = &p
+		}
+		p := segments.PercentOf(to)
+		ch.To = &p
+		res = append(res, ch)
+	}
+	return res, rows.Err()
+}
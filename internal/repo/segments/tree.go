@@ -0,0 +1,133 @@
+package segments
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"time"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+const constrParent = "segments_parent_id_fkey"
+
+// lockTrees serializes changes of parents and archiving in the transaction,
+// so concurrent changes neither make a cycle together nor archive a parent of a new child
+func lockTrees(ctx context.Context, db repo.DB) error {
+	_, err := db.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('segment_trees'))")
+	return err
+}
+
+// SetParent makes the segment a child of the parent, empty parent makes the segment a root.
+// The parent must not be archived and must not be the segment or one of its descendants
+func (r Repo) SetParent(ctx context.Context, slug string, parent string) (segments.Segment, error) {
+	const fn = "repo.segments.SetParent"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const (
+		lockQuery   = "SELECT id FROM segments WHERE id=resolve_segment($1) FOR UPDATE"
+		parentQuery = "SELECT id, state FROM segments WHERE id=resolve_segment($1) FOR SHARE"
+		cycleQuery  = `WITH RECURSIVE ancestors AS (
+                           SELECT id, parent_id FROM segments WHERE id=$1
+                           UNION
+                           SELECT segments.id, segments.parent_id FROM segments
+                           JOIN ancestors ON segments.id = ancestors.parent_id)
+                       SELECT EXISTS (SELECT 1 FROM ancestors WHERE id=$2)`
+		updateQuery = `UPDATE segments SET parent_id=$2, updated_at=now() WHERE id=$1 RETURNING ` + segmentColumns
+	)
+	var seg segments.Segment
+	err := repo.NewTransactor(r.db).WithinTx(ctx, func(ctx context.Context) error {
+		db := repo.Conn(ctx, r.db)
+		if err := lockTrees(ctx, db); err != nil {
+			return err
+		}
+		var id int64
+		if err := db.QueryRow(ctx, lockQuery, slug).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return repo.ErrSegmentNotFound
+			}
+			return err
+		}
+		var parentID *int64
+		if parent != "" {
+			var pid int64
+			var state segments.State
+			if err := db.QueryRow(ctx, parentQuery, parent).Scan(&pid, &state); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return repo.ErrParentNotFound
+				}
+				return err
+			}
+			if state == segments.Archived {
+				return segments.ErrParentArchived
+			}
+			var cycle bool
+			if err := db.QueryRow(ctx, cycleQuery, pid, id).Scan(&cycle); err != nil {
+				return err
+			}
+			if cycle {
+				return segments.ErrCycle
+			}
+			parentID = &pid
+		}
+		var err error
+		seg, err = scanSegment(db.QueryRow(ctx, updateQuery, id, parentID))
+		return err
+	})
+	if err != nil && !errors.Is(err, repo.ErrSegmentNotFound) && !errors.Is(err, repo.ErrParentNotFound) &&
+		!errors.Is(err, segments.ErrParentArchived) && !errors.Is(err, segments.ErrCycle) {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return seg, err
+}
+
+// Ancestors returns active ancestors of the segments ordered by slug, except the segments themselves.
+// Returned segments are implied and have only slugs
+func (r Repo) Ancestors(ctx context.Context, slugs []string) ([]segments.Segment, error) {
+	const fn = "repo.segments.Ancestors"
+	defer metrics.ObserveQuery(fn, time.Now())
+	// ancestors are implied through segments in any state, but only active ones are returned like in GetUserSegments
+	const query = `WITH RECURSIVE ancestors AS (
+                       SELECT parent_id AS id FROM segments WHERE slug = ANY($1) AND parent_id IS NOT NULL
+                       UNION
+                       SELECT segments.parent_id FROM segments
+                       JOIN ancestors ON segments.id = ancestors.id WHERE segments.parent_id IS NOT NULL)
+                   SELECT slug FROM segments WHERE id IN (SELECT id FROM ancestors) AND NOT slug = ANY($1)
+                   AND state=$2 AND (active_from IS NULL OR active_from <= now())
+                   AND (active_until IS NULL OR active_until > now())
+                   ORDER BY slug`
+	rows, err := repo.Conn(ctx, r.db).Query(ctx, query, slugs, segments.Active)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]segments.Segment, 0)
+	for rows.Next() {
+		seg := segments.Segment{Implied: true}
+		if err := rows.Scan(&seg.Slug); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, seg)
+	}
+	return res, rows.Err()
+}
+
+// hasLiveChildren tells if the segment has children that are not archived
+func (r Repo) hasLiveChildren(ctx context.Context, slug string) (bool, error) {
+	const query = "SELECT EXISTS (SELECT 1 FROM segments WHERE parent_id=resolve_segment($1) AND state<>$2)"
+	var res bool
+	err := repo.Conn(ctx, r.db).QueryRow(ctx, query, slug, segments.Archived).Scan(&res)
+	return res, err
+}
+
+// hasArchivedParent tells if the parent of the segment is archived
+func (r Repo) hasArchivedParent(ctx context.Context, slug string) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM segments JOIN segments parents ON parents.id = segments.parent_id
+                   WHERE segments.id=resolve_segment($1) AND parents.state=$2)`
+	var res bool
+	err := repo.Conn(ctx, r.db).QueryRow(ctx, query, slug, segments.Archived).Scan(&res)
+	return res, err
+}
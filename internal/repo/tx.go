@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DB is implemented by both the pool and the transaction
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx          pgx.Tx
+	afterCommit []func()
+}
+
+// Conn returns the transaction started by Transactor.WithinTx, or the pool outside of transactions
+func Conn(ctx context.Context, db *pgxpool.Pool) DB {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		return st.tx
+	}
+	return db
+}
+
+// AfterCommit runs fn once the transaction is committed, or right away outside of transactions.
+// It is not run if the transaction is rolled back
+func AfterCommit(ctx context.Context, fn func()) {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		st.afterCommit = append(st.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+type Transactor struct {
+	db *pgxpool.Pool
+}
+
+// WithinTx runs fn in a transaction, which repositories get by Conn. It commits if fn returns nil.
+// Nested calls join the outer transaction
+func (t Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+	tx, err := t.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+	st := &txState{tx: tx}
+	if err := fn(context.WithValue(ctx, txKey{}, st)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	for _, f := range st.afterCommit {
+		f()
+	}
+	return nil
+}
+
+func NewTransactor(db *pgxpool.Pool) Transactor {
+	return Transactor{db: db}
+}
@@ -0,0 +1,198 @@
+package webhooks
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/webhooks"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/repo"
+)
+
+type Repo struct {
+	db *pgxpool.Pool
+}
+
+func (r Repo) Store(ctx context.Context, w webhooks.Webhook) (int64, error) {
+	const fn = "repo.webhooks.Store"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `INSERT INTO webhooks (url, secret, segments, operations, created_at) VALUES ($1, $2, $3, $4, $5)
+                   RETURNING id`
+	ops := make([]int16, len(w.Operations))
+	for i := range ops {
+		ops[i] = int16(w.Operations[i])
+	}
+	var id int64
+	err := r.db.QueryRow(ctx, query, w.URL, w.Secret, w.Segments, ops, w.CreatedAt).Scan(&id)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return id, err
+}
+
+func (r Repo) List(ctx context.Context) ([]webhooks.Webhook, error) {
+	const fn = "repo.webhooks.List"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = "SELECT id, url, segments, operations, created_at FROM webhooks ORDER BY id"
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]webhooks.Webhook, 0)
+	for rows.Next() {
+		var w webhooks.Webhook
+		var ops []int16
+		if err := rows.Scan(&w.ID, &w.URL, &w.Segments, &ops, &w.CreatedAt); err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		w.Operations = make([]operations.Type, len(ops))
+		for i := range ops {
+			w.Operations[i] = operations.Type(ops[i])
+		}
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
+
+func (r Repo) Delete(ctx context.Context, id int64) error {
+	const fn = "repo.webhooks.Delete"
+	defer metrics.ObserveQuery(fn, time.Now())
+	cmd, err := r.db.Exec(ctx, "DELETE FROM webhooks WHERE id=$1", id)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repo.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deliveries returns up to limit latest deliveries of the webhook. Nil status matches any status
+func (r Repo) Deliveries(ctx context.Context, webhookID int64, status *webhooks.Status, limit int) ([]webhooks.Delivery, error) {
+	const fn = "repo.webhooks.Deliveries"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `SELECT id, webhook_id, operation_id, payload, status, attempts, next_attempt_at,
+                          last_status, last_error, created_at, delivered_at
+                   FROM webhook_deliveries
+                   WHERE webhook_id=$1 AND ($2::SMALLINT IS NULL OR status=$2)
+                   ORDER BY id DESC LIMIT $3`
+	var exists bool
+	err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM webhooks WHERE id=$1)", webhookID).Scan(&exists)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	if !exists {
+		return nil, repo.ErrWebhookNotFound
+	}
+	rows, err := r.db.Query(ctx, query, webhookID, status, limit)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]webhooks.Delivery, 0)
+	for rows.Next() {
+		var d webhooks.Delivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.OperationID, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+// Retry queues the delivered or dead delivery again
+func (r Repo) Retry(ctx context.Context, webhookID int64, deliveryID int64) error {
+	const fn = "repo.webhooks.Retry"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE webhook_deliveries SET status=$3, attempts=0, next_attempt_at=$4
+                   WHERE id=$1 AND webhook_id=$2 AND status<>$3`
+	cmd, err := r.db.Exec(ctx, query, deliveryID, webhookID, webhooks.Pending, time.Now().UTC())
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return repo.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// Claim returns up to limit due deliveries and postpones them until leaseUntil,
+// so other replicas do not send them at the same time
+func (r Repo) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]webhooks.Job, error) {
+	const fn = "repo.webhooks.Claim"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE webhook_deliveries SET next_attempt_at=$3
+                   FROM webhooks
+                   WHERE webhook_deliveries.id IN (
+                       SELECT id FROM webhook_deliveries
+                       WHERE status=$1 AND next_attempt_at<=$2
+                       ORDER BY next_attempt_at
+                       LIMIT $4 FOR UPDATE SKIP LOCKED
+                   ) AND webhooks.id=webhook_deliveries.webhook_id
+                   RETURNING webhook_deliveries.id, webhook_id, operation_id, payload, attempts, url, secret`
+	rows, err := r.db.Query(ctx, query, webhooks.Pending, time.Now().UTC(), leaseUntil.UTC(), limit)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]webhooks.Job, 0)
+	for rows.Next() {
+		var j webhooks.Job
+		err := rows.Scan(&j.ID, &j.WebhookID, &j.OperationID, &j.Payload, &j.Attempts, &j.URL, &j.Secret)
+		if err != nil {
+			logger.InternalErr(ctx, err, fn)
+			return nil, err
+		}
+		res = append(res, j)
+	}
+	return res, rows.Err()
+}
+
+// Complete marks the delivery as delivered
+func (r Repo) Complete(ctx context.Context, id int64, statusCode int) error {
+	const fn = "repo.webhooks.Complete"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE webhook_deliveries
+                   SET status=$2, attempts=attempts+1, last_status=$3, last_error=NULL, delivered_at=$4
+                   WHERE id=$1`
+	_, err := r.db.Exec(ctx, query, id, webhooks.Delivered, statusCode, time.Now().UTC())
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
+
+// Fail records the failed attempt. The delivery is retried at retryAt or becomes dead if retryAt is nil
+func (r Repo) Fail(ctx context.Context, id int64, statusCode *int, reason string, retryAt *time.Time) error {
+	const fn = "repo.webhooks.Fail"
+	defer metrics.ObserveQuery(fn, time.Now())
+	const query = `UPDATE webhook_deliveries
+                   SET status=$2, attempts=attempts+1, last_status=$3, last_error=$4, next_attempt_at=COALESCE($5, next_attempt_at)
+                   WHERE id=$1`
+	status := webhooks.Pending
+	if retryAt == nil {
+		status = webhooks.Dead
+	}
+	_, err := r.db.Exec(ctx, query, id, status, statusCode, reason, retryAt)
+	if err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+	return err
+}
+
+func New(db *pgxpool.Pool) Repo {
+	return Repo{db: db}
+}
@@ -0,0 +1,63 @@
+// Package schedule records segments entering and leaving their schedules, applies steps of rollout ramps
+// and recomputes members of segments whose rollouts and rules changed
+package schedule
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/logger"
+)
+
+type Switcher interface {
+	SwitchSchedules(ctx context.Context, now time.Time) ([]segments.Transition, error)
+	AdvanceRollouts(ctx context.Context, now time.Time) ([]segments.RolloutChange, error)
+	SyncDynamicSegments(ctx context.Context) ([]segments.Resync, error)
+}
+
+// Scheduler switches schedules and advances rollouts every interval. Users get segments by their schedules at once,
+// but transitions are recorded in history with a delay up to the interval. Ramp steps are applied with the same delay,
+// and members of segments with changed rollouts and rules are recomputed after them
+type Scheduler struct {
+	Switcher Switcher
+	Interval time.Duration
+}
+
+// Run switches schedules, advances rollouts and recomputes members every interval until ctx is done
+func (s Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s Scheduler) tick(ctx context.Context, now time.Time) {
+	// the service logs internal errors, the next tick retries
+	transitions, err := s.Switcher.SwitchSchedules(ctx, now)
+	if err == nil {
+		for _, tr := range transitions {
+			logger.Log(ctx).InfoContext(ctx, "segment switched by schedule",
+				slog.String("segment", tr.Segment), slog.Bool("active", tr.Activate))
+		}
+	}
+	changes, err := s.Switcher.AdvanceRollouts(ctx, now)
+	if err == nil {
+		for _, ch := range changes {
+			logger.Log(ctx).InfoContext(ctx, "segment rollout advanced", slog.String("segment", ch.Segment),
+				slog.String("from", segments.FormatPercent(ch.From)), slog.String("to", segments.FormatPercent(ch.To)))
+		}
+	}
+	// segments recomputed before an error are logged as well
+	resyncs, _ := s.Switcher.SyncDynamicSegments(ctx)
+	for _, r := range resyncs {
+		logger.Log(ctx).InfoContext(ctx, "segment members recomputed", slog.String("segment", r.Segment),
+			slog.Int("added", r.Added), slog.Int("removed", r.Removed))
+	}
+}
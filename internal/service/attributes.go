@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/tracing"
+)
+
+const (
+	maxBulkUsers        = 1000
+	maxAttrHistoryLimit = 1000
+)
+
+var (
+	ErrAttributesDisabled = errors.New("user attributes are disabled")
+	ErrTooManyUsers       = errors.New("at most 1000 users may be changed at once")
+	ErrInvalidLimit       = errors.New("limit must be between 1 and 1000")
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=AttributesRepo
+type AttributesRepo interface {
+	Define(ctx context.Context, d attributes.Definition) (attributes.Definition, error)
+	Definitions(ctx context.Context) ([]attributes.Definition, error)
+	DeleteDefinition(ctx context.Context, name string) error
+	Upsert(ctx context.Context, changes map[int64]attributes.Attributes, replace bool) (map[int64]attributes.Attributes, error)
+	Get(ctx context.Context, userID int64) (attributes.Attributes, error)
+	History(ctx context.Context, userID int64, limit int) ([]attributes.Change, error)
+}
+
+// WithAttributes enables attributes of users and segments with rules matching them
+func WithAttributes(a AttributesRepo) Option {
+	return func(s *Service) {
+		s.Attributes = a
+	}
+}
+
+// DefineAttribute adds the attribute to the schema, users may have only defined attributes
+func (s Service) DefineAttribute(ctx context.Context, name string, typ string, description string) (_ attributes.Definition, err error) {
+	ctx, span := tracer.Start(ctx, "Service.DefineAttribute", trace.WithAttributes(
+		attribute.String("attribute", name),
+		attribute.String("type", typ),
+	))
+	defer func() { tracing.End(span, err) }()
+	if s.Attributes == nil {
+		return attributes.Definition{}, ErrAttributesDisabled
+	}
+	d, err := attributes.NewDefinition(name, typ, description)
+	if err != nil {
+		return attributes.Definition{}, err
+	}
+	return s.Attributes.Define(ctx, d)
+}
+
+func (s Service) ListAttributes(ctx context.Context) (_ []attributes.Definition, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListAttributes")
+	defer func() { tracing.End(span, err) }()
+	if s.Attributes == nil {
+		return nil, ErrAttributesDisabled
+	}
+	return s.Attributes.Definitions(ctx)
+}
+
+// DeleteAttribute removes the attribute from the schema. The attribute set for any user is not removed,
+// the deletion is rejected with attributes.ErrInUse until the attribute is unset for all users
+func (s Service) DeleteAttribute(ctx context.Context, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.DeleteAttribute", trace.WithAttributes(attribute.String("attribute", name)))
+	defer func() { tracing.End(span, err) }()
+	if s.Attributes == nil {
+		return ErrAttributesDisabled
+	}
+	return s.Attributes.DeleteDefinition(ctx, name)
+}
+
+func (s Service) schema(ctx context.Context) (attributes.Schema, error) {
+	defs, err := s.Attributes.Definitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return attributes.NewSchema(defs), nil
+}
+
+func (s Service) GetUserAttributes(ctx context.Context, userID int64) (_ attributes.Attributes, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserAttributes", trace.WithAttributes(attribute.Int64("user_id", userID)))
+	defer func() { tracing.End(span, err) }()
+	if s.Attributes == nil {
+		return nil, ErrAttributesDisabled
+	}
+	return s.Attributes.Get(ctx, userID)
+}
+
+// SetUserAttributes replaces the attributes of the user and updates membership of the user in rule segments
+func (s Service) SetUserAttributes(ctx context.Context, userID int64, values map[string]any) (_ attributes.Attributes, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetUserAttributes", trace.WithAttributes(
+		attribute.Int64("user_id", userID),
+		attribute.Int("attributes", len(values)),
+	))
+	defer func() { tracing.End(span, err) }()
+	if s.Attributes == nil {
+		return nil, ErrAttributesDisabled
+	}
+	schema, err := s.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := schema.Validate(values)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.Attributes.Upsert(ctx, map[int64]attributes.Attributes{userID: attrs}, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncDynamic(ctx, userID); err != nil {
+		// the attributes are stored, membership is updated by the next change of the attributes or the segments
+		span.RecordError(err)
+	}
+	return res[userID], nil
+}
+
+// UpsertUserAttributes sets the attributes of the users keeping their other attributes, nil values remove
+// attributes. Changes of all users are applied atomically, then membership of every user in rule segments is updated
+func (s Service) UpsertUserAttributes(ctx context.Context, values map[int64]map[string]any) (_ map[int64]attributes.Attributes, err error) {
+	ctx, span := tracer.Start(ctx, "Service.UpsertUserAttributes", trace.WithAttributes(attribute.Int("users", len(values))))
+	defer func() { tracing.End(span, err) }()
+	if s.Attributes == nil {
+		return nil, ErrAttributesDisabled
+	}
+	if len(values) > maxBulkUsers {
+		return nil, ErrTooManyUsers
+	}
+	schema, err := s.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[int64]attributes.Attributes, len(values))
+	for userID, v := range values {
+		if changes[userID], err = schema.Validate(v); err != nil {
+			return nil, err
+		}
+	}
+	res, err := s.Attributes.Upsert(ctx, changes, false)
+	if err != nil {
+		return nil, err
+	}
+	for userID := range res {
+		if err := s.syncDynamic(ctx, userID); err != nil {
+			// the attributes are stored, membership is updated by the next change of the attributes or the segments
+			span.RecordError(err)
+		}
+	}
+	return res, nil
+}
+
+// GetUserAttributeHistory returns the latest changes of attributes of the user, newest first
+func (s Service) GetUserAttributeHistory(ctx context.Context, userID int64, limit int) (_ []attributes.Change, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserAttributeHistory", trace.WithAttributes(attribute.Int64("user_id", userID)))
+	defer func() { tracing.End(span, err) }()
+	if s.Attributes == nil {
+		return nil, ErrAttributesDisabled
+	}
+	if limit < 1 || limit > maxAttrHistoryLimit {
+		return nil, ErrInvalidLimit
+	}
+	return s.Attributes.History(ctx, userID, limit)
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"time"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/rules"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/tracing"
+)
+
+const (
+	// resyncBatch is the number of users read at once by the recomputation of members of a segment
+	resyncBatch = 1000
+	// resyncLease is the time a segment is claimed by the replica recomputing it. An expired lease lets
+	// another replica take the segment over, changes applied twice are rejected by ChangeUserSegments
+	resyncLease = 10 * time.Minute
+)
+
+// PendingResync is the segment whose members are to be recomputed after its rollout or rule changed at the time
+type PendingResync struct {
+	Segment segments.Segment
+	At      time.Time
+}
+
+// Candidate is a known user with the attributes and membership in the recomputed segment
+type Candidate struct {
+	UserID     int64
+	Attributes attributes.Attributes
+	Member     bool
+}
+
+// DynamicSegment is an active segment with a rollout or a rule, evaluated for users on reads of their segments
+type DynamicSegment struct {
+	segments.Segment
+	// Exclusive is set for segments of exclusive groups
+	Exclusive bool
+}
+
+// withDynamic replaces recorded membership of the user in active segments with rollouts and rules by matching
+// the user now, so users are in them before SyncDynamicSegments or their changes record it, and users without
+// attributes match negated rules. In an exclusive group the user keeps a segment without a rollout and a rule,
+// then a recorded dynamic segment, then the first matching dynamic segment by slug. Without attributes
+// membership in rule segments is kept as recorded
+func (s Service) withDynamic(ctx context.Context, userID int64, segs []segments.Segment) ([]segments.Segment, error) {
+	dynamic, err := s.Segments.ActiveDynamic(ctx)
+	if err != nil || len(dynamic) == 0 {
+		return segs, err
+	}
+	now := time.Now()
+	var attrs attributes.Attributes
+	matched := make(map[string]bool, len(dynamic))
+	for _, seg := range dynamic {
+		if seg.Rule != "" && s.Attributes == nil {
+			continue
+		}
+		if seg.Rule != "" && attrs == nil {
+			if attrs, err = s.Attributes.Get(ctx, userID); err != nil {
+				return nil, err
+			}
+		}
+		in, _ := matchDynamic(seg.Segment, userID, attrs)
+		matched[seg.Slug] = in && seg.ActiveAt(now)
+	}
+	res := make([]segments.Segment, 0, len(segs)+len(dynamic))
+	recorded := make(map[string]segments.Segment)
+	held := make(map[string]bool)
+	for _, seg := range segs {
+		if _, ok := matched[seg.Slug]; ok {
+			recorded[seg.Slug] = seg
+			continue
+		}
+		res = append(res, seg)
+		held[seg.Group] = true
+	}
+	for _, first := range []bool{true, false} {
+		for _, seg := range dynamic {
+			rec, ok := recorded[seg.Slug]
+			if !matched[seg.Slug] || ok != first || seg.Exclusive && held[seg.Group] {
+				continue
+			}
+			if !ok {
+				rec = segments.Segment{Slug: seg.Slug, Group: seg.Group}
+			}
+			res = append(res, rec)
+			held[seg.Group] = true
+		}
+	}
+	return res, nil
+}
+
+// syncDynamic adds the user to segments with rollouts and rules the user matches and removes the user
+// from the others. A segment with both takes users matching the rule within the percent. Changes are logged
+// to history with the rule and the percent in details. Every segment is changed separately, so a change
+// rejected by an exclusive group does not hold back the others. Without attributes rule segments are skipped
+func (s Service) syncDynamic(ctx context.Context, userID int64) error {
+	ctx, span := tracer.Start(ctx, "sync dynamic segments")
+	defer span.End()
+	segs, members, err := s.Segments.DynamicForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var attrs attributes.Attributes
+	changed := 0
+	for _, seg := range segs {
+		if seg.Rule != "" && s.Attributes == nil {
+			continue
+		}
+		if seg.Rule != "" && attrs == nil {
+			if attrs, err = s.Attributes.Get(ctx, userID); err != nil {
+				return err
+			}
+		}
+		in, details := matchDynamic(seg, userID, attrs)
+		if in == members[seg.Slug] {
+			continue
+		}
+		ok, err := s.applyDynamic(ctx, userID, seg, in, details)
+		if err != nil {
+			return err
+		}
+		if ok {
+			changed++
+		}
+	}
+	span.SetAttributes(attribute.Int("changed", changed))
+	return nil
+}
+
+// applyDynamic adds the user to the segment or removes the user from it with the details in history.
+// Returns false if the change is rejected
+func (s Service) applyDynamic(ctx context.Context, userID int64, seg segments.Segment, in bool, details map[string]string) (bool, error) {
+	var add, remove []segments.Segment
+	opType := operations.Remove
+	if in {
+		add, opType = []segments.Segment{seg}, operations.Add
+	} else {
+		remove = []segments.Segment{seg}
+	}
+	op, _ := operations.New(userID, seg, opType)
+	op.Details = details
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		if errs := s.Segments.ChangeUserSegments(ctx, userID, add, remove); len(errs) != 0 {
+			return errRejected
+		}
+		if err := s.History.Put(ctx, []operations.Operation{op}); err != nil {
+			return err
+		}
+		return s.putEvents(ctx, []operations.Operation{op})
+	})
+	if errors.Is(err, errRejected) {
+		// a concurrent sync has applied the change, or the user is in another segment of the exclusive group
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SyncDynamicSegments recomputes members of segments whose rollouts or rules changed for all known users
+// and returns the numbers of added and removed members. Users are known by their segments and attributes.
+// Segments are claimed one by one, so replicas recompute different segments. A segment changed again
+// during the recomputation is recomputed by the next call
+func (s Service) SyncDynamicSegments(ctx context.Context) (_ []segments.Resync, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SyncDynamicSegments")
+	defer func() { tracing.End(span, err) }()
+	res := make([]segments.Resync, 0)
+	for {
+		claimed, err := s.Segments.ClaimResync(ctx, 1, time.Now().Add(resyncLease))
+		if err != nil || len(claimed) == 0 {
+			return res, err
+		}
+		p := claimed[0]
+		seg := p.Segment
+		if seg.Rule != "" && s.Attributes == nil {
+			continue
+		}
+		resync := segments.Resync{Segment: seg.Slug}
+		// a segment without a rollout and a rule keeps its members as explicit ones
+		for after := (*int64)(nil); seg.Rollout != nil || seg.Rule != ""; {
+			users, err := s.Segments.Candidates(ctx, seg.Slug, after, resyncBatch)
+			if err != nil {
+				return res, err
+			}
+			for _, u := range users {
+				in, details := matchDynamic(seg, u.UserID, u.Attributes)
+				if in == u.Member {
+					continue
+				}
+				ok, err := s.applyDynamic(ctx, u.UserID, seg, in, details)
+				if err != nil {
+					return res, err
+				}
+				if ok && in {
+					resync.Added++
+				} else if ok {
+					resync.Removed++
+				}
+			}
+			if len(users) < resyncBatch {
+				break
+			}
+			after = &users[len(users)-1].UserID
+		}
+		if err := s.Segments.FinishResync(ctx, seg.Slug, p.At); err != nil {
+			return res, err
+		}
+		res = append(res, resync)
+	}
+}
+
+// matchDynamic reports whether the user belongs to the segment by its rule and rollout
+// and returns the details of the change of membership
+func matchDynamic(seg segments.Segment, userID int64, attrs attributes.Attributes) (bool, map[string]string) {
+	in := true
+	details := make(map[string]string)
+	if seg.Rule != "" {
+		// stored rules are valid, a rule failing to parse matches nobody
+		rule, _ := rules.Parse(seg.Rule)
+		in = rule.Match(attrs)
+		details[operations.DetailRule] = seg.Rule
+	}
+	if seg.Rollout != nil {
+		in = in && seg.Rollout.Includes(userID)
+		details[operations.DetailRollout] = segments.FormatPercent(&seg.Rollout.Percent)
+	}
+	return in, details
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/tracing"
+)
+
+var ErrExperimentsDisabled = errors.New("experiments are disabled")
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=ExperimentsRepo
+type ExperimentsRepo interface {
+	Store(ctx context.Context, exp experiments.Experiment) error
+	Get(ctx context.Context, key string) (experiments.Experiment, error)
+	List(ctx context.Context) ([]experiments.Experiment, error)
+	ForUser(ctx context.Context, userID int64) ([]experiments.Experiment, map[string]string, error)
+}
+
+// WithExperiments enables experiments. Users are assigned to variants when their segments are read.
+// The list of experiments is checked on every read, so the repository should cache it
+func WithExperiments(e ExperimentsRepo) Option {
+	return func(s *Service) {
+		s.Experiments = e
+	}
+}
+
+// CreateExperiment stores the experiment with active segments of its variants
+func (s Service) CreateExperiment(ctx context.Context, key string, description string, variants []experiments.Variant) (_ experiments.Experiment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateExperiment", trace.WithAttributes(
+		attribute.String("experiment", key),
+		attribute.Int("variants", len(variants)),
+	))
+	defer func() { tracing.End(span, err) }()
+	if s.Experiments == nil {
+		return experiments.Experiment{}, ErrExperimentsDisabled
+	}
+	exp, err := experiments.New(key, description, variants)
+	if err != nil {
+		return experiments.Experiment{}, err
+	}
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		for _, v := range exp.Variants {
+			if err := s.Segments.Store(ctx, v.Segment); err != nil {
+				return err
+			}
+		}
+		if err := s.Experiments.Store(ctx, exp); err != nil {
+			return err
+		}
+		exp, err = s.Experiments.Get(ctx, key)
+		return err
+	})
+	if err != nil {
+		return experiments.Experiment{}, err
+	}
+	return exp, nil
+}
+
+func (s Service) GetExperiment(ctx context.Context, key string) (_ experiments.Experiment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetExperiment", trace.WithAttributes(attribute.String("experiment", key)))
+	defer func() { tracing.End(span, err) }()
+	if s.Experiments == nil {
+		return experiments.Experiment{}, ErrExperimentsDisabled
+	}
+	return s.Experiments.Get(ctx, key)
+}
+
+func (s Service) ListExperiments(ctx context.Context) (_ []experiments.Experiment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListExperiments")
+	defer func() { tracing.End(span, err) }()
+	if s.Experiments == nil {
+		return nil, ErrExperimentsDisabled
+	}
+	return s.Experiments.List(ctx)
+}
+
+// assignExperiments adds the user to the variants of experiments the user is not assigned to yet and tells
+// if the user is added. Segments of the user are checked first, so assigned users are not looked up.
+// Variants of archived segments are skipped. Assignments are logged to history as additions
+// with the experiment and the variant in details
+func (s Service) assignExperiments(ctx context.Context, userID int64, segs []segments.Segment) (bool, error) {
+	ctx, span := tracer.Start(ctx, "assign experiments")
+	defer span.End()
+	missing, err := s.missingVariant(ctx, userID, segs)
+	if err != nil || !missing {
+		return false, err
+	}
+	exps, assigned, err := s.Experiments.ForUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	var add []segments.Segment
+	var ops []operations.Operation
+	for _, exp := range exps {
+		if _, ok := assigned[exp.Key]; ok {
+			continue
+		}
+		v := exp.Assign(userID)
+		if v.Name == "" || v.Segment.State == segments.Archived {
+			continue
+		}
+		add = append(add, v.Segment)
+		op, _ := operations.New(userID, v.Segment, operations.Add)
+		op.Details = map[string]string{operations.DetailExperiment: exp.Key, operations.DetailVariant: v.Name}
+		ops = append(ops, op)
+	}
+	span.SetAttributes(attribute.Int("assigned", len(add)))
+	if len(add) == 0 {
+		return false, nil
+	}
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if errs := s.Segments.ChangeUserSegments(ctx, userID, add, nil); len(errs) != 0 {
+			return errRejected
+		}
+		if err := s.History.Put(ctx, ops); err != nil {
+			return err
+		}
+		return s.putEvents(ctx, ops)
+	})
+	if errors.Is(err, errRejected) {
+		// a concurrent read has assigned the user to the same variants
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// missingVariant tells if the user would be assigned to an active variant of an experiment
+// none of the segments of the user belongs to
+func (s Service) missingVariant(ctx context.Context, userID int64, segs []segments.Segment) (bool, error) {
+	exps, err := s.Experiments.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	seen := make(map[string]bool, len(segs))
+	for _, seg := range segs {
+		if seg.Variant != nil {
+			seen[seg.Variant.Experiment] = true
+		}
+	}
+	for _, exp := range exps {
+		if seen[exp.Key] {
+			continue
+		}
+		if v := exp.Assign(userID); v.Name != "" && v.Segment.State == segments.Active {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"user-segmentation/internal/entities/flags"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/tracing"
+)
+
+var ErrFlagsDisabled = errors.New("feature flags are disabled")
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=FlagsRepo
+type FlagsRepo interface {
+	SetOverride(ctx context.Context, userID int64, key string, value any) error
+	DeleteOverride(ctx context.Context, userID int64, key string) error
+	Overrides(ctx context.Context, userID int64, keys []string) (map[string]any, error)
+	Known(ctx context.Context, keys []string) (map[string]flags.Source, error)
+}
+
+// WithFlags enables evaluation of feature flags and their overrides for users
+func WithFlags(f FlagsRepo) Option {
+	return func(s *Service) {
+		s.Flags = f
+	}
+}
+
+// GetUserFlags evaluates the flags of the user by overrides, experiments and segments of the user
+// including the implied ones. Rollouts and rules of segments are matched by the call, so users get their flags
+// before their membership is recorded. Flags are returned in the order of the keys
+func (s Service) GetUserFlags(ctx context.Context, userID int64, keys []string) (_ []flags.Flag, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserFlags", trace.WithAttributes(
+		attribute.Int64("user_id", userID),
+		attribute.Int("keys", len(keys)),
+	))
+	defer func() { tracing.End(span, err) }()
+	if s.Flags == nil {
+		return nil, ErrFlagsDisabled
+	}
+	segs, err := s.GetUserImpliedSegments(ctx, userID)
+	if err != nil && !errors.Is(err, repo.ErrNoSegments) {
+		return nil, err
+	}
+	user := flags.User{Segments: make([]string, len(segs)), Variants: make(map[string]string)}
+	for i, seg := range segs {
+		user.Segments[i] = seg.Slug
+		if seg.Variant != nil {
+			user.Variants[seg.Variant.Experiment] = seg.Variant.Variant
+		}
+	}
+	if user.Overrides, err = s.Flags.Overrides(ctx, userID, keys); err != nil {
+		return nil, err
+	}
+	known, err := s.Flags.Known(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return flags.Evaluate(keys, user, known), nil
+}
+
+// SetFlagOverride sets the value of the flag for the user, it wins over experiments and segments
+func (s Service) SetFlagOverride(ctx context.Context, userID int64, key string, value any) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetFlagOverride", trace.WithAttributes(
+		attribute.Int64("user_id", userID),
+		attribute.String("flag", key),
+	))
+	defer func() { tracing.End(span, err) }()
+	if s.Flags == nil {
+		return ErrFlagsDisabled
+	}
+	value, err = flags.NewOverride(key, value)
+	if err != nil {
+		return err
+	}
+	return s.Flags.SetOverride(ctx, userID, key, value)
+}
+
+func (s Service) DeleteFlagOverride(ctx context.Context, userID int64, key string) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.DeleteFlagOverride", trace.WithAttributes(
+		attribute.Int64("user_id", userID),
+		attribute.String("flag", key),
+	))
+	defer func() { tracing.End(span, err) }()
+	if s.Flags == nil {
+		return ErrFlagsDisabled
+	}
+	return s.Flags.DeleteOverride(ctx, userID, key)
+}
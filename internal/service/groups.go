@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/tracing"
+)
+
+// CreateGroup stores the group. Users are in at most one segment of an exclusive group
+func (s Service) CreateGroup(ctx context.Context, name string, exclusive bool) (_ segments.Group, err error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateGroup", trace.WithAttributes(
+		attribute.String("group", name),
+		attribute.Bool("exclusive", exclusive),
+	))
+	defer func() { tracing.End(span, err) }()
+	g, err := segments.NewGroup(name, exclusive)
+	if err != nil {
+		return segments.Group{}, err
+	}
+	if err := s.Segments.StoreGroup(ctx, g); err != nil {
+		return segments.Group{}, err
+	}
+	return g, nil
+}
+
+func (s Service) ListGroups(ctx context.Context) (_ []segments.Group, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListGroups")
+	defer func() { tracing.End(span, err) }()
+	return s.Segments.ListGroups(ctx)
+}
+
+// DeleteGroup deletes the group, its segments and their users are kept
+func (s Service) DeleteGroup(ctx context.Context, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.DeleteGroup", trace.WithAttributes(attribute.String("group", name)))
+	defer func() { tracing.End(span, err) }()
+	return s.Segments.DeleteGroup(ctx, name)
+}
+
+// SetSegmentGroup moves the segment to the group, empty group removes the segment from its group
+func (s Service) SetSegmentGroup(ctx context.Context, slug string, group string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetSegmentGroup", trace.WithAttributes(
+		attribute.String("segment", slug),
+		attribute.String("group", group),
+	))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	if group != "" {
+		g, err := segments.NewGroup(group, false)
+		if err != nil {
+			return segments.Segment{}, err
+		}
+		group = g.Name
+	}
+	return s.Segments.SetGroup(ctx, slug, group)
+}
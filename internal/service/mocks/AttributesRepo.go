@@ -0,0 +1,171 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	attributes "user-segmentation/internal/entities/attributes"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AttributesRepo is an autogenerated mock type for the AttributesRepo type
+type AttributesRepo struct {
+	mock.Mock
+}
+
+// Define provides a mock function with given fields: ctx, d
+func (_m *AttributesRepo) Define(ctx context.Context, d attributes.Definition) (attributes.Definition, error) {
+	ret := _m.Called(ctx, d)
+
+	var r0 attributes.Definition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, attributes.Definition) (attributes.Definition, error)); ok {
+		return rf(ctx, d)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, attributes.Definition) attributes.Definition); ok {
+		r0 = rf(ctx, d)
+	} else {
+		r0 = ret.Get(0).(attributes.Definition)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, attributes.Definition) error); ok {
+		r1 = rf(ctx, d)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Definitions provides a mock function with given fields: ctx
+func (_m *AttributesRepo) Definitions(ctx context.Context) ([]attributes.Definition, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []attributes.Definition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]attributes.Definition, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []attributes.Definition); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]attributes.Definition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteDefinition provides a mock function with given fields: ctx, name
+func (_m *AttributesRepo) DeleteDefinition(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, userID
+func (_m *AttributesRepo) Get(ctx context.Context, userID int64) (attributes.Attributes, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 attributes.Attributes
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (attributes.Attributes, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) attributes.Attributes); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(attributes.Attributes)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// History provides a mock function with given fields: ctx, userID, limit
+func (_m *AttributesRepo) History(ctx context.Context, userID int64, limit int) ([]attributes.Change, error) {
+	ret := _m.Called(ctx, userID, limit)
+
+	var r0 []attributes.Change
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int) ([]attributes.Change, error)); ok {
+		return rf(ctx, userID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int) []attributes.Change); ok {
+		r0 = rf(ctx, userID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]attributes.Change)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int) error); ok {
+		r1 = rf(ctx, userID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Upsert provides a mock function with given fields: ctx, changes, replace
+func (_m *AttributesRepo) Upsert(ctx context.Context, changes map[int64]attributes.Attributes, replace bool) (map[int64]attributes.Attributes, error) {
+	ret := _m.Called(ctx, changes, replace)
+
+	var r0 map[int64]attributes.Attributes
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, map[int64]attributes.Attributes, bool) (map[int64]attributes.Attributes, error)); ok {
+		return rf(ctx, changes, replace)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, map[int64]attributes.Attributes, bool) map[int64]attributes.Attributes); ok {
+		r0 = rf(ctx, changes, replace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int64]attributes.Attributes)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, map[int64]attributes.Attributes, bool) error); ok {
+		r1 = rf(ctx, changes, replace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewAttributesRepo creates a new instance of AttributesRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAttributesRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *AttributesRepo {
+	mock := &AttributesRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,128 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	experiments "user-segmentation/internal/entities/experiments"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ExperimentsRepo is an autogenerated mock type for the ExperimentsRepo type
+type ExperimentsRepo struct {
+	mock.Mock
+}
+
+// ForUser provides a mock function with given fields: ctx, userID
+func (_m *ExperimentsRepo) ForUser(ctx context.Context, userID int64) ([]experiments.Experiment, map[string]string, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []experiments.Experiment
+	var r1 map[string]string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) ([]experiments.Experiment, map[string]string, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []experiments.Experiment); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]experiments.Experiment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) map[string]string); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(map[string]string)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int64) error); ok {
+		r2 = rf(ctx, userID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Get provides a mock function with given fields: ctx, key
+func (_m *ExperimentsRepo) Get(ctx context.Context, key string) (experiments.Experiment, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 experiments.Experiment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (experiments.Experiment, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) experiments.Experiment); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(experiments.Experiment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx
+func (_m *ExperimentsRepo) List(ctx context.Context) ([]experiments.Experiment, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []experiments.Experiment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]experiments.Experiment, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []experiments.Experiment); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]experiments.Experiment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Store provides a mock function with given fields: ctx, exp
+func (_m *ExperimentsRepo) Store(ctx context.Context, exp experiments.Experiment) error {
+	ret := _m.Called(ctx, exp)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, experiments.Experiment) error); ok {
+		r0 = rf(ctx, exp)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewExperimentsRepo creates a new instance of ExperimentsRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewExperimentsRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ExperimentsRepo {
+	mock := &ExperimentsRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
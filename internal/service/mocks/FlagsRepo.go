@@ -0,0 +1,109 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	flags "user-segmentation/internal/entities/flags"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// FlagsRepo is an autogenerated mock type for the FlagsRepo type
+type FlagsRepo struct {
+	mock.Mock
+}
+
+// DeleteOverride provides a mock function with given fields: ctx, userID, key
+func (_m *FlagsRepo) DeleteOverride(ctx context.Context, userID int64, key string) error {
+	ret := _m.Called(ctx, userID, key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(ctx, userID, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Known provides a mock function with given fields: ctx, keys
+func (_m *FlagsRepo) Known(ctx context.Context, keys []string) (map[string]flags.Source, error) {
+	ret := _m.Called(ctx, keys)
+
+	var r0 map[string]flags.Source
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) (map[string]flags.Source, error)); ok {
+		return rf(ctx, keys)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string]flags.Source); ok {
+		r0 = rf(ctx, keys)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]flags.Source)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Overrides provides a mock function with given fields: ctx, userID, keys
+func (_m *FlagsRepo) Overrides(ctx context.Context, userID int64, keys []string) (map[string]interface{}, error) {
+	ret := _m.Called(ctx, userID, keys)
+
+	var r0 map[string]interface{}
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []string) (map[string]interface{}, error)); ok {
+		return rf(ctx, userID, keys)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []string) map[string]interface{}); ok {
+		r0 = rf(ctx, userID, keys)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]interface{})
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []string) error); ok {
+		r1 = rf(ctx, userID, keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetOverride provides a mock function with given fields: ctx, userID, key, value
+func (_m *FlagsRepo) SetOverride(ctx context.Context, userID int64, key string, value interface{}) error {
+	ret := _m.Called(ctx, userID, key, value)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, interface{}) error); ok {
+		r0 = rf(ctx, userID, key, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewFlagsRepo creates a new instance of FlagsRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewFlagsRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *FlagsRepo {
+	mock := &FlagsRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -40,6 +40,32 @@ func (_m *HistoryRepo) Get(ctx context.Context, year int, month int) ([]operatio
 	return r0, r1
 }
 
+// GetPage provides a mock function with given fields: ctx, year, month, afterID, limit
+func (_m *HistoryRepo) GetPage(ctx context.Context, year int, month int, afterID int64, limit int) ([]operations.Operation, error) {
+	ret := _m.Called(ctx, year, month, afterID, limit)
+
+	var r0 []operations.Operation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int64, int) ([]operations.Operation, error)); ok {
+		return rf(ctx, year, month, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int64, int) []operations.Operation); ok {
+		r0 = rf(ctx, year, month, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]operations.Operation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, int64, int) error); ok {
+		r1 = rf(ctx, year, month, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Put provides a mock function with given fields: ctx, ops
 func (_m *HistoryRepo) Put(ctx context.Context, ops []operations.Operation) error {
 	ret := _m.Called(ctx, ops)
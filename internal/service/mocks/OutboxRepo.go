@@ -0,0 +1,43 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	outbox "user-segmentation/internal/entities/outbox"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OutboxRepo is an autogenerated mock type for the OutboxRepo type
+type OutboxRepo struct {
+	mock.Mock
+}
+
+// Put provides a mock function with given fields: ctx, events
+func (_m *OutboxRepo) Put(ctx context.Context, events []outbox.Event) error {
+	ret := _m.Called(ctx, events)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []outbox.Event) error); ok {
+		r0 = rf(ctx, events)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewOutboxRepo creates a new instance of OutboxRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOutboxRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OutboxRepo {
+	mock := &OutboxRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -9,6 +9,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	service "user-segmentation/internal/service"
+
+	time "time"
 )
 
 // SegmentsRepo is an autogenerated mock type for the SegmentsRepo type
@@ -16,6 +18,110 @@ type SegmentsRepo struct {
 	mock.Mock
 }
 
+// ActiveDynamic provides a mock function with given fields: ctx
+func (_m *SegmentsRepo) ActiveDynamic(ctx context.Context) ([]service.DynamicSegment, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []service.DynamicSegment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]service.DynamicSegment, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []service.DynamicSegment); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.DynamicSegment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AdvanceRollouts provides a mock function with given fields: ctx, now
+func (_m *SegmentsRepo) AdvanceRollouts(ctx context.Context, now time.Time) ([]segments.RolloutChange, error) {
+	ret := _m.Called(ctx, now)
+
+	var r0 []segments.RolloutChange
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]segments.RolloutChange, error)); ok {
+		return rf(ctx, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []segments.RolloutChange); ok {
+		r0 = rf(ctx, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.RolloutChange)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Ancestors provides a mock function with given fields: ctx, slugs
+func (_m *SegmentsRepo) Ancestors(ctx context.Context, slugs []string) ([]segments.Segment, error) {
+	ret := _m.Called(ctx, slugs)
+
+	var r0 []segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]segments.Segment, error)); ok {
+		return rf(ctx, slugs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []segments.Segment); ok {
+		r0 = rf(ctx, slugs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.Segment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, slugs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Candidates provides a mock function with given fields: ctx, slug, after, limit
+func (_m *SegmentsRepo) Candidates(ctx context.Context, slug string, after *int64, limit int) ([]service.Candidate, error) {
+	ret := _m.Called(ctx, slug, after, limit)
+
+	var r0 []service.Candidate
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *int64, int) ([]service.Candidate, error)); ok {
+		return rf(ctx, slug, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *int64, int) []service.Candidate); ok {
+		r0 = rf(ctx, slug, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.Candidate)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *int64, int) error); ok {
+		r1 = rf(ctx, slug, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ChangeUserSegments provides a mock function with given fields: ctx, userID, add, remove
 func (_m *SegmentsRepo) ChangeUserSegments(ctx context.Context, userID int64, add []segments.Segment, remove []segments.Segment) service.ChangeErrors {
 	ret := _m.Called(ctx, userID, add, remove)
@@ -32,6 +138,32 @@ func (_m *SegmentsRepo) ChangeUserSegments(ctx context.Context, userID int64, ad
 	return r0
 }
 
+// ClaimResync provides a mock function with given fields: ctx, limit, leaseUntil
+func (_m *SegmentsRepo) ClaimResync(ctx context.Context, limit int, leaseUntil time.Time) ([]service.PendingResync, error) {
+	ret := _m.Called(ctx, limit, leaseUntil)
+
+	var r0 []service.PendingResync
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) ([]service.PendingResync, error)); ok {
+		return rf(ctx, limit, leaseUntil)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) []service.PendingResync); ok {
+		r0 = rf(ctx, limit, leaseUntil)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.PendingResync)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Time) error); ok {
+		r1 = rf(ctx, limit, leaseUntil)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: ctx, seg
 func (_m *SegmentsRepo) Delete(ctx context.Context, seg segments.Segment) error {
 	ret := _m.Called(ctx, seg)
@@ -46,6 +178,145 @@ func (_m *SegmentsRepo) Delete(ctx context.Context, seg segments.Segment) error
 	return r0
 }
 
+// DeleteGroup provides a mock function with given fields: ctx, name
+func (_m *SegmentsRepo) DeleteGroup(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Dynamic provides a mock function with given fields: ctx, slugs
+func (_m *SegmentsRepo) Dynamic(ctx context.Context, slugs []string) ([]string, error) {
+	ret := _m.Called(ctx, slugs)
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]string, error)); ok {
+		return rf(ctx, slugs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []string); ok {
+		r0 = rf(ctx, slugs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, slugs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DynamicForUser provides a mock function with given fields: ctx, userID
+func (_m *SegmentsRepo) DynamicForUser(ctx context.Context, userID int64) ([]segments.Segment, map[string]bool, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []segments.Segment
+	var r1 map[string]bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) ([]segments.Segment, map[string]bool, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []segments.Segment); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.Segment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) map[string]bool); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(map[string]bool)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int64) error); ok {
+		r2 = rf(ctx, userID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ExclusiveMembers provides a mock function with given fields: ctx, userID, slugs
+func (_m *SegmentsRepo) ExclusiveMembers(ctx context.Context, userID int64, slugs []string) (map[string][]string, error) {
+	ret := _m.Called(ctx, userID, slugs)
+
+	var r0 map[string][]string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []string) (map[string][]string, error)); ok {
+		return rf(ctx, userID, slugs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []string) map[string][]string); ok {
+		r0 = rf(ctx, userID, slugs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []string) error); ok {
+		r1 = rf(ctx, userID, slugs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FinishResync provides a mock function with given fields: ctx, slug, at
+func (_m *SegmentsRepo) FinishResync(ctx context.Context, slug string, at time.Time) error {
+	ret := _m.Called(ctx, slug, at)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, slug, at)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, slug
+func (_m *SegmentsRepo) Get(ctx context.Context, slug string) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (segments.Segment, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) segments.Segment); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserSegments provides a mock function with given fields: ctx, userID
 func (_m *SegmentsRepo) GetUserSegments(ctx context.Context, userID int64) ([]segments.Segment, error) {
 	ret := _m.Called(ctx, userID)
@@ -72,6 +343,342 @@ func (_m *SegmentsRepo) GetUserSegments(ctx context.Context, userID int64) ([]se
 	return r0, r1
 }
 
+// List provides a mock function with given fields: ctx, filter
+func (_m *SegmentsRepo) List(ctx context.Context, filter segments.Filter) ([]segments.Segment, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, segments.Filter) ([]segments.Segment, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, segments.Filter) []segments.Segment); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.Segment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, segments.Filter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListGroups provides a mock function with given fields: ctx
+func (_m *SegmentsRepo) ListGroups(ctx context.Context) ([]segments.Group, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []segments.Group
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]segments.Group, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []segments.Group); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.Group)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Payloads provides a mock function with given fields: ctx, slugs
+func (_m *SegmentsRepo) Payloads(ctx context.Context, slugs []string) ([]segments.Segment, error) {
+	ret := _m.Called(ctx, slugs)
+
+	var r0 []segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]segments.Segment, error)); ok {
+		return rf(ctx, slugs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []segments.Segment); ok {
+		r0 = rf(ctx, slugs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.Segment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, slugs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Ramp provides a mock function with given fields: ctx, slug
+func (_m *SegmentsRepo) Ramp(ctx context.Context, slug string) ([]segments.RampStep, error) {
+	ret := _m.Called(ctx, slug)
+
+	var r0 []segments.RampStep
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]segments.RampStep, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []segments.RampStep); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.RampStep)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Rename provides a mock function with given fields: ctx, from, to, expiresAt
+func (_m *SegmentsRepo) Rename(ctx context.Context, from string, to string, expiresAt time.Time) (segments.Segment, error) {
+	ret := _m.Called(ctx, from, to, expiresAt)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) (segments.Segment, error)); ok {
+		return rf(ctx, from, to, expiresAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) segments.Segment); ok {
+		r0 = rf(ctx, from, to, expiresAt)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, from, to, expiresAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Restore provides a mock function with given fields: ctx, slug
+func (_m *SegmentsRepo) Restore(ctx context.Context, slug string) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (segments.Segment, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) segments.Segment); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetGroup provides a mock function with given fields: ctx, slug, group
+func (_m *SegmentsRepo) SetGroup(ctx context.Context, slug string, group string) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug, group)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (segments.Segment, error)); ok {
+		return rf(ctx, slug, group)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) segments.Segment); ok {
+		r0 = rf(ctx, slug, group)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, slug, group)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetParent provides a mock function with given fields: ctx, slug, parent
+func (_m *SegmentsRepo) SetParent(ctx context.Context, slug string, parent string) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug, parent)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (segments.Segment, error)); ok {
+		return rf(ctx, slug, parent)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) segments.Segment); ok {
+		r0 = rf(ctx, slug, parent)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, slug, parent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetPayload provides a mock function with given fields: ctx, slug, payload
+func (_m *SegmentsRepo) SetPayload(ctx context.Context, slug string, payload *segments.Payload) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug, payload)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *segments.Payload) (segments.Segment, error)); ok {
+		return rf(ctx, slug, payload)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *segments.Payload) segments.Segment); ok {
+		r0 = rf(ctx, slug, payload)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *segments.Payload) error); ok {
+		r1 = rf(ctx, slug, payload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetRollout provides a mock function with given fields: ctx, slug, percent, salt, ramp
+func (_m *SegmentsRepo) SetRollout(ctx context.Context, slug string, percent *float64, salt string, ramp []segments.RampStep) (segments.Segment, *float64, error) {
+	ret := _m.Called(ctx, slug, percent, salt, ramp)
+
+	var r0 segments.Segment
+	var r1 *float64
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *float64, string, []segments.RampStep) (segments.Segment, *float64, error)); ok {
+		return rf(ctx, slug, percent, salt, ramp)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, *float64, string, []segments.RampStep) segments.Segment); ok {
+		r0 = rf(ctx, slug, percent, salt, ramp)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, *float64, string, []segments.RampStep) *float64); ok {
+		r1 = rf(ctx, slug, percent, salt, ramp)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*float64)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, *float64, string, []segments.RampStep) error); ok {
+		r2 = rf(ctx, slug, percent, salt, ramp)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SetRule provides a mock function with given fields: ctx, slug, rule
+func (_m *SegmentsRepo) SetRule(ctx context.Context, slug string, rule string) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug, rule)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (segments.Segment, error)); ok {
+		return rf(ctx, slug, rule)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) segments.Segment); ok {
+		r0 = rf(ctx, slug, rule)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, slug, rule)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetSchedule provides a mock function with given fields: ctx, slug, schedule
+func (_m *SegmentsRepo) SetSchedule(ctx context.Context, slug string, schedule segments.Schedule) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug, schedule)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, segments.Schedule) (segments.Segment, error)); ok {
+		return rf(ctx, slug, schedule)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, segments.Schedule) segments.Segment); ok {
+		r0 = rf(ctx, slug, schedule)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, segments.Schedule) error); ok {
+		r1 = rf(ctx, slug, schedule)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetState provides a mock function with given fields: ctx, slug, from, to
+func (_m *SegmentsRepo) SetState(ctx context.Context, slug string, from []segments.State, to segments.State) (segments.Segment, segments.State, error) {
+	ret := _m.Called(ctx, slug, from, to)
+
+	var r0 segments.Segment
+	var r1 segments.State
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []segments.State, segments.State) (segments.Segment, segments.State, error)); ok {
+		return rf(ctx, slug, from, to)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, []segments.State, segments.State) segments.Segment); ok {
+		r0 = rf(ctx, slug, from, to)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, []segments.State, segments.State) segments.State); ok {
+		r1 = rf(ctx, slug, from, to)
+	} else {
+		r1 = ret.Get(1).(segments.State)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, []segments.State, segments.State) error); ok {
+		r2 = rf(ctx, slug, from, to)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // Store provides a mock function with given fields: ctx, seg
 func (_m *SegmentsRepo) Store(ctx context.Context, seg segments.Segment) error {
 	ret := _m.Called(ctx, seg)
@@ -86,6 +693,96 @@ func (_m *SegmentsRepo) Store(ctx context.Context, seg segments.Segment) error {
 	return r0
 }
 
+// StoreGroup provides a mock function with given fields: ctx, g
+func (_m *SegmentsRepo) StoreGroup(ctx context.Context, g segments.Group) error {
+	ret := _m.Called(ctx, g)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, segments.Group) error); ok {
+		r0 = rf(ctx, g)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SwitchSchedules provides a mock function with given fields: ctx, now
+func (_m *SegmentsRepo) SwitchSchedules(ctx context.Context, now time.Time) ([]segments.Segment, error) {
+	ret := _m.Called(ctx, now)
+
+	var r0 []segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]segments.Segment, error)); ok {
+		return rf(ctx, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []segments.Segment); ok {
+		r0 = rf(ctx, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.Segment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Upcoming provides a mock function with given fields: ctx, from, until, limit
+func (_m *SegmentsRepo) Upcoming(ctx context.Context, from time.Time, until time.Time, limit int) ([]segments.Transition, error) {
+	ret := _m.Called(ctx, from, until, limit)
+
+	var r0 []segments.Transition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, int) ([]segments.Transition, error)); ok {
+		return rf(ctx, from, until, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, int) []segments.Transition); ok {
+		r0 = rf(ctx, from, until, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]segments.Transition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, int) error); ok {
+		r1 = rf(ctx, from, until, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, slug, patch
+func (_m *SegmentsRepo) Update(ctx context.Context, slug string, patch segments.Patch) (segments.Segment, error) {
+	ret := _m.Called(ctx, slug, patch)
+
+	var r0 segments.Segment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, segments.Patch) (segments.Segment, error)); ok {
+		return rf(ctx, slug, patch)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, segments.Patch) segments.Segment); ok {
+		r0 = rf(ctx, slug, patch)
+	} else {
+		r0 = ret.Get(0).(segments.Segment)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, segments.Patch) error); ok {
+		r1 = rf(ctx, slug, patch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewSegmentsRepo creates a new instance of SegmentsRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewSegmentsRepo(t interface {
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/tracing"
+)
+
+// SetSegmentPayload replaces the payload of the segment. The value is validated against the schema if it is set
+func (s Service) SetSegmentPayload(ctx context.Context, slug string, value json.RawMessage, schema json.RawMessage, priority int) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetSegmentPayload", trace.WithAttributes(
+		attribute.String("segment", slug),
+		attribute.Int("priority", priority),
+	))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	p, err := segments.NewPayload(value, schema, priority)
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return s.Segments.SetPayload(ctx, slug, &p)
+}
+
+func (s Service) RemoveSegmentPayload(ctx context.Context, slug string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.RemoveSegmentPayload", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	return s.Segments.SetPayload(ctx, slug, nil)
+}
+
+// GetUserConfig merges the payloads of the segments of the user by their priorities.
+// Ancestors of the segments give their payloads as well
+func (s Service) GetUserConfig(ctx context.Context, userID int64) (_ segments.Config, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserConfig", trace.WithAttributes(attribute.Int64("user_id", userID)))
+	defer func() { tracing.End(span, err) }()
+	segs, err := s.GetUserImpliedSegments(ctx, userID)
+	if err != nil && !errors.Is(err, repo.ErrNoSegments) {
+		return segments.Config{}, err
+	}
+	slugs := make([]string, len(segs))
+	for i := range segs {
+		slugs[i] = segs[i].Slug
+	}
+	withPayload, err := s.Segments.Payloads(ctx, slugs)
+	if err != nil {
+		return segments.Config{}, err
+	}
+	cfg := segments.MergePayloads(withPayload)
+	span.SetAttributes(attribute.Int("segments", len(cfg.Segments)))
+	return cfg, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/tracing"
+)
+
+// SetSegmentRollout sets the rollout percent of the segment and replaces its ramp, the ramp is returned ordered by time.
+// Users are matched by the percent on reads of their segments, recorded members are recomputed in SyncDynamicSegments.
+// Changes of the percent are recorded in history
+func (s Service) SetSegmentRollout(ctx context.Context, slug string, percent float64, ramp []segments.RampStep) (_ segments.Segment, _ []segments.RampStep, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetSegmentRollout", trace.WithAttributes(
+		attribute.String("segment", slug),
+		attribute.Float64("percent", percent),
+		attribute.Int("ramp", len(ramp)),
+	))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, nil, err
+	}
+	if err := segments.ValidatePercent(percent); err != nil {
+		return segments.Segment{}, nil, err
+	}
+	ramp, err = segments.NewRamp(ramp, time.Now())
+	if err != nil {
+		return segments.Segment{}, nil, err
+	}
+	seg, err := s.setRollout(ctx, slug, &percent, ramp)
+	if err != nil {
+		return segments.Segment{}, nil, err
+	}
+	return seg, ramp, nil
+}
+
+// DisableSegmentRollout stops changing members of the segment by the percent and drops its ramp.
+// Members are kept, and enabling the rollout again includes the same users
+func (s Service) DisableSegmentRollout(ctx context.Context, slug string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.DisableSegmentRollout", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	return s.setRollout(ctx, slug, nil, nil)
+}
+
+func (s Service) setRollout(ctx context.Context, slug string, percent *float64, ramp []segments.RampStep) (segments.Segment, error) {
+	salt, err := segments.NewSalt()
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	var seg segments.Segment
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		var prev *float64
+		seg, prev, err = s.Segments.SetRollout(ctx, slug, percent, salt, ramp)
+		if err != nil {
+			return err
+		}
+		if samePercent(prev, percent) {
+			return nil
+		}
+		ch := segments.RolloutChange{Segment: seg.Slug, From: prev, To: percent, At: time.Now().UTC()}
+		return s.History.Put(ctx, []operations.Operation{operations.NewRolloutChange(ch)})
+	})
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return seg, nil
+}
+
+func samePercent(a *float64, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return segments.Buckets(*a) == segments.Buckets(*b)
+}
+
+// GetSegmentRollout returns the segment with its rollout and the steps of the ramp that are not applied yet
+func (s Service) GetSegmentRollout(ctx context.Context, slug string) (_ segments.Segment, _ []segments.RampStep, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetSegmentRollout", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, nil, err
+	}
+	seg, err := s.Segments.Get(ctx, slug)
+	if err != nil {
+		return segments.Segment{}, nil, err
+	}
+	ramp, err := s.Segments.Ramp(ctx, slug)
+	if err != nil {
+		return segments.Segment{}, nil, err
+	}
+	return seg, ramp, nil
+}
+
+// AdvanceRollouts applies the ramp steps due by now and records the changes of percents in history.
+// A step is applied once, even if several schedulers run concurrently
+func (s Service) AdvanceRollouts(ctx context.Context, now time.Time) (_ []segments.RolloutChange, err error) {
+	ctx, span := tracer.Start(ctx, "Service.AdvanceRollouts")
+	defer func() { tracing.End(span, err) }()
+	var res []segments.RolloutChange
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = s.Segments.AdvanceRollouts(ctx, now)
+		if err != nil || len(res) == 0 {
+			return err
+		}
+		ops := make([]operations.Operation, 0, len(res))
+		for _, ch := range res {
+			if !samePercent(ch.From, ch.To) {
+				ops = append(ops, operations.NewRolloutChange(ch))
+			}
+		}
+		return s.History.Put(ctx, ops)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"user-segmentation/internal/entities/rules"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/tracing"
+)
+
+// SetSegmentRule replaces the rule of the segment. Users are matched by the rule on reads of their segments,
+// recorded members are recomputed in SyncDynamicSegments.
+// Empty rule keeps the current members as explicit ones
+func (s Service) SetSegmentRule(ctx context.Context, slug string, rule string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetSegmentRule", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	if rule != "" {
+		r, err := s.parseRule(ctx, rule)
+		if err != nil {
+			return segments.Segment{}, err
+		}
+		rule = r.String()
+	}
+	return s.Segments.SetRule(ctx, slug, rule)
+}
+
+// parseRule parses the rule and checks that its attributes are defined in the schema
+func (s Service) parseRule(ctx context.Context, text string) (rules.Rule, error) {
+	if s.Attributes == nil {
+		return rules.Rule{}, ErrAttributesDisabled
+	}
+	rule, err := rules.Parse(text)
+	if err != nil {
+		return rules.Rule{}, err
+	}
+	schema, err := s.schema(ctx)
+	if err != nil {
+		return rules.Rule{}, err
+	}
+	for _, name := range rule.Attributes() {
+		if _, ok := schema[name]; !ok {
+			return rules.Rule{}, fmt.Errorf("%w: attribute %s is not defined", rules.ErrInvalidRule, name)
+		}
+	}
+	return rule, nil
+}
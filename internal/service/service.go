@@ -4,19 +4,72 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"maps"
+	"slices"
+	"time"
 	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/outbox"
 	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/metrics"
+	"user-segmentation/internal/tracing"
 )
 
-var ErrInvalidDates = errors.New("invalid dates")
+var (
+	ErrInvalidDates = errors.New("invalid dates")
+	ErrSameSlug     = errors.New("new slug must differ from the current one")
+	ErrNotConfirmed = errors.New("purge must be confirmed by the slug of the segment")
+)
+
+// DefaultAliasTTL is the grace period of old slugs of renamed segments
+const DefaultAliasTTL = 30 * 24 * time.Hour
+
+// DefaultUpcomingLimit is the number of upcoming transitions returned when the limit is not set
+const DefaultUpcomingLimit = 100
+
+// exportBatch is the number of operations read at once by ExportOperations
+const exportBatch = 1000
+
+// errRejected rolls back the transaction of changes rejected by the repository
+var errRejected = errors.New("changes are rejected")
+
+var tracer = tracing.Tracer("service")
 
 type ChangeErrors map[string]string
 
 //go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=SegmentsRepo
 type SegmentsRepo interface {
 	Store(ctx context.Context, seg segments.Segment) error
+	Get(ctx context.Context, slug string) (segments.Segment, error)
+	List(ctx context.Context, filter segments.Filter) ([]segments.Segment, error)
+	Update(ctx context.Context, slug string, patch segments.Patch) (segments.Segment, error)
+	Rename(ctx context.Context, from string, to string, expiresAt time.Time) (segments.Segment, error)
+	SetState(ctx context.Context, slug string, from []segments.State, to segments.State) (segments.Segment, segments.State, error)
+	Restore(ctx context.Context, slug string) (segments.Segment, error)
+	SetSchedule(ctx context.Context, slug string, schedule segments.Schedule) (segments.Segment, error)
+	Upcoming(ctx context.Context, from time.Time, until time.Time, limit int) ([]segments.Transition, error)
+	SwitchSchedules(ctx context.Context, now time.Time) ([]segments.Segment, error)
+	SetRollout(ctx context.Context, slug string, percent *float64, salt string, ramp []segments.RampStep) (segments.Segment, *float64, error)
+	Ramp(ctx context.Context, slug string) ([]segments.RampStep, error)
+	AdvanceRollouts(ctx context.Context, now time.Time) ([]segments.RolloutChange, error)
+	SetRule(ctx context.Context, slug string, rule string) (segments.Segment, error)
+	DynamicForUser(ctx context.Context, userID int64) ([]segments.Segment, map[string]bool, error)
+	ActiveDynamic(ctx context.Context) ([]DynamicSegment, error)
+	Dynamic(ctx context.Context, slugs []string) ([]string, error)
+	ClaimResync(ctx context.Context, limit int, leaseUntil time.Time) ([]PendingResync, error)
+	Candidates(ctx context.Context, slug string, after *int64, limit int) ([]Candidate, error)
+	FinishResync(ctx context.Context, slug string, at time.Time) error
+	SetPayload(ctx context.Context, slug string, payload *segments.Payload) (segments.Segment, error)
+	Payloads(ctx context.Context, slugs []string) ([]segments.Segment, error)
 	Delete(ctx context.Context, seg segments.Segment) error
+	StoreGroup(ctx context.Context, g segments.Group) error
+	ListGroups(ctx context.Context) ([]segments.Group, error)
+	DeleteGroup(ctx context.Context, name string) error
+	SetGroup(ctx context.Context, slug string, group string) (segments.Segment, error)
+	SetParent(ctx context.Context, slug string, parent string) (segments.Segment, error)
+	Ancestors(ctx context.Context, slugs []string) ([]segments.Segment, error)
+	ExclusiveMembers(ctx context.Context, userID int64, slugs []string) (map[string][]string, error)
 	ChangeUserSegments(ctx context.Context, userID int64, add []segments.Segment, remove []segments.Segment) ChangeErrors
 	GetUserSegments(ctx context.Context, userID int64) ([]segments.Segment, error)
 }
@@ -24,9 +77,19 @@ type SegmentsRepo interface {
 //go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=HistoryRepo
 type HistoryRepo interface {
 	Get(ctx context.Context, year int, month int) ([]operations.Operation, error)
+	GetPage(ctx context.Context, year int, month int, afterID int64, limit int) ([]operations.Operation, error)
 	Put(ctx context.Context, ops []operations.Operation) error
 }
 
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=OutboxRepo
+type OutboxRepo interface {
+	Put(ctx context.Context, events []outbox.Event) error
+}
+
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type Storage interface {
 	Put(ctx context.Context)
 }
@@ -34,24 +97,281 @@ type Storage interface {
 type Service struct {
 	Segments SegmentsRepo
 	History  HistoryRepo
+	// Tx makes changes of repositories atomic. Without it every repository call is applied separately
+	Tx Transactor
+	// Outbox receives events of membership changes in the transaction of the changes
+	Outbox OutboxRepo
+	// AliasTTL is the time the old slug of a renamed segment resolves to the segment
+	AliasTTL time.Duration
+	// Experiments assign users to variants. Without it experiments are disabled
+	Experiments ExperimentsRepo
+	// Attributes of users are matched by rules of segments. Without it rule segments are disabled
+	Attributes AttributesRepo
+	// Flags keep overrides of feature flags. Without it flags are disabled
+	Flags FlagsRepo
 }
 
-func (s Service) CreateSegment(ctx context.Context, slug string) error {
-	seg, err := segments.New(slug)
+type Option func(s *Service)
+
+func WithTransactions(tx Transactor) Option {
+	return func(s *Service) {
+		s.Tx = tx
+	}
+}
+
+func WithOutbox(o OutboxRepo) Option {
+	return func(s *Service) {
+		s.Outbox = o
+	}
+}
+
+// WithAliasTTL sets the grace period of old slugs of renamed segments
+func WithAliasTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.AliasTTL = ttl
+	}
+}
+
+func (s Service) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Tx == nil {
+		return fn(ctx)
+	}
+	return s.Tx.WithinTx(ctx, fn)
+}
+
+// CreateSegment stores the segment with the slug, metadata, schedule, state and rule of req.
+// Segments are created active or as drafts
+func (s Service) CreateSegment(ctx context.Context, req segments.Segment) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.CreateSegment", trace.WithAttributes(attribute.String("segment", req.Slug)))
+	defer func() { tracing.End(span, err) }()
+	seg, err := segments.New(req.Slug)
 	if err != nil {
 		return err
 	}
+	seg.Metadata, err = segments.NewMetadata(req.Description, req.Owner, req.Tags)
+	if err != nil {
+		return err
+	}
+	seg.Schedule, err = segments.NewSchedule(req.ActiveFrom, req.ActiveUntil)
+	if err != nil {
+		return err
+	}
+	if req.State != segments.Active && req.State != segments.Draft {
+		return segments.ErrInvalidTransition
+	}
+	seg.State = req.State
+	if req.Rule != "" {
+		rule, err := s.parseRule(ctx, req.Rule)
+		if err != nil {
+			return err
+		}
+		seg.Rule = rule.String()
+	}
 	return s.Segments.Store(ctx, seg)
 }
 
-func (s Service) DeleteSegment(ctx context.Context, slug string) error {
+func (s Service) GetSegment(ctx context.Context, slug string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetSegment", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	return s.Segments.Get(ctx, slug)
+}
+
+// ListSegments returns segments having the tag, the owner and the state. Empty arguments match any segment
+func (s Service) ListSegments(ctx context.Context, tag string, owner string, state string) (_ []segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListSegments", trace.WithAttributes(
+		attribute.String("tag", tag),
+		attribute.String("owner", owner),
+		attribute.String("state", state),
+	))
+	defer func() { tracing.End(span, err) }()
+	filter, err := segments.NewFilter(tag, owner, state)
+	if err != nil {
+		return nil, err
+	}
+	return s.Segments.List(ctx, filter)
+}
+
+// UpdateSegment changes the metadata fields that are not nil
+func (s Service) UpdateSegment(ctx context.Context, slug string, description *string, owner *string, tags *[]string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.UpdateSegment", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	patch, err := segments.NewPatch(description, owner, tags)
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return s.Segments.Update(ctx, slug, patch)
+}
+
+// DeleteSegment archives the segment. Archived segments keep members and history and may be restored
+func (s Service) DeleteSegment(ctx context.Context, slug string) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.DeleteSegment", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	_, err = s.setState(ctx, slug, segments.Archived)
+	return err
+}
+
+// SetSegmentState moves the segment to the state and records the transition in history
+func (s Service) SetSegmentState(ctx context.Context, slug string, state string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetSegmentState", trace.WithAttributes(
+		attribute.String("segment", slug),
+		attribute.String("state", state),
+	))
+	defer func() { tracing.End(span, err) }()
+	to, err := segments.ParseState(state)
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return s.setState(ctx, slug, to)
+}
+
+func (s Service) setState(ctx context.Context, slug string, to segments.State) (segments.Segment, error) {
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	var seg segments.Segment
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		var from segments.State
+		seg, from, err = s.Segments.SetState(ctx, slug, segments.SourcesOf(to), to)
+		if err != nil {
+			return err
+		}
+		return s.History.Put(ctx, []operations.Operation{operations.NewTransition(from, seg)})
+	})
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return seg, nil
+}
+
+// RestoreSegment returns the archived segment to the state it had before archiving
+func (s Service) RestoreSegment(ctx context.Context, slug string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.RestoreSegment", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	var seg segments.Segment
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if seg, err = s.Segments.Restore(ctx, slug); err != nil {
+			return err
+		}
+		return s.History.Put(ctx, []operations.Operation{operations.NewTransition(segments.Archived, seg)})
+	})
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return seg, nil
+}
+
+// PurgeSegment deletes the archived segment with its memberships and history.
+// confirm must repeat the slug, so the segment is not purged by mistake
+func (s Service) PurgeSegment(ctx context.Context, slug string, confirm string) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.PurgeSegment", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
 	seg, err := segments.New(slug)
 	if err != nil {
 		return err
 	}
+	if confirm != slug {
+		return ErrNotConfirmed
+	}
 	return s.Segments.Delete(ctx, seg)
 }
 
+// SetSegmentSchedule replaces the schedule of the segment. Nil bounds are open.
+// Changes of the schedule are recorded in history by SwitchSchedules
+func (s Service) SetSegmentSchedule(ctx context.Context, slug string, from *time.Time, until *time.Time) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetSegmentSchedule", trace.WithAttributes(attribute.String("segment", slug)))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	schedule, err := segments.NewSchedule(from, until)
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return s.Segments.SetSchedule(ctx, slug, schedule)
+}
+
+// UpcomingTransitions returns up to limit activations and deactivations of segments from now until the time
+func (s Service) UpcomingTransitions(ctx context.Context, until time.Time, limit int) (_ []segments.Transition, err error) {
+	ctx, span := tracer.Start(ctx, "Service.UpcomingTransitions", trace.WithAttributes(attribute.Int("limit", limit)))
+	defer func() { tracing.End(span, err) }()
+	if limit <= 0 {
+		limit = DefaultUpcomingLimit
+	}
+	return s.Segments.Upcoming(ctx, time.Now(), until, limit)
+}
+
+// SwitchSchedules records in history the segments that entered or left their schedules by now.
+// A transition is recorded once, even if several schedulers run concurrently
+func (s Service) SwitchSchedules(ctx context.Context, now time.Time) (_ []segments.Transition, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SwitchSchedules")
+	defer func() { tracing.End(span, err) }()
+	var res []segments.Transition
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		switched, err := s.Segments.SwitchSchedules(ctx, now)
+		if err != nil || len(switched) == 0 {
+			return err
+		}
+		res = make([]segments.Transition, len(switched))
+		ops := make([]operations.Operation, len(switched))
+		for i, seg := range switched {
+			res[i] = segments.Transition{Segment: seg.Slug, Activate: seg.ActiveAt(now), At: now}
+			ops[i] = operations.NewScheduled(res[i], seg.Schedule)
+		}
+		return s.History.Put(ctx, ops)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RenameSegment changes the slug of the segment keeping its members and history.
+// The old slug resolves to the segment for AliasTTL
+func (s Service) RenameSegment(ctx context.Context, slug string, newSlug string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.RenameSegment", trace.WithAttributes(
+		attribute.String("segment", slug),
+		attribute.String("new_segment", newSlug),
+	))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	if _, err := segments.New(newSlug); err != nil {
+		return segments.Segment{}, err
+	}
+	if slug == newSlug {
+		return segments.Segment{}, ErrSameSlug
+	}
+	ttl := s.AliasTTL
+	if ttl == 0 {
+		ttl = DefaultAliasTTL
+	}
+	var seg segments.Segment
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		seg, err = s.Segments.Rename(ctx, slug, newSlug, time.Now().Add(ttl))
+		if err != nil {
+			return err
+		}
+		return s.History.Put(ctx, []operations.Operation{operations.NewRenaming(slug, seg)})
+	})
+	if err != nil {
+		return segments.Segment{}, err
+	}
+	return seg, nil
+}
+
 func createSegments(slugs []string) ([]segments.Segment, ChangeErrors) {
 	res := make([]segments.Segment, len(slugs))
 	errs := make(ChangeErrors)
@@ -65,53 +385,226 @@ func createSegments(slugs []string) ([]segments.Segment, ChangeErrors) {
 	return res, errs
 }
 
+// ChangeUserSegments adds the user to the segments and removes from them. Changes are applied only if all succeed,
+// then membership of the user in segments with rollouts and rules is updated. Members of those segments are computed,
+// so their explicit changes are rejected. Adding the user to a segment of an exclusive group the user is already in is rejected
 func (s Service) ChangeUserSegments(ctx context.Context, userID int64, add []string, remove []string) (ChangeErrors, error) {
+	return s.changeUserSegments(ctx, userID, add, remove, false)
+}
+
+// ReplaceUserSegments changes segments of the user like ChangeUserSegments, but removes the user
+// from other segments of exclusive groups of the added segments in the same transaction
+func (s Service) ReplaceUserSegments(ctx context.Context, userID int64, add []string, remove []string) (ChangeErrors, error) {
+	return s.changeUserSegments(ctx, userID, add, remove, true)
+}
+
+func (s Service) changeUserSegments(ctx context.Context, userID int64, add []string, remove []string, replace bool) (_ ChangeErrors, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ChangeUserSegments", trace.WithAttributes(
+		attribute.Int64("user_id", userID),
+		attribute.Int("add", len(add)),
+		attribute.Int("remove", len(remove)),
+		attribute.Bool("replace", replace),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	_, validation := tracer.Start(ctx, "validate")
 	addSeg, errs := createSegments(add)
 	rmSeg, errsRm := createSegments(remove)
 	maps.Copy(errs, errsRm)
+	validation.End()
+	if len(errs) == 0 && len(add)+len(remove) != 0 {
+		dynamic, err := s.Segments.Dynamic(ctx, append(slices.Clone(add), remove...))
+		if err != nil {
+			metrics.CountChanges(len(add), len(remove), metrics.OutcomeFailed)
+			return nil, err
+		}
+		for _, slug := range dynamic {
+			errs[slug] = segments.ErrDynamicMembership.Error()
+		}
+	}
 	if len(errs) != 0 {
+		span.SetAttributes(attribute.Int("errors", len(errs)))
+		metrics.CountChanges(len(add), len(remove), metrics.OutcomeRejected)
 		return errs, nil
 	}
-	errs = s.Segments.ChangeUserSegments(ctx, userID, addSeg, rmSeg)
-	if len(errs) != 0 {
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if replace {
+			replaced, err := s.replacedSegments(ctx, userID, add, remove)
+			if err != nil {
+				return err
+			}
+			rmSeg = append(rmSeg, replaced...)
+		}
+		errs = s.Segments.ChangeUserSegments(ctx, userID, addSeg, rmSeg)
+		if len(errs) != 0 {
+			return errRejected
+		}
+		ops := make([]operations.Operation, 0, len(addSeg)+len(rmSeg))
+		for i := range addSeg {
+			op, _ := operations.New(userID, addSeg[i], operations.Add)
+			ops = append(ops, op)
+		}
+		for i := range rmSeg {
+			op, _ := operations.New(userID, rmSeg[i], operations.Remove)
+			ops = append(ops, op)
+		}
+		if err := s.History.Put(ctx, ops); err != nil {
+			return err
+		}
+		return s.putEvents(ctx, ops)
+	})
+	if errors.Is(err, errRejected) {
+		span.SetAttributes(attribute.Int("errors", len(errs)))
+		metrics.CountChanges(len(add), len(remove), metrics.OutcomeRejected)
 		return errs, nil
 	}
-	ops := make([]operations.Operation, 0, len(addSeg)+len(rmSeg))
-	for i := range addSeg {
-		op, _ := operations.New(userID, addSeg[i], operations.Add)
-		ops = append(ops, op)
+	if err != nil {
+		metrics.CountChanges(len(add), len(remove), metrics.OutcomeFailed)
+		return nil, err
 	}
-	for i := range rmSeg {
-		op, _ := operations.New(userID, rmSeg[i], operations.Remove)
-		ops = append(ops, op)
+	metrics.CountChanges(len(add), len(remove), metrics.OutcomeApplied)
+	// a new user enters segments with rollouts and rules
+	if err := s.syncDynamic(ctx, userID); err != nil {
+		// the change is applied, membership is updated by the next change of the user
+		span.RecordError(err)
 	}
-	return nil, s.History.Put(ctx, ops)
+	return nil, nil
 }
 
-func (s Service) GetUserSegments(ctx context.Context, userID int64) ([]segments.Segment, error) {
-	return s.Segments.GetUserSegments(ctx, userID)
+// replacedSegments returns the segments of exclusive groups of the added segments the user is in,
+// except the segments changed explicitly
+func (s Service) replacedSegments(ctx context.Context, userID int64, add []string, remove []string) ([]segments.Segment, error) {
+	if len(add) == 0 {
+		return nil, nil
+	}
+	members, err := s.Segments.ExclusiveMembers(ctx, userID, add)
+	if err != nil {
+		return nil, err
+	}
+	var res []segments.Segment
+	seen := make(map[string]bool)
+	for _, slug := range add {
+		seen[slug] = true
+	}
+	for _, slug := range remove {
+		seen[slug] = true
+	}
+	for _, slug := range add {
+		for _, other := range members[slug] {
+			if !seen[other] {
+				seen[other] = true
+				res = append(res, segments.Segment{Slug: other})
+			}
+		}
+	}
+	return res, nil
 }
 
-func (s Service) GetHistory(ctx context.Context, year int, month int) ([][]string, error) {
+func (s Service) putEvents(ctx context.Context, ops []operations.Operation) error {
+	if s.Outbox == nil {
+		return nil
+	}
+	events := make([]outbox.Event, len(ops))
+	for i := range ops {
+		var err error
+		if events[i], err = outbox.NewMembership(ops[i]); err != nil {
+			return err
+		}
+	}
+	return s.Outbox.Put(ctx, events)
+}
+
+// GetUserSegments returns active segments of the user. Segments with rollouts and rules are matched on every read,
+// their recorded membership is kept by SyncDynamicSegments and changes of the user for history. If experiments
+// are enabled and the user lacks a variant of some experiment, the user is assigned to variants of new experiments first
+func (s Service) GetUserSegments(ctx context.Context, userID int64) (_ []segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserSegments", trace.WithAttributes(attribute.Int64("user_id", userID)))
+	defer func() { tracing.End(span, err) }()
+	res, err := s.userSegments(ctx, userID)
+	if err != nil || s.Experiments == nil {
+		return res, err
+	}
+	assigned, err := s.assignExperiments(ctx, userID, res)
+	if err != nil {
+		// segments are still returned, the assignment is retried on the next read
+		span.RecordError(err)
+		return res, nil
+	}
+	if !assigned {
+		return res, nil
+	}
+	return s.userSegments(ctx, userID)
+}
+
+func (s Service) userSegments(ctx context.Context, userID int64) ([]segments.Segment, error) {
+	res, err := s.Segments.GetUserSegments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDynamic(ctx, userID, res)
+}
+
+func (s Service) GetOperations(ctx context.Context, year int, month int) (_ []operations.Operation, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetOperations", trace.WithAttributes(
+		attribute.Int("year", year),
+		attribute.Int("month", month),
+	))
+	defer func() { tracing.End(span, err) }()
 	if month < 1 || month > 12 || year < 1970 {
 		return nil, ErrInvalidDates
 	}
-	ops, err := s.History.Get(ctx, year, month)
+	return s.History.Get(ctx, year, month)
+}
+
+// ExportOperations calls fn for each operation made during the month in the order of IDs. Operations are read
+// in batches after the ID of the last one, so the month is never held in memory at once. An error of fn stops the export
+func (s Service) ExportOperations(ctx context.Context, year int, month int, fn func(op operations.Operation) error) (err error) {
+	ctx, span := tracer.Start(ctx, "Service.ExportOperations", trace.WithAttributes(
+		attribute.Int("year", year),
+		attribute.Int("month", month),
+	))
+	defer func() { tracing.End(span, err) }()
+	if month < 1 || month > 12 || year < 1970 {
+		return ErrInvalidDates
+	}
+	for after := int64(0); ; {
+		ops, err := s.History.GetPage(ctx, year, month, after, exportBatch)
+		if err != nil {
+			return err
+		}
+		for i := range ops {
+			if err := fn(ops[i]); err != nil {
+				return err
+			}
+		}
+		if len(ops) < exportBatch {
+			return nil
+		}
+		after = ops[len(ops)-1].ID
+	}
+}
+
+func (s Service) GetHistory(ctx context.Context, year int, month int) ([][]string, error) {
+	ops, err := s.GetOperations(ctx, year, month)
 	if err != nil {
 		return nil, err
 	}
 	res := make([][]string, 0, len(ops)+1)
 	res = append(res, []string{"User ID", "Segment", "Operation", "Timestamp UTC"})
 	for i := range ops {
-		opType := "add"
-		if ops[i].Type == operations.Remove {
-			opType = "remove"
+		userID := ""
+		if ops[i].Type.IsMembership() {
+			userID = fmt.Sprint(ops[i].UserID)
 		}
-		res = append(res, []string{fmt.Sprint(ops[i].UserID), ops[i].Segment.Slug, opType, ops[i].Time.String()})
+		res = append(res, []string{userID, ops[i].Segment.Slug, ops[i].Type.String(), ops[i].Time.String()})
 	}
 	return res, nil
 }
 
-func New(seg SegmentsRepo, his HistoryRepo) Service {
-	return Service{Segments: seg, History: his}
+func New(seg SegmentsRepo, his HistoryRepo, opts ...Option) Service {
+	s := Service{Segments: seg, History: his}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
 }
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func newExperiment(t *testing.T) experiments.Experiment {
+	exp, err := experiments.New("checkout", "", []experiments.Variant{
+		{Name: "control", Weight: 1},
+		{Name: "treatment", Weight: 3},
+		{Name: "off", Weight: 0},
+	})
+	require.NoError(t, err)
+	return exp
+}
+
+func TestExperiment_Assign(t *testing.T) {
+	exp := newExperiment(t)
+	require.Equal(t, "checkout.treatment", exp.Variants[1].Segment.Slug)
+	counts := make(map[string]int)
+	for userID := int64(1); userID <= 10000; userID++ {
+		v := exp.Assign(userID)
+		require.Equal(t, v, exp.Assign(userID), "assignment is deterministic")
+		counts[v.Name]++
+	}
+	require.Zero(t, counts["off"])
+	require.InDelta(t, 2500, counts["control"], 250)
+	require.InDelta(t, 7500, counts["treatment"], 250)
+
+	other := exp
+	other.Key = "search"
+	differs := false
+	for userID := int64(1); userID <= 100 && !differs; userID++ {
+		differs = exp.Assign(userID).Name != other.Assign(userID).Name
+	}
+	require.True(t, differs, "experiments split users independently")
+}
+
+func TestService_CreateExperiment(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	e := mocks.NewExperimentsRepo(t)
+	s := service.New(r, nil, service.WithExperiments(e))
+	ctx := context.Background()
+	_, err := s.CreateExperiment(ctx, "Bad Key", "", []experiments.Variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}})
+	require.ErrorIs(t, err, experiments.ErrInvalidKey)
+	_, err = s.CreateExperiment(ctx, "exp", "", []experiments.Variant{{Name: "a", Weight: 1}})
+	require.ErrorIs(t, err, experiments.ErrTooFewVariants)
+	_, err = s.CreateExperiment(ctx, "exp", "", []experiments.Variant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}})
+	require.ErrorIs(t, err, experiments.ErrDuplicateName)
+	_, err = s.CreateExperiment(ctx, "exp", "", []experiments.Variant{{Name: "a"}, {Name: "b"}})
+	require.ErrorIs(t, err, experiments.ErrZeroWeights)
+
+	exp := newExperiment(t)
+	for _, v := range exp.Variants {
+		r.On("Store", mock.Anything, v.Segment).Return(nil).Once()
+	}
+	e.On("Store", mock.Anything, exp).Return(nil).Once()
+	e.On("Get", mock.Anything, "checkout").Return(exp, nil).Once()
+	_, err = s.CreateExperiment(ctx, exp.Key, "", []experiments.Variant{
+		{Name: "control", Weight: 1},
+		{Name: "treatment", Weight: 3},
+		{Name: "off", Weight: 0},
+	})
+	require.NoError(t, err)
+
+	_, err = service.New(r, nil).CreateExperiment(ctx, exp.Key, "", nil)
+	require.ErrorIs(t, err, service.ErrExperimentsDisabled)
+}
+
+func TestService_GetUserSegmentsAssignsVariants(t *testing.T) {
+	const userID = 42
+	exp := newExperiment(t)
+	archived := newExperiment(t)
+	archived.Key = "archived"
+	for i := range archived.Variants {
+		archived.Variants[i].Segment.State = segments.Archived
+	}
+	assigned := newExperiment(t)
+	assigned.Key = "assigned"
+	want := exp.Assign(userID)
+
+	control := segments.Segment{Slug: "assigned-control", Variant: &segments.VariantOf{Experiment: "assigned", Variant: "control"}}
+	variant := segments.Segment{Slug: want.Segment.Slug, Variant: &segments.VariantOf{Experiment: "checkout", Variant: want.Name}}
+	r := mocks.NewSegmentsRepo(t)
+	e := mocks.NewExperimentsRepo(t)
+	h := mocks.NewHistoryRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{control}, nil).
+		Once()
+	e.
+		On("List", mock.Anything).
+		Return([]experiments.Experiment{archived, assigned, exp}, nil)
+	e.
+		On("ForUser", mock.Anything, int64(userID)).
+		Return([]experiments.Experiment{archived, assigned, exp}, map[string]string{"assigned": "control"}, nil).
+		Once()
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(userID), []segments.Segment{want.Segment}, []segments.Segment(nil)).
+		Return(service.ChangeErrors{}).
+		Once()
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Add && ops[0].UserID == userID &&
+				ops[0].Segment.Slug == want.Segment.Slug &&
+				ops[0].Details[operations.DetailExperiment] == "checkout" &&
+				ops[0].Details[operations.DetailVariant] == want.Name
+		})).
+		Return(nil).
+		Once()
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{control, variant}, nil).
+		Twice()
+	s := service.New(r, h, service.WithExperiments(e))
+	res, err := s.GetUserSegments(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, []segments.Segment{control, variant}, res, "segments are read again after the assignment")
+
+	// the variant is among the segments, so the assignments of the user are not looked up
+	_, err = s.GetUserSegments(context.Background(), userID)
+	require.NoError(t, err, "assigned users are not changed")
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/entities/flags"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestService_GetUserFlags(t *testing.T) {
+	const userID = 42
+	keys := []string{"beta", "checkout", "search", "legacy", "forced", "unknown"}
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{
+			{Slug: "beta"},
+			{Slug: "checkout-blue", Variant: &segments.VariantOf{Experiment: "checkout", Variant: "blue"}},
+		}, nil).
+		Once()
+	r.
+		On("Ancestors", mock.Anything, []string{"beta", "checkout-blue"}).
+		Return([]segments.Segment{}, nil).
+		Once()
+	f := mocks.NewFlagsRepo(t)
+	f.
+		On("Overrides", mock.Anything, int64(userID), keys).
+		Return(map[string]any{"forced": "red", "beta": false}, nil).
+		Once()
+	f.
+		On("Known", mock.Anything, keys).
+		Return(map[string]flags.Source{
+			"beta":     flags.SourceSegment,
+			"legacy":   flags.SourceSegment,
+			"checkout": flags.SourceExperiment,
+			"search":   flags.SourceExperiment,
+		}, nil).
+		Once()
+	s := service.New(r, mocks.NewHistoryRepo(t), service.WithFlags(f))
+	res, err := s.GetUserFlags(context.Background(), userID, keys)
+	require.NoError(t, err)
+	require.Equal(t, []flags.Flag{
+		{Key: "beta", Value: false, Source: flags.SourceOverride},
+		{Key: "checkout", Value: "blue", Source: flags.SourceExperiment},
+		{Key: "search", Value: false, Source: flags.SourceExperiment},
+		{Key: "legacy", Value: false, Source: flags.SourceSegment},
+		{Key: "forced", Value: "red", Source: flags.SourceOverride},
+		{Key: "unknown", Value: false, Source: flags.SourceDefault},
+	}, res)
+}
+
+func TestService_GetUserFlagsMatchesDynamicSegments(t *testing.T) {
+	const userID = 42
+	keys := []string{"rollout", "rule"}
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{
+			{Segment: segments.Segment{Slug: "rollout", Rollout: &segments.Rollout{Percent: 100, Salt: "salt"}}},
+			{Segment: segments.Segment{Slug: "rule", Rule: `not (platform == "ios")`}},
+		}, nil).
+		Once()
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{}, nil).
+		Once()
+	r.
+		On("Ancestors", mock.Anything, keys).
+		Return([]segments.Segment{}, nil).
+		Once()
+	a := mocks.NewAttributesRepo(t)
+	a.
+		On("Get", mock.Anything, int64(userID)).
+		Return(attributes.Attributes{}, nil).
+		Once()
+	f := mocks.NewFlagsRepo(t)
+	f.
+		On("Overrides", mock.Anything, int64(userID), keys).
+		Return(map[string]any{}, nil).
+		Once()
+	f.
+		On("Known", mock.Anything, keys).
+		Return(map[string]flags.Source{"rollout": flags.SourceSegment, "rule": flags.SourceSegment}, nil).
+		Once()
+	s := service.New(r, mocks.NewHistoryRepo(t), service.WithFlags(f), service.WithAttributes(a))
+	res, err := s.GetUserFlags(context.Background(), userID, keys)
+	require.NoError(t, err)
+	require.Equal(t, []flags.Flag{
+		{Key: "rollout", Value: true, Source: flags.SourceSegment},
+		{Key: "rule", Value: true, Source: flags.SourceSegment},
+	}, res, "users without recorded membership get flags of their rollouts and rules")
+}
+
+func TestService_SetFlagOverride(t *testing.T) {
+	f := mocks.NewFlagsRepo(t)
+	f.
+		On("SetOverride", mock.Anything, int64(42), "checkout", "blue").
+		Return(nil).
+		Once()
+	s := service.New(mocks.NewSegmentsRepo(t), mocks.NewHistoryRepo(t), service.WithFlags(f))
+	require.NoError(t, s.SetFlagOverride(context.Background(), 42, "checkout", "blue"))
+
+	for _, value := range []any{"", 1.0, nil, []any{true}} {
+		require.ErrorIs(t, s.SetFlagOverride(context.Background(), 42, "checkout", value), flags.ErrInvalidOverride, value)
+	}
+	_, err := service.New(mocks.NewSegmentsRepo(t), mocks.NewHistoryRepo(t)).GetUserFlags(context.Background(), 42, []string{"beta"})
+	require.ErrorIs(t, err, service.ErrFlagsDisabled)
+}
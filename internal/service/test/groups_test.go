@@ -0,0 +1,63 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestService_ReplaceUserSegments(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Dynamic", mock.Anything, []string{"layer-b", "other", "layer-c"}).
+		Return([]string{}, nil).
+		Once()
+	r.
+		On("ExclusiveMembers", mock.Anything, int64(1), []string{"layer-b", "other"}).
+		Return(map[string][]string{"layer-b": {"layer-a", "layer-c"}}, nil).
+		Once()
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(1),
+			[]segments.Segment{{Slug: "layer-b"}, {Slug: "other"}},
+			[]segments.Segment{{Slug: "layer-c"}, {Slug: "layer-a"}}).
+		Return(service.ChangeErrors{}).
+		Once()
+	r.
+		On("DynamicForUser", mock.Anything, int64(1)).
+		Return([]segments.Segment{}, map[string]bool{}, nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 4 && ops[2].Type == operations.Remove && ops[2].Segment.Slug == "layer-c" &&
+				ops[3].Type == operations.Remove && ops[3].Segment.Slug == "layer-a"
+		})).
+		Return(nil).
+		Once()
+	s := service.New(r, h)
+	errs, err := s.ReplaceUserSegments(context.Background(), 1, []string{"layer-b", "other"}, []string{"layer-c"})
+	require.NoError(t, err)
+	require.Empty(t, errs, "explicitly removed segments are removed once")
+}
+
+func TestService_CreateGroup(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("StoreGroup", mock.Anything, segments.Group{Name: "layer", Exclusive: true, Segments: []string{}}).
+		Return(nil).
+		Once()
+	s := service.New(r, nil)
+	g, err := s.CreateGroup(context.Background(), " layer ", true)
+	require.NoError(t, err)
+	require.Equal(t, "layer", g.Name)
+
+	_, err = s.CreateGroup(context.Background(), " ", true)
+	require.ErrorIs(t, err, segments.ErrEmptyGroup)
+	_, err = s.SetSegmentGroup(context.Background(), "slug", string(make([]byte, 256)))
+	require.ErrorIs(t, err, segments.ErrGroupNameTooLong)
+}
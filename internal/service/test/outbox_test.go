@@ -0,0 +1,77 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/outbox"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+// transactor runs functions without a database and records how transactions end
+type transactor struct {
+	committed  int
+	rolledBack int
+}
+
+func (tx *transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		tx.rolledBack++
+		return err
+	}
+	tx.committed++
+	return nil
+}
+
+func TestService_ChangeUserSegmentsOutbox(t *testing.T) {
+	var events []outbox.Event
+	o := mocks.NewOutboxRepo(t)
+	o.
+		On("Put", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { events = args.Get(1).([]outbox.Event) }).
+		Return(nil).
+		Once()
+	tx := &transactor{}
+	s := service.New(changeUserSegmentsRepo(t), putHistoryRepo(t), service.WithTransactions(tx), service.WithOutbox(o))
+
+	errs, err := s.ChangeUserSegments(context.Background(), 1, []string{"slug-1"}, []string{"slug-2"})
+	require.NoError(t, err)
+	require.Empty(t, errs)
+	require.Equal(t, 1, tx.committed)
+	require.Len(t, events, 2)
+	require.NotEqual(t, events[0].Key, events[1].Key)
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(events[1].Payload, &payload))
+	require.Equal(t, outbox.TopicMembership, events[1].Topic)
+	require.Equal(t, "slug-2", payload["segment"])
+	require.Equal(t, "remove", payload["operation"])
+}
+
+func TestService_ChangeUserSegmentsRollback(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Dynamic", mock.Anything, []string{"slug-1"}).
+		Return([]string{}, nil)
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(1), mock.Anything, mock.Anything).
+		Return(service.ChangeErrors{"slug-1": "segment not found"})
+	tx := &transactor{}
+	s := service.New(r, nil, service.WithTransactions(tx), service.WithOutbox(mocks.NewOutboxRepo(t)))
+
+	errs, err := s.ChangeUserSegments(context.Background(), 1, []string{"slug-1"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, service.ChangeErrors{"slug-1": "segment not found"}, errs)
+	require.Equal(t, 1, tx.rolledBack)
+
+	failing := errors.New("connection reset")
+	h := mocks.NewHistoryRepo(t)
+	h.On("Put", mock.Anything, mock.Anything).Return(failing)
+	s = service.New(changeUserSegmentsRepo(t), h, service.WithTransactions(tx), service.WithOutbox(mocks.NewOutboxRepo(t)))
+	_, err = s.ChangeUserSegments(context.Background(), 1, []string{"slug-1"}, nil)
+	require.ErrorIs(t, err, failing)
+	require.Equal(t, 2, tx.rolledBack)
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestService_SetSegmentPayload(t *testing.T) {
+	schema := json.RawMessage(`{"type": "object", "required": ["color"], "properties": {"color": {"enum": ["red", "blue"]}}}`)
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SetPayload", mock.Anything, "slug", &segments.Payload{
+			Value:    json.RawMessage(`{"color":"red"}`),
+			Schema:   json.RawMessage(`{"type":"object","required":["color"],"properties":{"color":{"enum":["red","blue"]}}}`),
+			Priority: 5,
+		}).
+		Return(segments.Segment{Slug: "slug"}, nil).
+		Once()
+	s := service.New(r, mocks.NewHistoryRepo(t))
+	_, err := s.SetSegmentPayload(context.Background(), "slug", json.RawMessage(`{"color": "red"}`), schema, 5)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		payload, schema string
+		want            error
+	}{
+		{`[1, 2]`, ``, segments.ErrInvalidPayload},
+		{`null`, ``, segments.ErrInvalidPayload},
+		{`{"color": "green"}`, string(schema), segments.ErrInvalidPayload},
+		{`{}`, string(schema), segments.ErrInvalidPayload},
+		{`{"color": "red"}`, `{"type": 1}`, segments.ErrInvalidPayloadSchema},
+	} {
+		_, err := s.SetSegmentPayload(context.Background(), "slug", json.RawMessage(c.payload), json.RawMessage(c.schema), 0)
+		require.ErrorIs(t, err, c.want, c.payload)
+	}
+}
+
+func TestService_GetUserConfig(t *testing.T) {
+	const userID = 42
+	payload := func(value string, priority int) *segments.Payload {
+		return &segments.Payload{Value: json.RawMessage(value), Priority: priority}
+	}
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{{Slug: "base"}, {Slug: "beta"}, {Slug: "alpha"}, {Slug: "plain"}}, nil).
+		Once()
+	r.
+		On("Ancestors", mock.Anything, []string{"base", "beta", "alpha", "plain"}).
+		Return([]segments.Segment{}, nil).
+		Once()
+	r.
+		On("Payloads", mock.Anything, []string{"base", "beta", "alpha", "plain"}).
+		Return([]segments.Segment{
+			{Slug: "base", Payload: payload(`{"theme": {"color": "white", "font": "serif"}, "limit": 10}`, 0)},
+			{Slug: "beta", Payload: payload(`{"theme": {"color": "black"}, "limit": 20}`, 10)},
+			{Slug: "alpha", Payload: payload(`{"limit": 30, "banner": true}`, 10)},
+		}, nil).
+		Once()
+	s := service.New(r, mocks.NewHistoryRepo(t))
+	cfg, err := s.GetUserConfig(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"theme":  map[string]any{"color": "black", "font": "serif"},
+		"limit":  30.0,
+		"banner": true,
+	}, cfg.Values, "objects are merged, of equal priorities the lesser slug wins")
+	require.Equal(t, []string{"alpha", "beta", "base"}, cfg.Segments)
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestService_SetSegmentRollout(t *testing.T) {
+	now := time.Now().UTC()
+	prev, percent := 5.0, 25.0
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SetRollout", mock.Anything, "slug", &percent, mock.AnythingOfType("string"), []segments.RampStep{
+			{At: now.Add(time.Hour), Percent: 50},
+			{At: now.Add(2 * time.Hour), Percent: 100},
+		}).
+		Return(segments.Segment{Slug: "slug", Rollout: &segments.Rollout{Percent: percent}}, &prev, nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Rollout && ops[0].UserID == 0 &&
+				ops[0].Details[operations.DetailFrom] == "5" && ops[0].Details[operations.DetailTo] == "25"
+		})).
+		Return(nil).
+		Once()
+	s := service.New(r, h)
+	_, ramp, err := s.SetSegmentRollout(context.Background(), "slug", percent, []segments.RampStep{
+		{At: now.Add(2 * time.Hour), Percent: 100},
+		{At: now.Add(time.Hour), Percent: 50},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 50.0, ramp[0].Percent, "steps are ordered by time")
+
+	_, _, err = s.SetSegmentRollout(context.Background(), "slug", 100.5, nil)
+	require.ErrorIs(t, err, segments.ErrInvalidPercent)
+	_, _, err = s.SetSegmentRollout(context.Background(), "slug", 0.125, nil)
+	require.ErrorIs(t, err, segments.ErrInvalidPercent)
+	_, _, err = s.SetSegmentRollout(context.Background(), "slug", 1, []segments.RampStep{{At: now.Add(-time.Hour), Percent: 5}})
+	require.ErrorIs(t, err, segments.ErrInvalidRamp)
+}
+
+func TestService_GetUserSegmentsMatchesRollouts(t *testing.T) {
+	const userID = 42
+	later := time.Now().Add(time.Hour)
+	dynamic := func(slug string, percent float64) service.DynamicSegment {
+		return service.DynamicSegment{Segment: segments.Segment{Slug: slug, Rollout: &segments.Rollout{Percent: percent, Salt: "salt"}}}
+	}
+	full, off, scheduled := dynamic("full", 100), dynamic("off", 0), dynamic("scheduled", 100)
+	scheduled.ActiveFrom = &later
+	layered, recorded := dynamic("layer-b", 100), dynamic("layer-c", 100)
+	layered.Group, layered.Exclusive = "layer", true
+	recorded.Group, recorded.Exclusive = "layer", true
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{full, layered, recorded, off, scheduled}, nil).
+		Once()
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{{Slug: "explicit"}, {Slug: "off"}, {Slug: "layer-c", Group: "layer"}}, nil).
+		Once()
+	res, err := service.New(r, mocks.NewHistoryRepo(t)).GetUserSegments(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, []segments.Segment{{Slug: "explicit"}, {Slug: "layer-c", Group: "layer"}, {Slug: "full"}}, res,
+		"rollouts are matched without recorded membership, and the recorded segment keeps the exclusive group")
+}
+
+func TestService_SyncDynamicSegments(t *testing.T) {
+	const userID = 42
+	at := time.Now()
+	// the bucket of the user decides membership, so percents are chosen around it
+	bucket := segments.Bucket("salt", userID, segments.RolloutBuckets)
+	included := segments.Segment{Slug: "included", Rollout: &segments.Rollout{Percent: segments.PercentOf(bucket + 1), Salt: "salt"}}
+	excluded := segments.Segment{Slug: "excluded", Rollout: &segments.Rollout{Percent: segments.PercentOf(bucket), Salt: "salt"}}
+	kept := segments.Segment{Slug: "kept", Rollout: &segments.Rollout{Percent: 100, Salt: "salt"}}
+	explicit := segments.Segment{Slug: "explicit"}
+	r := mocks.NewSegmentsRepo(t)
+	// segments are claimed one by one until none is pending
+	for _, seg := range []segments.Segment{included, excluded, kept, explicit} {
+		r.
+			On("ClaimResync", mock.Anything, 1, mock.AnythingOfType("time.Time")).
+			Return([]service.PendingResync{{Segment: seg, At: at}}, nil).
+			Once()
+	}
+	r.
+		On("ClaimResync", mock.Anything, 1, mock.AnythingOfType("time.Time")).
+		Return([]service.PendingResync{}, nil).
+		Once()
+	for slug, member := range map[string]bool{"included": false, "excluded": true, "kept": true} {
+		r.
+			On("Candidates", mock.Anything, slug, (*int64)(nil), 1000).
+			Return([]service.Candidate{{UserID: userID, Member: member}}, nil).
+			Once()
+	}
+	for _, slug := range []string{"included", "excluded", "kept", "explicit"} {
+		r.
+			On("FinishResync", mock.Anything, slug, at).
+			Return(nil).
+			Once()
+	}
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(userID), []segments.Segment{included}, []segments.Segment(nil)).
+		Return(service.ChangeErrors{}).
+		Once()
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(userID), []segments.Segment(nil), []segments.Segment{excluded}).
+		Return(service.ChangeErrors{}).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Add && ops[0].Segment.Slug == "included" &&
+				ops[0].Details[operations.DetailRollout] == segments.FormatPercent(&included.Rollout.Percent)
+		})).
+		Return(nil).
+		Once()
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Remove && ops[0].Segment.Slug == "excluded"
+		})).
+		Return(nil).
+		Once()
+	s := service.New(r, h)
+	res, err := s.SyncDynamicSegments(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []segments.Resync{
+		{Segment: "included", Added: 1},
+		{Segment: "excluded", Removed: 1},
+		{Segment: "kept"},
+		{Segment: "explicit"},
+	}, res)
+}
+
+func TestService_AdvanceRollouts(t *testing.T) {
+	now := time.Now()
+	from, to := 5.0, 25.0
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("AdvanceRollouts", mock.Anything, now).
+		Return([]segments.RolloutChange{{Segment: "slug", From: &from, To: &to, At: now}}, nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, []operations.Operation{{
+			Segment: segments.Segment{Slug: "slug"},
+			Type:    operations.Rollout,
+			Time:    now,
+			Details: map[string]string{operations.DetailFrom: "5", operations.DetailTo: "25"},
+		}}).
+		Return(nil).
+		Once()
+	s := service.New(r, h)
+	res, err := s.AdvanceRollouts(context.Background(), now)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/attributes"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/rules"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+var schema = []attributes.Definition{
+	{Name: "age", Type: attributes.Number},
+	{Name: "country", Type: attributes.String},
+	{Name: "signup_date", Type: attributes.Date},
+	{Name: "tags", Type: attributes.StringList},
+}
+
+func TestService_SetUserAttributes(t *testing.T) {
+	const userID = 42
+	matched := segments.Segment{Slug: "matched", Rule: `country == "RU"`}
+	unmatched := segments.Segment{Slug: "unmatched", Rule: `country == "KZ"`}
+	attrs := attributes.Attributes{"country": "RU", "age": float64(30)}
+	a := mocks.NewAttributesRepo(t)
+	a.On("Definitions", mock.Anything).Return(schema, nil)
+	a.
+		On("Upsert", mock.Anything, map[int64]attributes.Attributes{userID: attrs}, true).
+		Return(map[int64]attributes.Attributes{userID: attrs}, nil).
+		Once()
+	a.
+		On("Get", mock.Anything, int64(userID)).
+		Return(attrs, nil).
+		Once()
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("DynamicForUser", mock.Anything, int64(userID)).
+		Return([]segments.Segment{matched, unmatched}, map[string]bool{"unmatched": true}, nil).
+		Once()
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(userID), []segments.Segment{matched}, []segments.Segment(nil)).
+		Return(service.ChangeErrors{}).
+		Once()
+	r.
+		On("ChangeUserSegments", mock.Anything, int64(userID), []segments.Segment(nil), []segments.Segment{unmatched}).
+		Return(service.ChangeErrors{}).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Add && ops[0].Segment.Slug == "matched" &&
+				ops[0].Details[operations.DetailRule] == matched.Rule
+		})).
+		Return(nil).
+		Once()
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Remove && ops[0].Segment.Slug == "unmatched" &&
+				ops[0].Details[operations.DetailRule] == unmatched.Rule
+		})).
+		Return(nil).
+		Once()
+	s := service.New(r, h, service.WithAttributes(a))
+	res, err := s.SetUserAttributes(context.Background(), userID, map[string]any{"country": "RU", "age": 30})
+	require.NoError(t, err)
+	require.Equal(t, attrs, res)
+
+	for values, want := range map[string]error{
+		`{"Country": "RU"}`:           attributes.ErrInvalidName,
+		`{"city": "Moscow"}`:          attributes.ErrUnknownAttribute,
+		`{"age": "30"}`:               attributes.ErrInvalidValue,
+		`{"signup_date": "15.03.24"}`: attributes.ErrInvalidValue,
+		`{"tags": ["a", 1]}`:          attributes.ErrInvalidValue,
+	} {
+		_, err = s.SetUserAttributes(context.Background(), userID, decode(t, values))
+		require.ErrorIs(t, err, want, values)
+	}
+
+	_, err = service.New(r, h).SetUserAttributes(context.Background(), userID, map[string]any{"country": "RU"})
+	require.ErrorIs(t, err, service.ErrAttributesDisabled)
+}
+
+func TestService_UpsertUserAttributes(t *testing.T) {
+	a := mocks.NewAttributesRepo(t)
+	a.On("Definitions", mock.Anything).Return(schema, nil)
+	a.
+		On("Upsert", mock.Anything, map[int64]attributes.Attributes{
+			1: {"signup_date": "2024-03-15", "tags": []any{"a", "b"}},
+			2: {"country": nil},
+		}, false).
+		Return(map[int64]attributes.Attributes{
+			1: {"signup_date": "2024-03-15", "tags": []any{"a", "b"}, "age": float64(30)},
+			2: {},
+		}, nil).
+		Once()
+	r := mocks.NewSegmentsRepo(t)
+	for _, userID := range []int64{1, 2} {
+		r.
+			On("DynamicForUser", mock.Anything, userID).
+			Return([]segments.Segment{}, map[string]bool{}, nil).
+			Once()
+	}
+	s := service.New(r, mocks.NewHistoryRepo(t), service.WithAttributes(a))
+	res, err := s.UpsertUserAttributes(context.Background(), map[int64]map[string]any{
+		1: decode(t, `{"signup_date": "2024-03-15", "tags": ["a", "b"]}`),
+		2: decode(t, `{"country": null}`),
+	})
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	_, err = s.UpsertUserAttributes(context.Background(), map[int64]map[string]any{1: {"country": 1}})
+	require.ErrorIs(t, err, attributes.ErrInvalidValue)
+	_, err = s.GetUserAttributeHistory(context.Background(), 1, 0)
+	require.ErrorIs(t, err, service.ErrInvalidLimit)
+}
+
+func TestService_DeleteAttribute(t *testing.T) {
+	a := mocks.NewAttributesRepo(t)
+	a.
+		On("DeleteDefinition", mock.Anything, "country").
+		Return(attributes.ErrInUse).
+		Once()
+	a.
+		On("DeleteDefinition", mock.Anything, "age").
+		Return(nil).
+		Once()
+	s := service.New(mocks.NewSegmentsRepo(t), mocks.NewHistoryRepo(t), service.WithAttributes(a))
+	require.ErrorIs(t, s.DeleteAttribute(context.Background(), "country"), attributes.ErrInUse,
+		"attributes set for users are kept")
+	require.NoError(t, s.DeleteAttribute(context.Background(), "age"))
+
+	err := service.New(mocks.NewSegmentsRepo(t), mocks.NewHistoryRepo(t)).DeleteAttribute(context.Background(), "age")
+	require.ErrorIs(t, err, service.ErrAttributesDisabled)
+}
+
+func TestService_GetUserSegmentsMatchesRules(t *testing.T) {
+	const userID = 42
+	rule := func(slug string, text string) service.DynamicSegment {
+		return service.DynamicSegment{Segment: segments.Segment{Slug: slug, Rule: text}}
+	}
+	negated, ru := rule("not-ios", `not (platform == "ios")`), rule("ru", `country == "RU"`)
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{negated, ru}, nil)
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{{Slug: "ru"}}, nil)
+	a := mocks.NewAttributesRepo(t)
+	a.
+		On("Get", mock.Anything, int64(userID)).
+		Return(attributes.Attributes{}, nil).
+		Once()
+	res, err := service.New(r, mocks.NewHistoryRepo(t), service.WithAttributes(a)).GetUserSegments(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, []segments.Segment{{Slug: "not-ios"}}, res, "users without attributes match negated rules only")
+
+	res, err = service.New(r, mocks.NewHistoryRepo(t)).GetUserSegments(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, []segments.Segment{{Slug: "ru"}}, res, "without attributes membership is kept as recorded")
+}
+
+func TestService_SetSegmentRule(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SetRule", mock.Anything, "slug", `country in ("RU", "KZ") and age >= 18`).
+		Return(segments.Segment{Slug: "slug", Rule: `country in ("RU", "KZ") and age >= 18`}, nil).
+		Once()
+	a := mocks.NewAttributesRepo(t)
+	a.On("Definitions", mock.Anything).Return(schema, nil)
+	s := service.New(r, mocks.NewHistoryRepo(t), service.WithAttributes(a))
+	seg, err := s.SetSegmentRule(context.Background(), "slug", "  country in (\"RU\", \"KZ\") and age >= 18\n")
+	require.NoError(t, err)
+	require.Equal(t, `country in ("RU", "KZ") and age >= 18`, seg.Rule, "rules are stored trimmed")
+
+	_, err = s.SetSegmentRule(context.Background(), "slug", `country = "RU"`)
+	require.ErrorIs(t, err, rules.ErrInvalidRule)
+	_, err = s.SetSegmentRule(context.Background(), "slug", `city == "Moscow"`)
+	require.ErrorIs(t, err, rules.ErrInvalidRule, "attributes of rules are defined")
+	_, err = service.New(r, mocks.NewHistoryRepo(t)).SetSegmentRule(context.Background(), "slug", `country == "RU"`)
+	require.ErrorIs(t, err, service.ErrAttributesDisabled)
+}
+
+// decode returns the values as they come from JSON requests
+func decode(t *testing.T, values string) map[string]any {
+	t.Helper()
+	var res map[string]any
+	require.NoError(t, json.Unmarshal([]byte(values), &res))
+	return res
+}
@@ -0,0 +1,192 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestService_CreateSegmentMetadata(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Store", mock.Anything, segments.Segment{Slug: "slug", Metadata: segments.Metadata{
+			Description: "description",
+			Owner:       "growth",
+			Tags:        []string{"a", "b"},
+		}}).
+		Return(nil).
+		Once()
+	s := service.New(r, nil)
+	seg := segments.Segment{
+		Slug:     "slug",
+		Metadata: segments.Metadata{Description: "description", Owner: " growth ", Tags: []string{"B", "a", "b "}},
+	}
+	require.NoError(t, s.CreateSegment(context.Background(), seg))
+
+	seg.Tags = []string{""}
+	require.ErrorIs(t, s.CreateSegment(context.Background(), seg), segments.ErrInvalidTag)
+}
+
+func TestService_UpdateSegment(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Update", mock.Anything, "slug", segments.Patch{Tags: ptr([]string{"x"})}).
+		Return(segments.Segment{Slug: "slug"}, nil).
+		Once()
+	s := service.New(r, nil)
+	_, err := s.UpdateSegment(context.Background(), "slug", nil, nil, ptr([]string{"X", "x"}))
+	require.NoError(t, err, "absent fields stay nil")
+
+	_, err = s.UpdateSegment(context.Background(), "slug", ptr(string(make([]byte, 1025))), nil, nil)
+	require.ErrorIs(t, err, segments.ErrDescriptionTooLong)
+	_, err = s.UpdateSegment(context.Background(), "", nil, nil, nil)
+	require.ErrorIs(t, err, segments.ErrEmptySlug)
+}
+
+func TestService_ListSegments(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("List", mock.Anything, segments.Filter{Tag: "exp", Owner: "growth"}).
+		Return([]segments.Segment{}, nil).
+		Once()
+	s := service.New(r, nil)
+	_, err := s.ListSegments(context.Background(), "EXP", "growth", "")
+	require.NoError(t, err)
+}
+
+func TestService_RenameSegment(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Rename", mock.Anything, "old", "new", mock.AnythingOfType("time.Time")).
+		Return(segments.Segment{Slug: "new"}, nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Rename && ops[0].Segment.Slug == "new" &&
+				ops[0].Details[operations.DetailFrom] == "old"
+		})).
+		Return(nil).
+		Once()
+	s := service.New(r, h, service.WithAliasTTL(time.Hour))
+	seg, err := s.RenameSegment(context.Background(), "old", "new")
+	require.NoError(t, err)
+	require.Equal(t, "new", seg.Slug)
+
+	_, err = s.RenameSegment(context.Background(), "old", "old")
+	require.ErrorIs(t, err, service.ErrSameSlug)
+	_, err = s.RenameSegment(context.Background(), "old", "")
+	require.ErrorIs(t, err, segments.ErrEmptySlug)
+}
+
+func TestService_SetSegmentState(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SetState", mock.Anything, "slug", []segments.State{segments.Active}, segments.Paused).
+		Return(segments.Segment{Slug: "slug", State: segments.Paused}, segments.Active, nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 1 && ops[0].Type == operations.Transition &&
+				ops[0].Details[operations.DetailFrom] == "active" && ops[0].Details[operations.DetailTo] == "paused"
+		})).
+		Return(nil).
+		Once()
+	s := service.New(r, h)
+	seg, err := s.SetSegmentState(context.Background(), "slug", "paused")
+	require.NoError(t, err)
+	require.Equal(t, segments.Paused, seg.State)
+
+	_, err = s.SetSegmentState(context.Background(), "slug", "deleted")
+	require.ErrorIs(t, err, segments.ErrUnknownState)
+	err = s.CreateSegment(context.Background(), segments.Segment{Slug: "slug", State: segments.Archived})
+	require.ErrorIs(t, err, segments.ErrInvalidTransition)
+}
+
+func TestService_PurgeSegment(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Delete", mock.Anything, segments.Segment{Slug: "slug"}).
+		Return(nil).
+		Once()
+	s := service.New(r, nil)
+	require.ErrorIs(t, s.PurgeSegment(context.Background(), "slug", ""), service.ErrNotConfirmed)
+	require.ErrorIs(t, s.PurgeSegment(context.Background(), "slug", "other"), service.ErrNotConfirmed)
+	require.NoError(t, s.PurgeSegment(context.Background(), "slug", "slug"))
+}
+
+func TestSegmentStates(t *testing.T) {
+	require.ElementsMatch(t, []segments.State{segments.Active, segments.Draft, segments.Paused}, segments.SourcesOf(segments.Archived))
+	require.Equal(t, []segments.State{segments.Draft, segments.Paused}, segments.SourcesOf(segments.Active))
+	require.False(t, segments.Archived.CanMoveTo(segments.Active), "archived segments are restored")
+	require.False(t, segments.Active.CanMoveTo(segments.Draft))
+}
+
+func TestService_SetSegmentSchedule(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(time.Hour)
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SetSchedule", mock.Anything, "slug", segments.Schedule{ActiveFrom: &from, ActiveUntil: &until}).
+		Return(segments.Segment{Slug: "slug"}, nil).
+		Once()
+	s := service.New(r, nil)
+	local := from.In(time.FixedZone("UTC+3", 3*60*60))
+	_, err := s.SetSegmentSchedule(context.Background(), "slug", &local, &until)
+	require.NoError(t, err, "bounds are stored in UTC")
+
+	_, err = s.SetSegmentSchedule(context.Background(), "slug", &until, &from)
+	require.ErrorIs(t, err, segments.ErrInvalidSchedule)
+	_, err = s.SetSegmentSchedule(context.Background(), "slug", &from, &from)
+	require.ErrorIs(t, err, segments.ErrInvalidSchedule)
+}
+
+func TestService_SwitchSchedules(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SwitchSchedules", mock.Anything, now).
+		Return([]segments.Segment{
+			{Slug: "started", Schedule: segments.Schedule{ActiveFrom: &past}},
+			{Slug: "ended", Schedule: segments.Schedule{ActiveUntil: &past}},
+		}, nil).
+		Once()
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("Put", mock.Anything, mock.MatchedBy(func(ops []operations.Operation) bool {
+			return len(ops) == 2 &&
+				ops[0].Type == operations.Activate && ops[0].Segment.Slug == "started" && ops[0].Time.Equal(now) &&
+				ops[0].Details[operations.DetailActiveFrom] == past.Format(time.RFC3339) &&
+				ops[1].Type == operations.Deactivate && ops[1].Segment.Slug == "ended" &&
+				ops[1].Details[operations.DetailActiveUntil] == past.Format(time.RFC3339)
+		})).
+		Return(nil).
+		Once()
+	s := service.New(r, h)
+	res, err := s.SwitchSchedules(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, []segments.Transition{
+		{Segment: "started", Activate: true, At: now},
+		{Segment: "ended", Activate: false, At: now},
+	}, res)
+
+	r.
+		On("SwitchSchedules", mock.Anything, now).
+		Return([]segments.Segment{}, nil).
+		Once()
+	res, err = s.SwitchSchedules(context.Background(), now)
+	require.NoError(t, err)
+	require.Empty(t, res, "nothing is recorded without transitions")
+}
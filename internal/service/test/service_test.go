@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -28,8 +29,8 @@ func storeRepo(t *testing.T) service.SegmentsRepo {
 func deleteRepo(t *testing.T) service.SegmentsRepo {
 	r := mocks.NewSegmentsRepo(t)
 	r.
-		On("Delete", mock.Anything, mock.AnythingOfType("segments.Segment")).
-		Return(nil)
+		On("SetState", mock.Anything, "slug", mock.Anything, segments.Archived).
+		Return(segments.Segment{Slug: "slug", State: segments.Archived}, segments.Active, nil)
 	return r
 }
 
@@ -112,7 +113,7 @@ func TestService_CreateSegment(t *testing.T) {
 				Segments: tt.fields.segments,
 				History:  tt.fields.history,
 			}
-			tt.wantErr(t, s.CreateSegment(tt.args.ctx, tt.args.slug), fmt.Sprintf("CreateSegment(%v, %v)", tt.args.ctx, tt.args.slug))
+			tt.wantErr(t, s.CreateSegment(tt.args.ctx, segments.Segment{Slug: tt.args.slug}), fmt.Sprintf("CreateSegment(%v, %v)", tt.args.ctx, tt.args.slug))
 		})
 	}
 }
@@ -136,7 +137,7 @@ func TestService_DeleteSegment(t *testing.T) {
 			name: "correct deleting",
 			fields: fields{
 				segments: deleteRepo(t),
-				history:  nil,
+				history:  putHistoryRepo(t),
 			},
 			args: args{
 				ctx:  context.Background(),
@@ -309,6 +310,42 @@ func TestService_GetHistory(t *testing.T) {
 	}
 }
 
+func TestService_ExportOperations(t *testing.T) {
+	page := make([]operations.Operation, 1000)
+	for i := range page {
+		page[i] = operations.Operation{ID: int64(i + 1), UserID: 1, Segment: segments.Segment{Slug: "slug"}, Time: now}
+	}
+	h := mocks.NewHistoryRepo(t)
+	h.
+		On("GetPage", mock.Anything, 2023, 9, int64(0), 1000).
+		Return(page, nil)
+	h.
+		On("GetPage", mock.Anything, 2023, 9, int64(1000), 1000).
+		Return([]operations.Operation{{ID: 1001, UserID: 2, Segment: segments.Segment{Slug: "slug"}, Time: now}}, nil)
+	s := service.New(nil, h)
+
+	var got []int64
+	err := s.ExportOperations(context.Background(), 2023, 9, func(op operations.Operation) error {
+		got = append(got, op.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 1001)
+	assert.Equal(t, int64(1001), got[1000])
+
+	stop := errors.New("stream closed")
+	calls := 0
+	err = s.ExportOperations(context.Background(), 2023, 9, func(operations.Operation) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls, "an error of fn stops the export")
+
+	err = s.ExportOperations(context.Background(), 2023, 13, func(operations.Operation) error { return nil })
+	assert.ErrorIs(t, err, service.ErrInvalidDates)
+}
+
 func putHistoryRepo(t *testing.T) service.HistoryRepo {
 	r := mocks.NewHistoryRepo(t)
 	r.On("Put", mock.Anything, mock.Anything).Return(nil)
@@ -317,9 +354,18 @@ func putHistoryRepo(t *testing.T) service.HistoryRepo {
 
 func changeUserSegmentsRepo(t *testing.T) service.SegmentsRepo {
 	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("Dynamic", mock.Anything, mock.Anything).
+		Return([]string{}, nil).
+		Maybe()
 	r.
 		On("ChangeUserSegments", mock.Anything, mock.AnythingOfType("int64"), mock.Anything, mock.Anything).
 		Return(service.ChangeErrors{})
+	// applied changes update membership in dynamic segments
+	r.
+		On("DynamicForUser", mock.Anything, mock.AnythingOfType("int64")).
+		Return([]segments.Segment{}, map[string]bool{}, nil).
+		Maybe()
 	return r
 }
 
@@ -375,6 +421,33 @@ func TestService_ChangeUserSegments(t *testing.T) {
 				return assert.NoError(t, err)
 			},
 		},
+		{
+			name: "dynamic segments",
+			fields: fields{
+				segments: func() service.SegmentsRepo {
+					r := mocks.NewSegmentsRepo(t)
+					r.
+						On("Dynamic", mock.Anything, []string{"slug-1", "rollout", "rule"}).
+						Return([]string{"rollout", "rule"}, nil).
+						Once()
+					return r
+				}(),
+				history: nil,
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				add:    []string{"slug-1", "rollout"},
+				remove: []string{"rule"},
+			},
+			want: service.ChangeErrors{
+				"rollout": segments.ErrDynamicMembership.Error(),
+				"rule":    segments.ErrDynamicMembership.Error(),
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.NoError(t, err)
+			},
+		},
 		{
 			name: "incorrect segments",
 			fields: fields{
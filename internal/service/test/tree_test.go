@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/service"
+	"user-segmentation/internal/service/mocks"
+)
+
+func TestService_SetSegmentParent(t *testing.T) {
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("SetParent", mock.Anything, "payments-beta", "payments").
+		Return(segments.Segment{Slug: "payments-beta", Parent: "payments"}, nil).
+		Once()
+	r.
+		On("SetParent", mock.Anything, "payments", "payments-beta").
+		Return(segments.Segment{}, segments.ErrCycle).
+		Once()
+	s := service.New(r, nil)
+	seg, err := s.SetSegmentParent(context.Background(), "payments-beta", "payments")
+	require.NoError(t, err)
+	require.Equal(t, "payments", seg.Parent)
+	_, err = s.SetSegmentParent(context.Background(), "payments", "payments-beta")
+	require.ErrorIs(t, err, segments.ErrCycle)
+	_, err = s.SetSegmentParent(context.Background(), "payments", string(make([]byte, 256)))
+	require.ErrorIs(t, err, segments.ErrSlugToLong)
+}
+
+func TestService_GetUserImpliedSegments(t *testing.T) {
+	const userID = 42
+	r := mocks.NewSegmentsRepo(t)
+	r.
+		On("ActiveDynamic", mock.Anything).
+		Return([]service.DynamicSegment{}, nil)
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{{Slug: "payments-beta-sbp"}, {Slug: "other"}}, nil).
+		Once()
+	r.
+		On("Ancestors", mock.Anything, []string{"payments-beta-sbp", "other"}).
+		Return([]segments.Segment{{Slug: "payments", Implied: true}, {Slug: "payments-beta", Implied: true}}, nil).
+		Once()
+	s := service.New(r, mocks.NewHistoryRepo(t))
+	res, err := s.GetUserImpliedSegments(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, []segments.Segment{
+		{Slug: "payments-beta-sbp"},
+		{Slug: "other"},
+		{Slug: "payments", Implied: true},
+		{Slug: "payments-beta", Implied: true},
+	}, res)
+
+	r.
+		On("GetUserSegments", mock.Anything, int64(userID)).
+		Return([]segments.Segment{}, nil).
+		Once()
+	res, err = s.GetUserImpliedSegments(context.Background(), userID)
+	require.NoError(t, err)
+	require.Empty(t, res, "ancestors are not read for users without segments")
+}
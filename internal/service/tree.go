@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"user-segmentation/internal/entities/segments"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/tracing"
+)
+
+// SetSegmentParent makes the segment a child of the parent, empty parent makes the segment a root
+func (s Service) SetSegmentParent(ctx context.Context, slug string, parent string) (_ segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetSegmentParent", trace.WithAttributes(
+		attribute.String("segment", slug),
+		attribute.String("parent", parent),
+	))
+	defer func() { tracing.End(span, err) }()
+	if _, err := segments.New(slug); err != nil {
+		return segments.Segment{}, err
+	}
+	if parent != "" {
+		if _, err := segments.New(parent); err != nil {
+			return segments.Segment{}, err
+		}
+	}
+	return s.Segments.SetParent(ctx, slug, parent)
+}
+
+// GetUserImpliedSegments returns active segments of the user like GetUserSegments followed by
+// active ancestors of the segments, which are implied by membership in their descendants
+func (s Service) GetUserImpliedSegments(ctx context.Context, userID int64) (_ []segments.Segment, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserImpliedSegments", trace.WithAttributes(attribute.Int64("user_id", userID)))
+	defer func() { tracing.End(span, err) }()
+	segs, err := s.GetUserSegments(ctx, userID)
+	if err != nil && !errors.Is(err, repo.ErrNoSegments) {
+		return nil, err
+	}
+	if len(segs) == 0 {
+		return segs, err
+	}
+	slugs := make([]string, len(segs))
+	for i := range segs {
+		slugs[i] = segs[i].Slug
+	}
+	ancestors, err := s.Segments.Ancestors(ctx, slugs)
+	if err != nil {
+		return nil, err
+	}
+	return append(segs, ancestors...), nil
+}
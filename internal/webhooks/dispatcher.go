@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"user-segmentation/internal/entities/webhooks"
+	"user-segmentation/internal/logger"
+	"user-segmentation/internal/metrics"
+)
+
+const (
+	HeaderWebhookID = "X-Webhook-ID"
+	// HeaderDelivery is the same for all attempts of the delivery, so receivers can deduplicate them
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	userAgent     = "user-segmentation-webhooks"
+	maxBackoff    = time.Hour
+	maxErrorLen   = 1024
+	maxBodyToRead = 64 << 10
+)
+
+// Dispatcher sends due deliveries with exponential backoff. Deliveries failed MaxAttempts times become dead
+type Dispatcher struct {
+	Repo        Repo
+	Client      *http.Client
+	Interval    time.Duration
+	BatchSize   int
+	Concurrency int
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt, it doubles after every next one
+	Backoff time.Duration
+}
+
+// Run sends deliveries every interval until ctx is done
+func (d Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lease is the time the claimed batch is hidden from other replicas, it covers the slowest batch
+func (d Dispatcher) lease() time.Duration {
+	rounds := (d.BatchSize + d.Concurrency - 1) / d.Concurrency
+	return 2*time.Duration(rounds)*d.Client.Timeout + time.Minute
+}
+
+func (d Dispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobs, err := d.Repo.Claim(ctx, d.BatchSize, time.Now().Add(d.lease()))
+		if err != nil {
+			return
+		}
+		sem := make(chan struct{}, d.Concurrency)
+		var wg sync.WaitGroup
+		for _, job := range jobs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(job webhooks.Job) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				d.deliver(ctx, job)
+			}(job)
+		}
+		wg.Wait()
+		if len(jobs) < d.BatchSize {
+			return
+		}
+	}
+}
+
+func (d Dispatcher) deliver(ctx context.Context, job webhooks.Job) {
+	const fn = "webhooks.Dispatcher.deliver"
+	code, err := d.send(ctx, job)
+	if ctx.Err() != nil {
+		// the lease expires and the delivery is retried, the attempt is not counted
+		return
+	}
+	if err == nil {
+		metrics.CountDelivery(metrics.DeliveryDelivered)
+		if err := d.Repo.Complete(ctx, job.ID, code); err != nil {
+			logger.InternalErr(ctx, err, fn)
+		}
+		return
+	}
+	var status *int
+	if code != 0 {
+		status = &code
+	}
+	reason := err.Error()
+	if len(reason) > maxErrorLen {
+		reason = reason[:maxErrorLen]
+	}
+	var retryAt *time.Time
+	attempts := job.Attempts + 1
+	if attempts < d.MaxAttempts {
+		at := time.Now().UTC().Add(d.backoff(attempts))
+		retryAt = &at
+		metrics.CountDelivery(metrics.DeliveryFailed)
+	} else {
+		metrics.CountDelivery(metrics.DeliveryDead)
+		logger.Log(ctx).WarnContext(
+			ctx,
+			"webhook delivery is dead",
+			slog.Int64("webhook_id", job.WebhookID),
+			slog.Int64("delivery_id", job.ID),
+			slog.String("error", reason),
+		)
+	}
+	if err := d.Repo.Fail(ctx, job.ID, status, reason, retryAt); err != nil {
+		logger.InternalErr(ctx, err, fn)
+	}
+}
+
+func (d Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.Backoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// send posts the signed payload and returns the response status. Only 2xx statuses are successful
+func (d Dispatcher) send(ctx context.Context, job webhooks.Job) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(job.Payload))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderWebhookID, strconv.FormatInt(job.WebhookID, 10))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(job.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, webhooks.Sign(job.Secret, now, job.Payload))
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// reading the body lets the connection be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodyToRead))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
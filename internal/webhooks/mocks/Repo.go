@@ -0,0 +1,190 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	webhooks "user-segmentation/internal/entities/webhooks"
+)
+
+// Repo is an autogenerated mock type for the Repo type
+type Repo struct {
+	mock.Mock
+}
+
+// Claim provides a mock function with given fields: ctx, limit, leaseUntil
+func (_m *Repo) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]webhooks.Job, error) {
+	ret := _m.Called(ctx, limit, leaseUntil)
+
+	var r0 []webhooks.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) ([]webhooks.Job, error)); ok {
+		return rf(ctx, limit, leaseUntil)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) []webhooks.Job); ok {
+		r0 = rf(ctx, limit, leaseUntil)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]webhooks.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Time) error); ok {
+		r1 = rf(ctx, limit, leaseUntil)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Complete provides a mock function with given fields: ctx, id, statusCode
+func (_m *Repo) Complete(ctx context.Context, id int64, statusCode int) error {
+	ret := _m.Called(ctx, id, statusCode)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int) error); ok {
+		r0 = rf(ctx, id, statusCode)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *Repo) Delete(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Deliveries provides a mock function with given fields: ctx, webhookID, status, limit
+func (_m *Repo) Deliveries(ctx context.Context, webhookID int64, status *webhooks.Status, limit int) ([]webhooks.Delivery, error) {
+	ret := _m.Called(ctx, webhookID, status, limit)
+
+	var r0 []webhooks.Delivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, *webhooks.Status, int) ([]webhooks.Delivery, error)); ok {
+		return rf(ctx, webhookID, status, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, *webhooks.Status, int) []webhooks.Delivery); ok {
+		r0 = rf(ctx, webhookID, status, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]webhooks.Delivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, *webhooks.Status, int) error); ok {
+		r1 = rf(ctx, webhookID, status, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Fail provides a mock function with given fields: ctx, id, statusCode, reason, retryAt
+func (_m *Repo) Fail(ctx context.Context, id int64, statusCode *int, reason string, retryAt *time.Time) error {
+	ret := _m.Called(ctx, id, statusCode, reason, retryAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, *int, string, *time.Time) error); ok {
+		r0 = rf(ctx, id, statusCode, reason, retryAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// List provides a mock function with given fields: ctx
+func (_m *Repo) List(ctx context.Context) ([]webhooks.Webhook, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []webhooks.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]webhooks.Webhook, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []webhooks.Webhook); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]webhooks.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Retry provides a mock function with given fields: ctx, webhookID, deliveryID
+func (_m *Repo) Retry(ctx context.Context, webhookID int64, deliveryID int64) error {
+	ret := _m.Called(ctx, webhookID, deliveryID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) error); ok {
+		r0 = rf(ctx, webhookID, deliveryID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Store provides a mock function with given fields: ctx, w
+func (_m *Repo) Store(ctx context.Context, w webhooks.Webhook) (int64, error) {
+	ret := _m.Called(ctx, w)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, webhooks.Webhook) (int64, error)); ok {
+		return rf(ctx, w)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, webhooks.Webhook) int64); ok {
+		r0 = rf(ctx, w)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, webhooks.Webhook) error); ok {
+		r1 = rf(ctx, w)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewRepo creates a new instance of Repo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Repo {
+	mock := &Repo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
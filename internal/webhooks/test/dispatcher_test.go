@@ -0,0 +1,116 @@
+package test
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	entities "user-segmentation/internal/entities/webhooks"
+	"user-segmentation/internal/webhooks"
+	"user-segmentation/internal/webhooks/mocks"
+)
+
+const (
+	secret  = "whsec_test-secret"
+	payload = `{"id":7,"user_id":1,"segment":"slug","operation":"add","time":"2023-08-31T12:00:00.000000Z"}`
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func receiver(t *testing.T, status int) (*httptest.Server, chan received) {
+	ch := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		ch <- received{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, ch
+}
+
+func dispatcher(r webhooks.Repo) webhooks.Dispatcher {
+	return webhooks.Dispatcher{
+		Repo:        r,
+		Client:      &http.Client{Timeout: time.Second},
+		Interval:    time.Hour,
+		BatchSize:   10,
+		Concurrency: 2,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+	}
+}
+
+func claimRepo(t *testing.T, url string, attempts int) *mocks.Repo {
+	r := mocks.NewRepo(t)
+	r.
+		On("Claim", mock.Anything, 10, mock.AnythingOfType("time.Time")).
+		Return([]entities.Job{{
+			Delivery: entities.Delivery{ID: 3, WebhookID: 5, OperationID: 7, Payload: []byte(payload), Attempts: attempts},
+			URL:      url,
+			Secret:   secret,
+		}}, nil).
+		Once()
+	return r
+}
+
+// run runs the dispatcher until the result of the delivery is recorded
+func run(t *testing.T, d webhooks.Dispatcher, r *mocks.Repo, method string, args ...any) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.
+		On(method, args...).
+		Return(nil).
+		Run(func(mock.Arguments) { cancel() }).
+		Once()
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatal("delivery result is not recorded")
+	}
+}
+
+func TestDispatcher_Delivered(t *testing.T) {
+	srv, ch := receiver(t, http.StatusNoContent)
+	r := claimRepo(t, srv.URL, 0)
+	run(t, dispatcher(r), r, "Complete", mock.Anything, int64(3), http.StatusNoContent)
+
+	req := <-ch
+	require.JSONEq(t, payload, string(req.body))
+	require.Equal(t, "3", req.header.Get(webhooks.HeaderDelivery))
+	require.Equal(t, "5", req.header.Get(webhooks.HeaderWebhookID))
+	ts, err := strconv.ParseInt(req.header.Get(webhooks.HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	require.Equal(t, entities.Sign(secret, time.Unix(ts, 0), req.body), req.header.Get(webhooks.HeaderSignature))
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	srv, ch := receiver(t, http.StatusInternalServerError)
+	r := claimRepo(t, srv.URL, 0)
+	start := time.Now()
+	run(t, dispatcher(r), r, "Fail", mock.Anything, int64(3), mock.Anything, mock.Anything,
+		mock.MatchedBy(func(at *time.Time) bool {
+			return at != nil && !at.Before(start.Add(time.Minute))
+		}),
+	)
+	<-ch
+	call := r.Calls[len(r.Calls)-1]
+	require.Equal(t, http.StatusInternalServerError, *call.Arguments.Get(2).(*int))
+}
+
+func TestDispatcher_Dead(t *testing.T) {
+	srv, _ := receiver(t, http.StatusBadGateway)
+	r := claimRepo(t, srv.URL, 2)
+	run(t, dispatcher(r), r, "Fail", mock.Anything, int64(3), mock.Anything, mock.Anything, (*time.Time)(nil))
+}
@@ -0,0 +1,76 @@
+// Package webhooks manages subscriptions to membership changes and sends queued deliveries
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-segmentation/internal/entities/webhooks"
+)
+
+const maxDeliveriesLimit = 1000
+
+var ErrInvalidLimit = errors.New("limit must be between 1 and 1000")
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=Repo
+type Repo interface {
+	Store(ctx context.Context, w webhooks.Webhook) (int64, error)
+	List(ctx context.Context) ([]webhooks.Webhook, error)
+	Delete(ctx context.Context, id int64) error
+	Deliveries(ctx context.Context, webhookID int64, status *webhooks.Status, limit int) ([]webhooks.Delivery, error)
+	Retry(ctx context.Context, webhookID int64, deliveryID int64) error
+	Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]webhooks.Job, error)
+	Complete(ctx context.Context, id int64, statusCode int) error
+	Fail(ctx context.Context, id int64, statusCode *int, reason string, retryAt *time.Time) error
+}
+
+// Webhooks manages subscriptions. Deliveries are queued by the database in transactions of operations
+type Webhooks struct {
+	Repo Repo
+}
+
+// Create subscribes the URL. The secret is returned only here, it signs every delivery
+func (w Webhooks) Create(ctx context.Context, url string, secret string, segments []string, ops []string) (webhooks.Webhook, error) {
+	hook, err := webhooks.New(url, secret, segments, ops)
+	if err != nil {
+		return webhooks.Webhook{}, err
+	}
+	hook.ID, err = w.Repo.Store(ctx, hook)
+	if err != nil {
+		return webhooks.Webhook{}, err
+	}
+	return hook, nil
+}
+
+func (w Webhooks) List(ctx context.Context) ([]webhooks.Webhook, error) {
+	return w.Repo.List(ctx)
+}
+
+func (w Webhooks) Delete(ctx context.Context, id int64) error {
+	return w.Repo.Delete(ctx, id)
+}
+
+// Deliveries returns the latest deliveries of the webhook. Empty status matches any status
+func (w Webhooks) Deliveries(ctx context.Context, webhookID int64, status string, limit int) ([]webhooks.Delivery, error) {
+	if limit < 1 || limit > maxDeliveriesLimit {
+		return nil, ErrInvalidLimit
+	}
+	var s *webhooks.Status
+	if status != "" {
+		parsed, err := webhooks.ParseStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		s = &parsed
+	}
+	return w.Repo.Deliveries(ctx, webhookID, s, limit)
+}
+
+// Retry sends the delivery again, e.g. a dead one after the receiver is fixed
+func (w Webhooks) Retry(ctx context.Context, webhookID int64, deliveryID int64) error {
+	return w.Repo.Retry(ctx, webhookID, deliveryID)
+}
+
+func New(repo Repo) Webhooks {
+	return Webhooks{Repo: repo}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE api_keys
+(
+    id         BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name       VARCHAR(255) NOT NULL,
+    role       SMALLINT     NOT NULL,
+    prefix     VARCHAR(16)  NOT NULL,
+    key_hash   BYTEA CONSTRAINT api_keys_key_hash_key UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
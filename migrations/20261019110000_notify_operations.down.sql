@@ -0,0 +1,2 @@
+DROP TRIGGER operations_notify ON operations;
+DROP FUNCTION notify_operation;
\ No newline at end of file
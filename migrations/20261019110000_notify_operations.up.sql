@@ -0,0 +1,14 @@
+CREATE FUNCTION notify_operation() RETURNS TRIGGER AS
+$$
+BEGIN
+    -- notifications are delivered on commit, the payload is small enough for any id
+    PERFORM pg_notify('operations', NEW.id::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER operations_notify
+    AFTER INSERT
+    ON operations
+    FOR EACH ROW
+EXECUTE FUNCTION notify_operation();
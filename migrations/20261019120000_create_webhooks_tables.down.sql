@@ -0,0 +1,4 @@
+DROP TRIGGER operations_webhooks ON operations;
+DROP FUNCTION enqueue_webhook_deliveries;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
\ No newline at end of file
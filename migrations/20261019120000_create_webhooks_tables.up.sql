@@ -0,0 +1,59 @@
+CREATE TABLE webhooks
+(
+    id         BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(255)  NOT NULL,
+    segments   TEXT[]        NOT NULL DEFAULT '{}',
+    operations SMALLINT[]    NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ   NOT NULL
+);
+
+CREATE TABLE webhook_deliveries
+(
+    id              BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    webhook_id      BIGINT      NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    operation_id    BIGINT      NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          SMALLINT    NOT NULL DEFAULT 0,
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_status     INT,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL,
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 0;
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+
+-- deliveries are queued in the transaction of the operation, so none is lost or created for a rolled back one
+CREATE FUNCTION enqueue_webhook_deliveries() RETURNS TRIGGER AS
+$$
+DECLARE
+    queued_at TIMESTAMPTZ := now();
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, operation_id, payload, next_attempt_at, created_at)
+    SELECT webhooks.id,
+           NEW.id,
+           jsonb_build_object(
+                   'id', NEW.id,
+                   'user_id', NEW.user_id,
+                   'segment', segments.slug,
+                   'operation', CASE NEW.type WHEN 0 THEN 'add' ELSE 'remove' END,
+                   'time', to_char(NEW.time, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+           ),
+           queued_at,
+           queued_at
+    FROM webhooks
+             JOIN segments ON segments.id = NEW.segment_id
+    WHERE (cardinality(webhooks.segments) = 0 OR segments.slug = ANY (webhooks.segments))
+      AND (cardinality(webhooks.operations) = 0 OR NEW.type = ANY (webhooks.operations));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER operations_webhooks
+    AFTER INSERT
+    ON operations
+    FOR EACH ROW
+EXECUTE FUNCTION enqueue_webhook_deliveries();
@@ -0,0 +1,13 @@
+CREATE TABLE outbox
+(
+    id         BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    key        VARCHAR(64) CONSTRAINT outbox_key_key UNIQUE NOT NULL,
+    topic      VARCHAR(255) NOT NULL,
+    payload    JSONB        NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL,
+    attempts   INT          NOT NULL DEFAULT 0,
+    last_error TEXT,
+    sent_at    TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_unsent_idx ON outbox (id) WHERE sent_at IS NULL;
@@ -0,0 +1,8 @@
+DROP INDEX segments_owner_idx;
+DROP INDEX segments_tags_idx;
+ALTER TABLE segments
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at,
+    DROP COLUMN tags,
+    DROP COLUMN owner,
+    DROP COLUMN description;
\ No newline at end of file
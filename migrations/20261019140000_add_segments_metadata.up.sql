@@ -0,0 +1,8 @@
+ALTER TABLE segments
+    ADD COLUMN description TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN owner       TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN tags        TEXT[]      NOT NULL DEFAULT '{}',
+    ADD COLUMN created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at  TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX segments_tags_idx ON segments USING GIN (tags);
+CREATE INDEX segments_owner_idx ON segments (owner);
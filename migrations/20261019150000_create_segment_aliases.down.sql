@@ -0,0 +1,29 @@
+CREATE OR REPLACE FUNCTION enqueue_webhook_deliveries() RETURNS TRIGGER AS
+$$
+DECLARE
+    queued_at TIMESTAMPTZ := now();
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, operation_id, payload, next_attempt_at, created_at)
+    SELECT webhooks.id,
+           NEW.id,
+           jsonb_build_object(
+                   'id', NEW.id,
+                   'user_id', NEW.user_id,
+                   'segment', segments.slug,
+                   'operation', CASE NEW.type WHEN 0 THEN 'add' ELSE 'remove' END,
+                   'time', to_char(NEW.time, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+           ),
+           queued_at,
+           queued_at
+    FROM webhooks
+             JOIN segments ON segments.id = NEW.segment_id
+    WHERE (cardinality(webhooks.segments) = 0 OR segments.slug = ANY (webhooks.segments))
+      AND (cardinality(webhooks.operations) = 0 OR NEW.type = ANY (webhooks.operations));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+DROP FUNCTION operation_name(SMALLINT);
+DELETE FROM operations WHERE details IS NOT NULL;
+ALTER TABLE operations DROP COLUMN details;
+DROP FUNCTION resolve_segment(TEXT);
+DROP TABLE segment_aliases;
\ No newline at end of file
@@ -0,0 +1,57 @@
+-- old slugs of renamed segments stay resolvable until expires_at
+CREATE TABLE segment_aliases
+(
+    slug       VARCHAR(255) PRIMARY KEY,
+    segment_id BIGINT      NOT NULL REFERENCES segments (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX segment_aliases_segment_id_idx ON segment_aliases (segment_id);
+
+-- resolve_segment returns id of the segment with the slug or with the unexpired alias
+CREATE FUNCTION resolve_segment(s TEXT) RETURNS BIGINT AS
+$$
+SELECT id
+FROM segments
+WHERE slug = s
+UNION ALL
+SELECT segment_id
+FROM segment_aliases
+WHERE slug = s
+  AND expires_at > now()
+LIMIT 1
+$$ LANGUAGE sql STABLE;
+
+-- operations of the segment itself have zero user_id and describe the change in details
+ALTER TABLE operations
+    ADD COLUMN details JSONB;
+
+CREATE FUNCTION operation_name(t SMALLINT) RETURNS TEXT AS
+$$
+SELECT CASE t WHEN 0 THEN 'add' WHEN 1 THEN 'remove' WHEN 2 THEN 'rename' END
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION enqueue_webhook_deliveries() RETURNS TRIGGER AS
+$$
+DECLARE
+    queued_at TIMESTAMPTZ := now();
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, operation_id, payload, next_attempt_at, created_at)
+    SELECT webhooks.id,
+           NEW.id,
+           jsonb_build_object(
+                   'id', NEW.id,
+                   'segment', segments.slug,
+                   'operation', operation_name(NEW.type),
+                   'time', to_char(NEW.time, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+           ) || CASE
+                    WHEN NEW.details IS NULL THEN jsonb_build_object('user_id', NEW.user_id)
+                    ELSE jsonb_build_object('details', NEW.details) END,
+           queued_at,
+           queued_at
+    FROM webhooks
+             JOIN segments ON segments.id = NEW.segment_id
+    WHERE (cardinality(webhooks.segments) = 0 OR segments.slug = ANY (webhooks.segments))
+      AND (cardinality(webhooks.operations) = 0 OR NEW.type = ANY (webhooks.operations));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,9 @@
+DROP FUNCTION addable_segment(TEXT);
+CREATE OR REPLACE FUNCTION operation_name(t SMALLINT) RETURNS TEXT AS
+$$
+SELECT CASE t WHEN 0 THEN 'add' WHEN 1 THEN 'remove' WHEN 2 THEN 'rename' END
+$$ LANGUAGE sql IMMUTABLE;
+DELETE FROM operations WHERE type = 3;
+ALTER TABLE segments
+    DROP COLUMN archived_from,
+    DROP COLUMN state;
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- state: 0 active, 1 draft, 2 paused, 3 archived. archived_from is the state to restore
+ALTER TABLE segments
+    ADD COLUMN state         SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN archived_from SMALLINT;
+
+CREATE OR REPLACE FUNCTION operation_name(t SMALLINT) RETURNS TEXT AS
+$$
+SELECT CASE t WHEN 0 THEN 'add' WHEN 1 THEN 'remove' WHEN 2 THEN 'rename' WHEN 3 THEN 'state' END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- addable_segment resolves the slug like resolve_segment, but fails with SQLSTATE SG001 for archived segments
+CREATE FUNCTION addable_segment(s TEXT) RETURNS BIGINT AS
+$$
+DECLARE
+    seg_id    BIGINT;
+    seg_state SMALLINT;
+BEGIN
+    SELECT id, state INTO seg_id, seg_state FROM segments WHERE id = resolve_segment(s);
+    IF seg_state = 3 THEN
+        RAISE EXCEPTION 'segment % is archived', s USING ERRCODE = 'SG001';
+    END IF;
+    RETURN seg_id;
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -0,0 +1,36 @@
+CREATE OR REPLACE FUNCTION enqueue_webhook_deliveries() RETURNS TRIGGER AS
+$$
+DECLARE
+    queued_at TIMESTAMPTZ := now();
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, operation_id, payload, next_attempt_at, created_at)
+    SELECT webhooks.id,
+           NEW.id,
+           jsonb_build_object(
+                   'id', NEW.id,
+                   'segment', segments.slug,
+                   'operation', operation_name(NEW.type),
+                   'time', to_char(NEW.time, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+           ) || CASE
+                    WHEN NEW.details IS NULL THEN jsonb_build_object('user_id', NEW.user_id)
+                    ELSE jsonb_build_object('details', NEW.details) END,
+           queued_at,
+           queued_at
+    FROM webhooks
+             JOIN segments ON segments.id = NEW.segment_id
+    WHERE (cardinality(webhooks.segments) = 0 OR segments.slug = ANY (webhooks.segments))
+      AND (cardinality(webhooks.operations) = 0 OR NEW.type = ANY (webhooks.operations));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE OR REPLACE FUNCTION operation_name(t SMALLINT) RETURNS TEXT AS
+$$
+SELECT CASE t WHEN 0 THEN 'add' WHEN 1 THEN 'remove' WHEN 2 THEN 'rename' WHEN 3 THEN 'state' END
+$$ LANGUAGE sql IMMUTABLE;
+DELETE FROM operations WHERE type IN (4, 5);
+DROP INDEX segments_active_until_idx;
+DROP INDEX segments_active_from_idx;
+ALTER TABLE segments
+    DROP COLUMN scheduled_active,
+    DROP COLUMN active_until,
+    DROP COLUMN active_from;
\ No newline at end of file
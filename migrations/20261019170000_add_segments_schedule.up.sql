@@ -0,0 +1,45 @@
+-- scheduled_active is the last state of the schedule recorded in history by the scheduler
+ALTER TABLE segments
+    ADD COLUMN active_from      TIMESTAMPTZ,
+    ADD COLUMN active_until     TIMESTAMPTZ,
+    ADD COLUMN scheduled_active BOOLEAN NOT NULL DEFAULT TRUE;
+CREATE INDEX segments_active_from_idx ON segments (active_from) WHERE active_from IS NOT NULL;
+CREATE INDEX segments_active_until_idx ON segments (active_until) WHERE active_until IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION operation_name(t SMALLINT) RETURNS TEXT AS
+$$
+SELECT CASE t
+           WHEN 0 THEN 'add'
+           WHEN 1 THEN 'remove'
+           WHEN 2 THEN 'rename'
+           WHEN 3 THEN 'state'
+           WHEN 4 THEN 'activate'
+           WHEN 5 THEN 'deactivate' END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- operations of the segment itself may have no details, so payloads are chosen by the type
+CREATE OR REPLACE FUNCTION enqueue_webhook_deliveries() RETURNS TRIGGER AS
+$$
+DECLARE
+    queued_at TIMESTAMPTZ := now();
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, operation_id, payload, next_attempt_at, created_at)
+    SELECT webhooks.id,
+           NEW.id,
+           jsonb_build_object(
+                   'id', NEW.id,
+                   'segment', segments.slug,
+                   'operation', operation_name(NEW.type),
+                   'time', to_char(NEW.time, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+           ) || CASE
+                    WHEN NEW.type IN (0, 1) THEN jsonb_build_object('user_id', NEW.user_id)
+                    ELSE jsonb_build_object('details', COALESCE(NEW.details, '{}'::JSONB)) END,
+           queued_at,
+           queued_at
+    FROM webhooks
+             JOIN segments ON segments.id = NEW.segment_id
+    WHERE (cardinality(webhooks.segments) = 0 OR segments.slug = ANY (webhooks.segments))
+      AND (cardinality(webhooks.operations) = 0 OR NEW.type = ANY (webhooks.operations));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+DROP TABLE experiment_variants;
+DROP TABLE experiments;
\ No newline at end of file
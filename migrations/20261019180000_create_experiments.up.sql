@@ -0,0 +1,20 @@
+CREATE TABLE experiments
+(
+    id          BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    key         VARCHAR(100)  NOT NULL UNIQUE,
+    description VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ   NOT NULL DEFAULT now()
+);
+
+-- users are assigned to variants by membership in their segments, position keeps the order of variants for hashing.
+-- segments of variants cannot be purged while the experiment exists
+CREATE TABLE experiment_variants
+(
+    experiment_id BIGINT       NOT NULL REFERENCES experiments (id) ON DELETE CASCADE,
+    position      SMALLINT     NOT NULL,
+    name          VARCHAR(100) NOT NULL,
+    weight        INT          NOT NULL CHECK (weight >= 0),
+    segment_id    BIGINT       NOT NULL UNIQUE REFERENCES segments (id) ON DELETE RESTRICT,
+    PRIMARY KEY (experiment_id, position),
+    UNIQUE (experiment_id, name)
+);
@@ -0,0 +1,4 @@
+DROP TRIGGER user_segments_exclusive_group ON user_segments;
+DROP FUNCTION check_exclusive_group();
+ALTER TABLE segments DROP COLUMN group_id;
+DROP TABLE segment_groups;
\ No newline at end of file
@@ -0,0 +1,48 @@
+-- a user is in at most one segment of an exclusive group
+CREATE TABLE segment_groups
+(
+    id         BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name       VARCHAR(255) NOT NULL UNIQUE,
+    exclusive  BOOLEAN      NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+ALTER TABLE segments
+    ADD COLUMN group_id BIGINT REFERENCES segment_groups (id) ON DELETE SET NULL;
+CREATE INDEX segments_group_id_idx ON segments (group_id) WHERE group_id IS NOT NULL;
+
+-- check_exclusive_group fails with SQLSTATE SG002 when the user is already in another segment of the exclusive group.
+-- Changes of the user are serialized by the advisory lock, and the check sees memberships committed while waiting
+CREATE FUNCTION check_exclusive_group() RETURNS TRIGGER AS
+$$
+DECLARE
+    group_name TEXT;
+    other      TEXT;
+BEGIN
+    SELECT segment_groups.name INTO group_name
+    FROM segments
+             JOIN segment_groups ON segment_groups.id = segments.group_id AND segment_groups.exclusive
+    WHERE segments.id = NEW.segment_id;
+    IF group_name IS NULL THEN
+        RETURN NEW;
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('segment_groups'), hashtext(NEW.user_id::TEXT));
+    SELECT others.slug INTO other
+    FROM segments
+             JOIN segments others ON others.group_id = segments.group_id AND others.id <> segments.id
+             JOIN user_segments ON user_segments.segment_id = others.id AND user_segments.user_id = NEW.user_id
+    WHERE segments.id = NEW.segment_id
+    LIMIT 1;
+    IF other IS NOT NULL THEN
+        RAISE EXCEPTION 'user % is already in segment % of exclusive group %', NEW.user_id, other, group_name
+            USING ERRCODE = 'SG002', DETAIL = format('group %s, segment %s', group_name, other);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER user_segments_exclusive_group
+    BEFORE INSERT
+    ON user_segments
+    FOR EACH ROW
+EXECUTE FUNCTION check_exclusive_group();
@@ -0,0 +1,16 @@
+CREATE OR REPLACE FUNCTION operation_name(t SMALLINT) RETURNS TEXT AS
+$$
+SELECT CASE t
+           WHEN 0 THEN 'add'
+           WHEN 1 THEN 'remove'
+           WHEN 2 THEN 'rename'
+           WHEN 3 THEN 'state'
+           WHEN 4 THEN 'activate'
+           WHEN 5 THEN 'deactivate' END
+$$ LANGUAGE sql IMMUTABLE;
+DELETE FROM operations WHERE type = 6;
+DROP TABLE segment_rollout_steps;
+DROP INDEX segments_rollout_idx;
+ALTER TABLE segments
+    DROP COLUMN rollout_salt,
+    DROP COLUMN rollout_buckets;
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- rollout_buckets of 10000 buckets include users in the segment, NULL disables the rollout.
+-- The salt is kept when the rollout is disabled, so enabling it again includes the same users
+ALTER TABLE segments
+    ADD COLUMN rollout_buckets INTEGER CHECK (rollout_buckets BETWEEN 0 AND 10000),
+    ADD COLUMN rollout_salt    VARCHAR(64);
+CREATE INDEX segments_rollout_idx ON segments (id) WHERE rollout_buckets IS NOT NULL;
+
+-- steps of the automatic ramp are deleted when the scheduler applies them
+CREATE TABLE segment_rollout_steps
+(
+    segment_id BIGINT      NOT NULL REFERENCES segments (id) ON DELETE CASCADE,
+    apply_at   TIMESTAMPTZ NOT NULL,
+    buckets    INTEGER     NOT NULL CHECK (buckets BETWEEN 0 AND 10000),
+    PRIMARY KEY (segment_id, apply_at)
+);
+CREATE INDEX segment_rollout_steps_at_idx ON segment_rollout_steps (apply_at);
+
+CREATE OR REPLACE FUNCTION operation_name(t SMALLINT) RETURNS TEXT AS
+$$
+SELECT CASE t
+           WHEN 0 THEN 'add'
+           WHEN 1 THEN 'remove'
+           WHEN 2 THEN 'rename'
+           WHEN 3 THEN 'state'
+           WHEN 4 THEN 'activate'
+           WHEN 5 THEN 'deactivate'
+           WHEN 6 THEN 'rollout' END
+$$ LANGUAGE sql IMMUTABLE;
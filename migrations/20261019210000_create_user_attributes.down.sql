@@ -0,0 +1,4 @@
+DROP INDEX segments_rule_idx;
+ALTER TABLE segments
+    DROP COLUMN rule;
+DROP TABLE user_attributes;
\ No newline at end of file
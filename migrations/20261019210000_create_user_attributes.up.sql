@@ -0,0 +1,11 @@
+-- attributes of users are matched by rules of dynamic segments
+CREATE TABLE user_attributes
+(
+    user_id    BIGINT PRIMARY KEY,
+    attributes JSONB       NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE segments
+    ADD COLUMN rule TEXT;
+CREATE INDEX segments_rule_idx ON segments (id) WHERE rule IS NOT NULL;
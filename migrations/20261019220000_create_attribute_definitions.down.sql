@@ -0,0 +1,2 @@
+DROP TABLE user_attribute_history;
+DROP TABLE attribute_definitions;
\ No newline at end of file
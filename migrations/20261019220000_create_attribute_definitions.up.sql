@@ -0,0 +1,32 @@
+-- users have only the attributes defined here, values are checked against the type by the service
+CREATE TABLE attribute_definitions
+(
+    name        VARCHAR(64) PRIMARY KEY,
+    type        VARCHAR(16) NOT NULL CHECK (type IN ('string', 'number', 'bool', 'date', 'string_list')),
+    description TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- attributes stored before the schema are defined by their JSON types, the type of the first user wins.
+-- Objects have no attribute type, so they are skipped and stay undefined
+INSERT INTO attribute_definitions (name, type)
+SELECT DISTINCT ON (key) key,
+       CASE jsonb_typeof(value)
+           WHEN 'number' THEN 'number'
+           WHEN 'boolean' THEN 'bool'
+           WHEN 'array' THEN 'string_list'
+           ELSE 'string' END
+FROM user_attributes, jsonb_each(attributes)
+WHERE jsonb_typeof(value) <> 'object'
+ORDER BY key, user_id;
+
+CREATE TABLE user_attribute_history
+(
+    id         BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id    BIGINT      NOT NULL,
+    name       VARCHAR(64) NOT NULL,
+    old_value  JSONB,
+    new_value  JSONB,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX user_attribute_history_user_idx ON user_attribute_history (user_id, id);
@@ -0,0 +1,4 @@
+ALTER TABLE segments
+    DROP COLUMN payload_priority,
+    DROP COLUMN payload_schema,
+    DROP COLUMN payload;
\ No newline at end of file
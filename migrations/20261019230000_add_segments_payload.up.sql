@@ -0,0 +1,5 @@
+-- payloads of the segments of the user are merged into the configuration of the user by priority
+ALTER TABLE segments
+    ADD COLUMN payload          JSONB CHECK (jsonb_typeof(payload) = 'object'),
+    ADD COLUMN payload_schema   JSONB,
+    ADD COLUMN payload_priority INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP TABLE flag_overrides;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- values of flags set for users win over segments and experiments
+CREATE TABLE flag_overrides
+(
+    user_id    BIGINT       NOT NULL,
+    key        VARCHAR(255) NOT NULL,
+    value      JSONB        NOT NULL CHECK (jsonb_typeof(value) IN ('boolean', 'string')),
+    updated_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, key)
+);
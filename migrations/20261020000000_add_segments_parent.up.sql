@@ -0,0 +1,5 @@
+-- segments form trees: membership in a segment implies membership in its ancestors for reads.
+-- Parents are not purged while they have children, and cycles are rejected by the application
+ALTER TABLE segments
+    ADD COLUMN parent_id BIGINT REFERENCES segments (id) ON DELETE RESTRICT;
+CREATE INDEX segments_parent_id_idx ON segments (parent_id) WHERE parent_id IS NOT NULL;
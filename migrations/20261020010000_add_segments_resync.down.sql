@@ -0,0 +1 @@
+ALTER TABLE segments DROP COLUMN resync_claimed_until, DROP COLUMN resync_at;
@@ -0,0 +1,9 @@
+-- members of segments with rollouts and rules are recomputed for all known users by the scheduler
+-- after the percent or the rule changes. resync_at is the time of the latest change not recomputed yet,
+-- resync_claimed_until is the lease of the replica recomputing the segment
+ALTER TABLE segments
+    ADD COLUMN resync_at            TIMESTAMPTZ,
+    ADD COLUMN resync_claimed_until TIMESTAMPTZ;
+CREATE INDEX segments_resync_at_idx ON segments (resync_at) WHERE resync_at IS NOT NULL;
+-- members were recomputed on reads before, users who have not read since the latest change are recomputed once
+UPDATE segments SET resync_at = now() WHERE rollout_buckets IS NOT NULL OR rule IS NOT NULL;
@@ -0,0 +1,31 @@
+// Package migrations embeds SQL migrations of the database schema
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Version returns the latest migration version, i.e. the schema version expected by the application
+func Version() (uint, error) {
+	files, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest uint64
+	for _, f := range files {
+		prefix, _, _ := strings.Cut(f, "_")
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration name %s: %w", f, err)
+		}
+		latest = max(latest, v)
+	}
+	return uint(latest), nil
+}
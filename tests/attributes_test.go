@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUserAttributes(t *testing.T) {
+	client := setupClient()
+	plan, seats, tags, trial := attributeName("plan"), attributeName("seats"), attributeName("tags"), attributeName("trial_end")
+	_, err := client.defineAttribute(plan, "enum")
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.defineAttribute("Plan", "string")
+	require.ErrorIs(t, err, ErrBadRequest)
+	for name, typ := range map[string]string{plan: "string", seats: "number", tags: "string_list", trial: "date"} {
+		res, err := client.defineAttribute(name, typ)
+		require.NoError(t, err)
+		require.Equal(t, typ, res.Data.Type)
+	}
+	_, err = client.defineAttribute(plan, "number")
+	require.ErrorIs(t, err, ErrConflict)
+
+	first := int64(randInt(1_000_000_000) + 1)
+	second := int64(randInt(1_000_000_000) + 1)
+	_, err = client.setUserAttributes(first, map[string]any{seats: "ten"})
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setUserAttributes(first, map[string]any{attributeName("undefined"): "x"})
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setUserAttributes(first, map[string]any{plan: "free", seats: 1})
+	require.NoError(t, err)
+
+	_, err = client.upsertUserAttributes([]map[string]any{
+		{"user_id": first, "attributes": map[string]any{seats: 10}},
+		{"user_id": second, "attributes": map[string]any{trial: "2024-13-01"}},
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+	res, err := client.getUserAttributes(first)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, res.Data.Attributes[seats], "bulk changes are applied atomically")
+
+	bulk, err := client.upsertUserAttributes([]map[string]any{
+		{"user_id": first, "attributes": map[string]any{seats: 10, plan: nil, tags: []string{"beta"}}},
+		{"user_id": second, "attributes": map[string]any{trial: "2024-12-01"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, bulk.Data, 2)
+	res, err = client.getUserAttributes(first)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{seats: 10.0, tags: []any{"beta"}}, res.Data.Attributes)
+	res, err = client.getUserAttributes(second)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{trial: "2024-12-01"}, res.Data.Attributes)
+
+	history, err := client.getUserAttributeHistory(first)
+	require.NoError(t, err)
+	var changes []attributeChange
+	for _, ch := range history.Data {
+		changes = append(changes, attributeChange{Name: ch.Name, Old: ch.Old, New: ch.New})
+	}
+	// newest first, changes of one request are recorded in the order of names
+	require.Equal(t, []attributeChange{
+		{Name: tags, New: []any{"beta"}},
+		{Name: seats, Old: 1.0, New: 10.0},
+		{Name: plan, Old: "free"},
+		{Name: seats, New: 1.0},
+		{Name: plan, New: "free"},
+	}, changes)
+
+	_, err = client.deleteAttribute(trial)
+	require.ErrorIs(t, err, ErrConflict, "attributes set for users are kept in the schema")
+	_, err = client.upsertUserAttributes([]map[string]any{{"user_id": second, "attributes": map[string]any{trial: nil}}})
+	require.NoError(t, err)
+	_, err = client.deleteAttribute(trial)
+	require.NoError(t, err)
+	_, err = client.deleteAttribute(trial)
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -17,10 +17,12 @@ const (
 	mainUser  = 1
 )
 
+// randString returns random letters and digits, so the string is safe in URL paths
 func randString(sz int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	res := make([]byte, sz)
-	if _, err := rand.Read(res); err != nil {
-		return ""
+	for i := range res {
+		res[i] = alphabet[randInt(len(alphabet))]
 	}
 	return string(res)
 }
@@ -133,6 +135,13 @@ func TestUserSegments(t *testing.T) {
 	require.True(t, res.Data.Done)
 	require.Empty(t, res.Data.Errors)
 
+	if len(add) > 1 {
+		res, err = client.changeUserSegments(mainUser, []string{}, add[1:2])
+		require.ErrorIs(t, err, ErrBadRequest)
+		require.False(t, res.Data.Done)
+		require.Equal(t, repo.ErrRelationNotFound.Error(), res.Data.Errors[add[1]])
+	}
+
 	resGet, err = client.getUserSegments(mainUser)
 	require.NoError(t, err)
 	require.Len(t, resGet.Data, 1)
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+	"user-segmentation/internal/entities/experiments"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/repo/history"
+	"user-segmentation/internal/repo/segments"
+	"user-segmentation/internal/service"
+)
+
+func TestExperimentAssignment(t *testing.T) {
+	client := setupClient()
+	key := "exp_" + strconv.Itoa(randInt(1_000_000_000))
+	variants := []map[string]any{{"name": "control", "weight": 1}, {"name": "treatment", "weight": 1}}
+	_, err := client.createExperiment(map[string]any{"key": key, "variants": variants[:1]})
+	require.ErrorIs(t, err, ErrBadRequest)
+	created, err := client.createExperiment(map[string]any{"key": key, "description": "checkout", "variants": variants})
+	require.NoError(t, err)
+	require.Len(t, created.Data.Variants, 2)
+	require.Equal(t, key+".control", created.Data.Variants[0].Segment)
+	_, err = client.createExperiment(map[string]any{"key": key, "variants": variants})
+	require.ErrorIs(t, err, ErrConflict)
+	got, err := client.getExperiment(key)
+	require.NoError(t, err)
+	require.Equal(t, created.Data.Variants, got.Data.Variants)
+
+	exp, err := experiments.New(key, "", []experiments.Variant{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 1}})
+	require.NoError(t, err)
+	userID := int64(randInt(1_000_000) + 1)
+	want := exp.Assign(userID)
+	for i := 0; i < 2; i++ {
+		res, err := client.getUserSegments(userID)
+		require.NoError(t, err)
+		require.Contains(t, res.Data, segment{Slug: want.Segment.Slug, Experiment: key, Variant: want.Name})
+	}
+
+	now := time.Now().UTC()
+	ops, err := service.New(segments.New(db), history.New(db)).GetOperations(context.Background(), now.Year(), int(now.Month()))
+	require.NoError(t, err)
+	var assignments []operations.Operation
+	for _, op := range ops {
+		if op.UserID == userID && op.Details[operations.DetailExperiment] == key {
+			assignments = append(assignments, op)
+		}
+	}
+	require.Len(t, assignments, 1, "the assignment is logged once")
+	require.Equal(t, operations.Add, assignments[0].Type)
+	require.Equal(t, want.Name, assignments[0].Details[operations.DetailVariant])
+
+	_, err = client.changeUserSegments(userID, nil, []string{want.Segment.Slug})
+	require.NoError(t, err)
+	res, err := client.getUserSegments(userID)
+	require.NoError(t, err)
+	require.Contains(t, res.Data, segment{Slug: want.Segment.Slug, Experiment: key, Variant: want.Name},
+		"removed users are assigned to the same variant again")
+
+	variant := created.Data.Variants[1].Segment
+	_, err = client.deleteSegment(variant)
+	require.NoError(t, err)
+	purged, err := client.purgeSegment(variant, variant)
+	require.ErrorIs(t, err, ErrConflict)
+	require.Equal(t, experiments.ErrSegmentInUse.Error(), purged.Error)
+	got, err = client.getExperiment(key)
+	require.NoError(t, err)
+	require.Len(t, got.Data.Variants, 2, "segments of variants are kept")
+}
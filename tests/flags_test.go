@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/entities/experiments"
+)
+
+func TestUserFlags(t *testing.T) {
+	client := setupClient()
+	beta, paused, unknown := randString(20), randString(20), randString(20)
+	for _, slug := range []string{beta, paused} {
+		_, err := client.createSegment(slug)
+		require.NoError(t, err)
+	}
+	key := "exp_" + strconv.Itoa(randInt(1_000_000_000))
+	_, err := client.createExperiment(map[string]any{"key": key, "variants": []map[string]any{
+		{"name": "control", "weight": 1}, {"name": "treatment", "weight": 1},
+	}})
+	require.NoError(t, err)
+	exp, err := experiments.New(key, "", []experiments.Variant{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 1}})
+	require.NoError(t, err)
+	userID := int64(randInt(1_000_000_000) + 1)
+	_, err = client.changeUserSegments(userID, []string{beta, paused}, nil)
+	require.NoError(t, err)
+	_, err = client.setSegmentState(paused, "paused")
+	require.NoError(t, err)
+
+	_, err = client.getUserFlags(userID, beta+",,"+key)
+	require.ErrorIs(t, err, ErrBadRequest)
+	keys := beta + "," + paused + "," + key + "," + unknown
+	res, err := client.getUserFlags(userID, keys)
+	require.NoError(t, err)
+	require.Equal(t, map[string]httpserver.FlagResponse{
+		beta:    {Value: true, Source: "segment"},
+		paused:  {Value: false, Source: "segment"},
+		key:     {Value: exp.Assign(userID).Name, Source: "experiment"},
+		unknown: {Value: false, Source: "default"},
+	}, res.Data.Flags)
+
+	_, err = client.setFlagOverride(userID, beta, 1)
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setFlagOverride(userID, beta, false)
+	require.NoError(t, err)
+	_, err = client.setFlagOverride(userID, unknown, "on")
+	require.NoError(t, err)
+	res, err = client.getUserFlags(userID, keys)
+	require.NoError(t, err)
+	require.Equal(t, httpserver.FlagResponse{Value: false, Source: "override"}, res.Data.Flags[beta])
+	require.Equal(t, httpserver.FlagResponse{Value: "on", Source: "override"}, res.Data.Flags[unknown])
+
+	_, err = client.deleteFlagOverride(userID, beta)
+	require.NoError(t, err)
+	_, err = client.deleteFlagOverride(userID, beta)
+	require.ErrorIs(t, err, ErrNotFound)
+	res, err = client.getUserFlags(userID, beta)
+	require.NoError(t, err)
+	require.Equal(t, httpserver.FlagResponse{Value: true, Source: "segment"}, res.Data.Flags[beta])
+
+	rollout := randString(20)
+	_, err = client.createSegment(rollout)
+	require.NoError(t, err)
+	defer func() { _, _ = client.deleteSegment(rollout) }()
+	_, err = client.setSegmentRollout(rollout, map[string]any{"percent": 100})
+	require.NoError(t, err)
+	res, err = client.getUserFlags(int64(randInt(1_000_000_000)+1), rollout)
+	require.NoError(t, err)
+	require.Equal(t, httpserver.FlagResponse{Value: true, Source: "segment"}, res.Data.Flags[rollout],
+		"rollouts are matched for users without recorded membership")
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+)
+
+func TestExclusiveGroups(t *testing.T) {
+	client := setupClient()
+	name := randString(20)
+	_, err := client.createGroup(name, true)
+	require.NoError(t, err)
+	_, err = client.createGroup(name, false)
+	require.ErrorIs(t, err, ErrConflict)
+	a, b, c := randString(20), randString(20), randString(20)
+	for _, slug := range []string{a, b, c} {
+		_, err := client.createSegment(slug)
+		require.NoError(t, err)
+	}
+	for _, slug := range []string{a, b} {
+		res, err := client.setSegmentGroup(slug, name)
+		require.NoError(t, err)
+		require.Equal(t, name, res.Data.Group)
+	}
+	_, err = client.setSegmentGroup(c, randString(20))
+	require.ErrorIs(t, err, ErrNotFound)
+
+	userID := int64(randInt(1_000_000) + 1)
+	_, err = client.changeUserSegments(userID, []string{a}, []string{})
+	require.NoError(t, err)
+	res, err := client.changeUserSegments(userID, []string{b}, []string{})
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.Contains(t, res.Data.Errors[b], a, "the error names the conflicting segment")
+	res, err = client.changeUserSegments(userID, []string{c, b}, []string{a})
+	require.NoError(t, err, "removing from the group frees the place")
+	require.True(t, res.Data.Done)
+
+	_, err = client.replaceUserSegments(userID, []string{a}, []string{})
+	require.NoError(t, err)
+	segs, err := client.getUserSegments(userID)
+	require.NoError(t, err)
+	require.True(t, slices.Contains(segs.Data, segment{Slug: a}))
+	require.False(t, slices.Contains(segs.Data, segment{Slug: b}), "the member of the group is replaced")
+
+	_, err = client.changeUserSegments(userID, []string{b}, []string{})
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setSegmentGroup(c, name)
+	require.ErrorIs(t, err, ErrConflict, "users of the segment are in another segment of the group")
+	_, err = client.setSegmentGroup(a, "")
+	require.NoError(t, err)
+	_, err = client.changeUserSegments(userID, []string{b}, []string{})
+	require.NoError(t, err, "segments out of the group do not conflict")
+}
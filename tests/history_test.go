@@ -73,3 +73,34 @@ func TestHistoryDates(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, res, 1)
 }
+
+func TestHistoryAfter(t *testing.T) {
+	ctx := context.Background()
+	client := setupClient()
+	slug := randString(20)
+	userID := int64(randInt(1_000_000) + 1)
+	_, err := client.createSegment(slug)
+	require.NoError(t, err)
+	_, err = client.changeUserSegments(userID, []string{slug}, []string{})
+	require.NoError(t, err)
+	_, err = client.changeUserSegments(userID, []string{}, []string{slug})
+	require.NoError(t, err)
+
+	r := history.New(db)
+	filter := operations.Filter{UserID: userID, Segment: slug}
+	ops, err := r.After(ctx, 0, filter, 10)
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	require.Equal(t, operations.Add, ops[0].Type)
+	require.Equal(t, operations.Remove, ops[1].Type)
+	require.Less(t, ops[0].ID, ops[1].ID)
+
+	ops, err = r.After(ctx, ops[0].ID, filter, 10)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	require.Equal(t, operations.Remove, ops[0].Type)
+
+	byIDs, err := r.GetByIDs(ctx, []int64{ops[0].ID})
+	require.NoError(t, err)
+	require.Equal(t, ops, byIDs)
+}
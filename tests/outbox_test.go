@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	entities "user-segmentation/internal/entities/segments"
+	relay "user-segmentation/internal/outbox"
+	"user-segmentation/internal/repo"
+	"user-segmentation/internal/repo/history"
+	"user-segmentation/internal/repo/outbox"
+	"user-segmentation/internal/repo/segments"
+	"user-segmentation/internal/service"
+)
+
+func TestOutboxEvents(t *testing.T) {
+	ctx := context.Background()
+	_, _ = db.Exec(ctx, "DELETE FROM outbox")
+	tx := repo.NewTransactor(db)
+	o := outbox.New(db)
+	s := service.New(segments.New(db), history.New(db), service.WithTransactions(tx), service.WithOutbox(o))
+	slug := randString(20)
+	userID := int64(randInt(1_000_000) + 1)
+	require.NoError(t, s.CreateSegment(ctx, entities.Segment{Slug: slug}))
+
+	errs, err := s.ChangeUserSegments(ctx, userID, []string{slug, randString(20)}, nil)
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+	events, err := o.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, events, "rejected changes are rolled back with their events")
+
+	errs, err = s.ChangeUserSegments(ctx, userID, []string{slug}, nil)
+	require.NoError(t, err)
+	require.Empty(t, errs)
+	errs, err = s.ChangeUserSegments(ctx, userID, nil, []string{slug})
+	require.NoError(t, err)
+	require.Empty(t, errs)
+
+	var buf bytes.Buffer
+	r := relay.Relay{Repo: o, Tx: tx, Publisher: relay.NewWriterPublisher(&buf), Interval: time.Hour, BatchSize: 10}
+	runCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	require.NoError(t, r.Run(runCtx))
+
+	var ops []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var msg struct {
+			Payload struct {
+				UserID    int64  `json:"user_id"`
+				Segment   string `json:"segment"`
+				Operation string `json:"operation"`
+			} `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &msg))
+		require.Equal(t, userID, msg.Payload.UserID)
+		require.Equal(t, slug, msg.Payload.Segment)
+		ops = append(ops, msg.Payload.Operation)
+	}
+	require.Equal(t, []string{"add", "remove"}, ops)
+	events, err = o.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, events, "published events are marked as sent")
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUserConfig(t *testing.T) {
+	client := setupClient()
+	base, beta, plain := randString(20), randString(20), randString(20)
+	for _, slug := range []string{base, beta, plain} {
+		_, err := client.createSegment(slug)
+		require.NoError(t, err)
+	}
+	userID := int64(randInt(1_000_000_000) + 1)
+	_, err := client.changeUserSegments(userID, []string{base, beta, plain}, nil)
+	require.NoError(t, err)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"limit": map[string]any{"type": "integer", "minimum": 1}},
+	}
+	_, err = client.setSegmentPayload(base, map[string]any{"payload": map[string]any{"limit": 0}, "schema": schema})
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setSegmentPayload(base, map[string]any{"payload": []int{1}})
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setSegmentPayload(randString(20), map[string]any{"payload": map[string]any{}})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	info, err := client.setSegmentPayload(base, map[string]any{
+		"payload": map[string]any{"limit": 10, "theme": map[string]any{"color": "white", "font": "serif"}},
+		"schema":  schema,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, info.Data.Payload)
+	require.JSONEq(t, `{"limit": 10, "theme": {"color": "white", "font": "serif"}}`, string(info.Data.Payload.Value))
+	_, err = client.setSegmentPayload(beta, map[string]any{
+		"payload":  map[string]any{"limit": 20, "theme": map[string]any{"color": "black"}},
+		"priority": 10,
+	})
+	require.NoError(t, err)
+
+	cfg, err := client.getUserConfig(userID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"limit": 20.0,
+		"theme": map[string]any{"color": "black", "font": "serif"},
+	}, cfg.Data.Config)
+	require.Equal(t, []string{beta, base}, cfg.Data.Segments)
+
+	// payloads of inactive segments are not merged
+	_, err = client.setSegmentState(beta, "paused")
+	require.NoError(t, err)
+	cfg, err = client.getUserConfig(userID)
+	require.NoError(t, err)
+	require.Equal(t, []string{base}, cfg.Data.Segments)
+
+	info, err = client.removeSegmentPayload(base)
+	require.NoError(t, err)
+	require.Nil(t, info.Data.Payload)
+	cfg, err = client.getUserConfig(userID)
+	require.NoError(t, err)
+	require.Empty(t, cfg.Data.Config)
+	require.Empty(t, cfg.Data.Segments)
+}
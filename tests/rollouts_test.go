@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/repo/history"
+	"user-segmentation/internal/repo/segments"
+	"user-segmentation/internal/service"
+)
+
+func TestSegmentRollout(t *testing.T) {
+	client := setupClient()
+	ctx := context.Background()
+	slug := randString(20)
+	_, err := client.createSegment(slug)
+	require.NoError(t, err)
+	// segments with rollouts take users of other tests, archived segments do not
+	defer func() { _, _ = client.deleteSegment(slug) }()
+
+	// users are known to the service by their segments, the scheduler records membership of known users only
+	known := randString(20)
+	_, err = client.createSegment(known)
+	require.NoError(t, err)
+	defer func() { _, _ = client.deleteSegment(known) }()
+	users := make([]int64, 200)
+	for i := range users {
+		users[i] = int64(randInt(1_000_000_000) + 1)
+		_, err = client.changeUserSegments(users[i], []string{known}, nil)
+		require.NoError(t, err)
+	}
+	s := service.New(segments.New(db), history.New(db))
+	resync := func() {
+		t.Helper()
+		_, err := s.SyncDynamicSegments(ctx)
+		require.NoError(t, err)
+	}
+	included := func() map[int64]bool {
+		t.Helper()
+		res := make(map[int64]bool)
+		for _, userID := range users {
+			segs, err := client.getUserSegments(userID)
+			require.NoError(t, err)
+			if slices.Contains(segs.Data, segment{Slug: slug}) {
+				res[userID] = true
+			}
+		}
+		return res
+	}
+	subset := func(a, b map[int64]bool) bool {
+		for userID := range a {
+			if !b[userID] {
+				return false
+			}
+		}
+		return true
+	}
+
+	_, err = client.setSegmentRollout(slug, map[string]any{"percent": 100.5})
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setSegmentRollout(randString(20), map[string]any{"percent": 5})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	res, err := client.setSegmentRollout(slug, map[string]any{"percent": 20})
+	require.NoError(t, err)
+	require.Equal(t, 20.0, *res.Data.Percent)
+	small := included()
+	require.NotEmpty(t, small, "users are matched on reads")
+	require.Less(t, len(small), len(users))
+	resync()
+	require.Equal(t, small, included())
+	change, err := client.changeUserSegments(users[0], nil, []string{slug})
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.Contains(t, change.Data.Errors, slug, "members of rollouts are not changed explicitly")
+
+	_, err = client.setSegmentRollout(slug, map[string]any{"percent": 60})
+	require.NoError(t, err)
+	resync()
+	large := included()
+	require.True(t, subset(small, large), "included users stay included when the percent grows")
+	require.Greater(t, len(large), len(small))
+
+	_, err = client.setSegmentRollout(slug, map[string]any{"percent": 10})
+	require.NoError(t, err)
+	resync()
+	lowered := included()
+	require.True(t, subset(lowered, small), "lowering removes the highest buckets first")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	res, err = client.setSegmentRollout(slug, map[string]any{
+		"percent": 10,
+		"ramp": []map[string]any{
+			{"at": now.Add(2 * time.Minute), "percent": 100},
+			{"at": now.Add(time.Minute), "percent": 50},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Data.Ramp, 2)
+	require.Equal(t, 50.0, res.Data.Ramp[0].Percent)
+
+	advanced := func() []string {
+		t.Helper()
+		changes, err := s.AdvanceRollouts(ctx, now.Add(90*time.Second))
+		require.NoError(t, err)
+		var res []string
+		for _, ch := range changes {
+			if ch.Segment == slug {
+				res = append(res, fmt.Sprintf("%v-%v", *ch.From, *ch.To))
+			}
+		}
+		return res
+	}
+	require.Equal(t, []string{"10-50"}, advanced())
+	require.Empty(t, advanced(), "steps are applied once")
+	res, err = client.getSegmentRollout(slug)
+	require.NoError(t, err)
+	require.Equal(t, 50.0, *res.Data.Percent)
+	require.Len(t, res.Data.Ramp, 1)
+
+	res, err = client.disableSegmentRollout(slug)
+	require.NoError(t, err)
+	require.Nil(t, res.Data.Percent)
+	resync()
+	require.Equal(t, lowered, included(), "members are kept when the rollout is disabled")
+
+	ops, err := s.GetOperations(ctx, now.Year(), int(now.Month()))
+	require.NoError(t, err)
+	var percents []string
+	var added, removed int
+	for _, op := range ops {
+		if op.Segment.Slug != slug {
+			continue
+		}
+		switch op.Type {
+		case operations.Rollout:
+			percents = append(percents, op.Details[operations.DetailTo])
+		case operations.Add:
+			require.NotEmpty(t, op.Details[operations.DetailRollout])
+			added++
+		case operations.Remove:
+			removed++
+		}
+	}
+	// the step is recorded at its time, so operations are not ordered by the calls
+	require.ElementsMatch(t, []string{"20", "60", "10", "50", "off"}, percents)
+	require.Equal(t, len(large), added)
+	require.Equal(t, len(large)-len(lowered), removed)
+
+	_, err = client.setSegmentRollout(slug, map[string]any{"percent": 100})
+	require.NoError(t, err)
+	fresh := int64(randInt(1_000_000_000) + 1)
+	segs, err := client.getUserSegments(fresh)
+	require.NoError(t, err)
+	require.Contains(t, segs.Data, segment{Slug: slug}, "users without recorded state are matched as well")
+}
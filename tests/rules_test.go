@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/repo/history"
+	"user-segmentation/internal/repo/segments"
+	"user-segmentation/internal/service"
+)
+
+func TestSegmentRule(t *testing.T) {
+	client := setupClient()
+	slug := randString(20)
+	_, err := client.createSegment(slug)
+	require.NoError(t, err)
+	// segments with rules take users of other tests with matching attributes, archived segments do not
+	defer func() { _, _ = client.deleteSegment(slug) }()
+
+	// attributes are shared by all tests, so their names are random
+	country, age, signup := attributeName("country"), attributeName("age"), attributeName("signup_date")
+	_, err = client.setSegmentRule(slug, country+` == "RU"`)
+	require.ErrorIs(t, err, ErrBadRequest, "attributes of rules are defined")
+	for name, typ := range map[string]string{country: "string", age: "number", signup: "date"} {
+		_, err = client.defineAttribute(name, typ)
+		require.NoError(t, err)
+	}
+	_, err = client.setSegmentRule(slug, country+` = "RU"`)
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.setSegmentRule(randString(20), country+` == "RU"`)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	rule := country + ` in ("RU", "KZ") and ` + age + ` >= 18 and ` + signup + ` > 2024-01-01`
+	info, err := client.setSegmentRule(slug, rule)
+	require.NoError(t, err)
+	require.Equal(t, rule, info.Data.Rule)
+
+	matching := int64(randInt(1_000_000_000) + 1)
+	other := int64(randInt(1_000_000_000) + 1)
+	attrs, err := client.setUserAttributes(matching, map[string]any{country: "RU", age: 30, signup: "2024-03-15"})
+	require.NoError(t, err)
+	require.Equal(t, 30.0, attrs.Data.Attributes[age])
+	_, err = client.setUserAttributes(other, map[string]any{country: "RU", age: 17, signup: "2024-03-15"})
+	require.NoError(t, err)
+
+	has := func(userID int64) bool {
+		t.Helper()
+		segs, err := client.getUserSegments(userID)
+		require.NoError(t, err)
+		return slices.Contains(segs.Data, segment{Slug: slug})
+	}
+	require.True(t, has(matching))
+	require.False(t, has(other))
+
+	// attributes changes move users in and out of the segment
+	_, err = client.setUserAttributes(other, map[string]any{country: "RU", age: 18, signup: "2024-03-15"})
+	require.NoError(t, err)
+	_, err = client.setUserAttributes(matching, map[string]any{country: "BY", age: 30, signup: "2024-03-15"})
+	require.NoError(t, err)
+	require.False(t, has(matching))
+	require.True(t, has(other))
+
+	// members of rule segments are not changed explicitly
+	change, err := client.changeUserSegments(matching, []string{slug}, nil)
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.Contains(t, change.Data.Errors, slug)
+	require.False(t, has(matching))
+
+	// without a rule members are kept as explicit ones
+	_, err = client.setSegmentRule(slug, "")
+	require.NoError(t, err)
+	_, err = client.setUserAttributes(other, map[string]any{})
+	require.NoError(t, err)
+	require.True(t, has(other))
+
+	// users without attributes match negated rules
+	negated := randString(20)
+	_, err = client.createSegment(negated)
+	require.NoError(t, err)
+	_, err = client.setSegmentRule(negated, `not (`+country+` == "RU")`)
+	require.NoError(t, err)
+	segs, err := client.getUserSegments(int64(randInt(1_000_000_000) + 1))
+	require.NoError(t, err)
+	require.Contains(t, segs.Data, segment{Slug: negated})
+	_, err = client.deleteSegment(negated)
+	require.NoError(t, err)
+
+	now := time.Now()
+	ops, err := service.New(segments.New(db), history.New(db)).GetOperations(context.Background(), now.Year(), int(now.Month()))
+	require.NoError(t, err)
+	var changes []string
+	for _, op := range ops {
+		if op.Segment.Slug != slug || op.Details[operations.DetailRule] == "" {
+			continue
+		}
+		require.Equal(t, rule, op.Details[operations.DetailRule])
+		changes = append(changes, op.Type.String())
+	}
+	require.Equal(t, []string{"add", "add", "remove"}, changes)
+}
+
+// attributeName returns a random name of an attribute, names of attributes are lowercase
+func attributeName(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, randInt(1_000_000_000))
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+	"user-segmentation/internal/entities/operations"
+	"user-segmentation/internal/repo/history"
+	"user-segmentation/internal/repo/segments"
+	"user-segmentation/internal/service"
+)
+
+func TestSegmentMetadata(t *testing.T) {
+	client := setupClient()
+	slug, other := randString(20), randString(20)
+	owner, tag := randString(10), randString(10)
+	_, err := client.createSegmentWithBody(map[string]any{
+		"slug":        slug,
+		"description": "users in the experiment",
+		"owner":       owner,
+		"tags":        []string{" " + tag + " ", "exp", "EXP"},
+	})
+	require.NoError(t, err)
+	_, err = client.createSegment(other)
+	require.NoError(t, err)
+
+	res, err := client.listSegments(url.Values{"owner": {owner}}.Encode())
+	require.NoError(t, err)
+	require.Len(t, res.Data, 1)
+	seg := res.Data[0]
+	require.Equal(t, slug, seg.Slug)
+	require.Equal(t, "users in the experiment", seg.Description)
+	require.Equal(t, []string{"exp", tag}, seg.Tags, "tags are normalized")
+	require.False(t, seg.CreatedAt.IsZero())
+
+	updated, err := client.updateSegment(slug, map[string]any{"tags": []string{"archive"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"archive"}, updated.Data.Tags)
+	require.Equal(t, owner, updated.Data.Owner, "absent fields are kept")
+	require.Equal(t, "users in the experiment", updated.Data.Description)
+	require.True(t, updated.Data.UpdatedAt.After(seg.UpdatedAt))
+
+	res, err = client.listSegments(url.Values{"tag": {tag}}.Encode())
+	require.NoError(t, err)
+	require.Empty(t, res.Data)
+	res, err = client.listSegments(url.Values{"tag": {"archive"}, "owner": {owner}}.Encode())
+	require.NoError(t, err)
+	require.Len(t, res.Data, 1)
+
+	_, err = client.updateSegment(randString(20), map[string]any{"owner": "team"})
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = client.updateSegment(slug, map[string]any{"tags": []string{""}})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestSegmentRename(t *testing.T) {
+	client := setupClient()
+	slug, newSlug := randString(20), randString(20)
+	userID := int64(randInt(1_000_000) + 1)
+	_, err := client.createSegment(slug)
+	require.NoError(t, err)
+	_, err = client.changeUserSegments(userID, []string{slug}, []string{})
+	require.NoError(t, err)
+
+	res, err := client.renameSegment(slug, newSlug)
+	require.NoError(t, err)
+	require.Equal(t, newSlug, res.Data.Slug)
+	segs, err := client.getUserSegments(userID)
+	require.NoError(t, err)
+	require.Equal(t, []segment{{Slug: newSlug}}, segs.Data, "members are kept")
+
+	_, err = client.createSegment(slug)
+	require.ErrorIs(t, err, ErrConflict, "the old slug is reserved by the alias")
+	_, err = client.changeUserSegments(userID, []string{}, []string{slug})
+	require.NoError(t, err, "the old slug resolves to the segment")
+	_, err = client.renameSegment(newSlug, newSlug)
+	require.ErrorIs(t, err, ErrBadRequest)
+
+	other := randString(20)
+	_, err = client.createSegment(other)
+	require.NoError(t, err)
+	_, err = client.renameSegment(other, slug)
+	require.ErrorIs(t, err, ErrConflict)
+	_, err = client.renameSegment(newSlug, slug)
+	require.NoError(t, err, "the segment may take its own alias back")
+
+	now := time.Now().UTC()
+	history, err := client.getHistory(now.Year(), int(now.Month()))
+	require.NoError(t, err)
+	var renames int
+	for _, row := range history {
+		// history shows the current slug of the segment
+		if row[1] == slug && row[2] == "rename" {
+			require.Empty(t, row[0], "renaming is not an operation of a user")
+			renames++
+		}
+	}
+	require.Equal(t, 2, renames)
+}
+
+func TestSegmentLifecycle(t *testing.T) {
+	client := setupClient()
+	slug := randString(20)
+	userID := int64(randInt(1_000_000) + 1)
+	has := func(want bool) {
+		t.Helper()
+		res, err := client.getUserSegments(userID)
+		require.NoError(t, err)
+		require.Equal(t, want, slices.Contains(res.Data, segment{Slug: slug}))
+	}
+	_, err := client.createSegmentWithBody(map[string]any{"slug": slug, "state": "draft"})
+	require.NoError(t, err)
+	_, err = client.changeUserSegments(userID, []string{slug}, []string{})
+	require.NoError(t, err, "drafts accept members")
+	has(false)
+
+	_, err = client.setSegmentState(slug, "active")
+	require.NoError(t, err)
+	has(true)
+	_, err = client.setSegmentState(slug, "draft")
+	require.ErrorIs(t, err, ErrConflict)
+	res, err := client.setSegmentState(slug, "paused")
+	require.NoError(t, err)
+	require.Equal(t, "paused", res.Data.State)
+	has(false)
+
+	_, err = client.deleteSegment(slug)
+	require.NoError(t, err)
+	has(false)
+	other := randString(20)
+	_, err = client.createSegment(other)
+	require.NoError(t, err)
+	change, err := client.changeUserSegments(userID, []string{slug, other}, []string{})
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.Contains(t, change.Data.Errors, slug, "archived segments reject new members")
+
+	_, err = client.purgeSegment(slug, "")
+	require.ErrorIs(t, err, ErrBadRequest)
+	restored, err := client.restoreSegment(slug)
+	require.NoError(t, err)
+	require.Equal(t, "paused", restored.Data.State, "the state before archiving is restored")
+	_, err = client.setSegmentState(slug, "active")
+	require.NoError(t, err)
+	has(true)
+
+	_, err = client.purgeSegment(slug, slug)
+	require.ErrorIs(t, err, ErrConflict, "only archived segments are purged")
+	_, err = client.deleteSegment(slug)
+	require.NoError(t, err)
+	_, err = client.purgeSegment(slug, slug)
+	require.NoError(t, err)
+	_, err = client.restoreSegment(slug)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = client.createSegment(slug)
+	require.NoError(t, err, "the slug of the purged segment is free")
+}
+
+func TestSegmentSchedule(t *testing.T) {
+	client := setupClient()
+	ctx := context.Background()
+	s := service.New(segments.New(db), history.New(db))
+	slug := randString(20)
+	userID := int64(randInt(1_000_000) + 1)
+	has := func(want bool) {
+		t.Helper()
+		res, err := client.getUserSegments(userID)
+		require.NoError(t, err)
+		require.Equal(t, want, slices.Contains(res.Data, segment{Slug: slug}))
+	}
+	switched := func() []string {
+		t.Helper()
+		trs, err := s.SwitchSchedules(ctx, time.Now())
+		require.NoError(t, err)
+		var res []string
+		for _, tr := range trs {
+			if tr.Segment == slug {
+				res = append(res, map[bool]string{true: "activate", false: "deactivate"}[tr.Activate])
+			}
+		}
+		return res
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err := client.createSegmentWithBody(map[string]any{
+		"slug":         slug,
+		"active_from":  now.Add(time.Hour),
+		"active_until": now,
+	})
+	require.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createSegmentWithBody(map[string]any{"slug": slug, "active_from": now.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = client.changeUserSegments(userID, []string{slug}, []string{})
+	require.NoError(t, err)
+	has(false)
+	require.Empty(t, switched(), "segments start in the current state of their schedules")
+
+	upcoming, err := client.upcomingTransitions("until=" + url.QueryEscape(now.Add(2*time.Hour).Format(time.RFC3339)))
+	require.NoError(t, err)
+	require.True(t, slices.ContainsFunc(upcoming.Data, func(tr transition) bool {
+		return tr.Segment == slug && tr.Active && tr.At.Equal(now.Add(time.Hour))
+	}))
+
+	res, err := client.setSegmentSchedule(slug, map[string]any{"active_from": now.Add(-time.Hour)})
+	require.NoError(t, err)
+	require.Nil(t, res.Data.ActiveUntil)
+	has(true)
+	require.Equal(t, []string{"activate"}, switched())
+	require.Empty(t, switched(), "transitions are recorded once")
+
+	_, err = client.setSegmentSchedule(slug, map[string]any{"active_until": now.Add(-time.Minute)})
+	require.NoError(t, err)
+	has(false)
+	require.Equal(t, []string{"deactivate"}, switched())
+
+	ops, err := s.GetOperations(ctx, now.Year(), int(now.Month()))
+	require.NoError(t, err)
+	var types []operations.Type
+	for _, op := range ops {
+		if op.Segment.Slug == slug && !op.Type.IsMembership() {
+			types = append(types, op.Type)
+		}
+	}
+	require.ElementsMatch(t, []operations.Type{operations.Activate, operations.Deactivate}, types)
+	var exported []operations.Type
+	err = s.ExportOperations(ctx, now.Year(), int(now.Month()), func(op operations.Operation) error {
+		if op.Segment.Slug == slug && !op.Type.IsMembership() {
+			exported = append(exported, op.Type)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []operations.Type{operations.Activate, operations.Deactivate}, exported, "the export streams operations in order")
+
+	_, err = client.setSegmentSchedule(slug, map[string]any{})
+	require.NoError(t, err)
+	has(true)
+	_, err = client.setSegmentSchedule(randString(20), map[string]any{})
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -7,12 +7,16 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/goccy/go-json"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	httpserver "user-segmentation/internal/api/http"
+	"user-segmentation/internal/repo/attributes"
+	"user-segmentation/internal/repo/experiments"
+	"user-segmentation/internal/repo/flags"
 	"user-segmentation/internal/repo/history"
 	"user-segmentation/internal/repo/segments"
 	"user-segmentation/internal/service"
@@ -24,12 +28,15 @@ var (
 	ErrConflict   = errors.New("conflict")
 )
 
-var db *pgx.Conn
+var db *pgxpool.Pool
 
 func setupClient() *testClient {
 	a := service.New(
 		segments.New(db),
 		history.New(db),
+		service.WithExperiments(experiments.New(db)),
+		service.WithAttributes(attributes.New(db)),
+		service.WithFlags(flags.New(db)),
 	)
 	srv := httpserver.New(slog.Default(), ":8888", gin.ReleaseMode, a)
 	testSrv := httptest.NewServer(srv.Handler)
@@ -96,9 +103,12 @@ type segmentProcessedResponse struct {
 }
 
 func (tc *testClient) createSegment(slug string) (segmentProcessedResponse, error) {
-	body := map[string]any{
+	return tc.createSegmentWithBody(map[string]any{
 		"slug": slug,
-	}
+	})
+}
+
+func (tc *testClient) createSegmentWithBody(body map[string]any) (segmentProcessedResponse, error) {
 	var response segmentProcessedResponse
 	err := tc.proceed(body, http.MethodPost, "segments", &response)
 	return response, err
@@ -113,6 +123,155 @@ func (tc *testClient) deleteSegment(slug string) (segmentProcessedResponse, erro
 	return response, err
 }
 
+type segmentInfo httpserver.SegmentInfoResponse
+type segmentInfoResponse struct {
+	Data  segmentInfo `json:"data"`
+	Error string      `json:"error"`
+}
+
+type segmentInfoListResponse struct {
+	Data  []segmentInfo `json:"data"`
+	Error string        `json:"error"`
+}
+
+func (tc *testClient) updateSegment(slug string, body map[string]any) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(body, http.MethodPatch, "segments/"+slug, &response)
+	return response, err
+}
+
+func (tc *testClient) renameSegment(slug string, newSlug string) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(map[string]any{"slug": newSlug}, http.MethodPost, "segments/"+slug+"/rename", &response)
+	return response, err
+}
+
+func (tc *testClient) setSegmentState(slug string, state string) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(map[string]any{"state": state}, http.MethodPost, "segments/"+slug+"/state", &response)
+	return response, err
+}
+
+func (tc *testClient) restoreSegment(slug string) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(map[string]any{}, http.MethodPost, "segments/"+slug+"/restore", &response)
+	return response, err
+}
+
+func (tc *testClient) setSegmentSchedule(slug string, body map[string]any) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(body, http.MethodPut, "segments/"+slug+"/schedule", &response)
+	return response, err
+}
+
+type transition httpserver.TransitionResponse
+type transitionsResponse struct {
+	Data  []transition `json:"data"`
+	Error string       `json:"error"`
+}
+
+func (tc *testClient) upcomingTransitions(query string) (transitionsResponse, error) {
+	var response transitionsResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, "schedule?"+query, &response)
+	return response, err
+}
+
+type rollout httpserver.RolloutResponse
+type rolloutResponse struct {
+	Data  rollout `json:"data"`
+	Error string  `json:"error"`
+}
+
+func (tc *testClient) setSegmentRollout(slug string, body map[string]any) (rolloutResponse, error) {
+	var response rolloutResponse
+	err := tc.proceed(body, http.MethodPut, "segments/"+slug+"/rollout", &response)
+	return response, err
+}
+
+func (tc *testClient) getSegmentRollout(slug string) (rolloutResponse, error) {
+	var response rolloutResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, "segments/"+slug+"/rollout", &response)
+	return response, err
+}
+
+func (tc *testClient) disableSegmentRollout(slug string) (rolloutResponse, error) {
+	var response rolloutResponse
+	err := tc.proceed(map[string]any{}, http.MethodDelete, "segments/"+slug+"/rollout", &response)
+	return response, err
+}
+
+func (tc *testClient) setSegmentRule(slug string, rule string) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(map[string]any{"rule": rule}, http.MethodPut, "segments/"+slug+"/rule", &response)
+	return response, err
+}
+
+func (tc *testClient) setSegmentPayload(slug string, body map[string]any) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(body, http.MethodPut, "segments/"+slug+"/payload", &response)
+	return response, err
+}
+
+func (tc *testClient) removeSegmentPayload(slug string) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(map[string]any{}, http.MethodDelete, "segments/"+slug+"/payload", &response)
+	return response, err
+}
+
+func (tc *testClient) purgeSegment(slug string, confirm string) (segmentProcessedResponse, error) {
+	var response segmentProcessedResponse
+	err := tc.proceed(map[string]any{}, http.MethodDelete, "segments/"+slug+"?confirm="+url.QueryEscape(confirm), &response)
+	return response, err
+}
+
+func (tc *testClient) listSegments(query string) (segmentInfoListResponse, error) {
+	var response segmentInfoListResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, "segments?"+query, &response)
+	return response, err
+}
+
+type experiment httpserver.ExperimentResponse
+type experimentResponse struct {
+	Data  experiment `json:"data"`
+	Error string     `json:"error"`
+}
+
+func (tc *testClient) createExperiment(body map[string]any) (experimentResponse, error) {
+	var response experimentResponse
+	err := tc.proceed(body, http.MethodPost, "experiments", &response)
+	return response, err
+}
+
+func (tc *testClient) getExperiment(key string) (experimentResponse, error) {
+	var response experimentResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, "experiments/"+key, &response)
+	return response, err
+}
+
+type group httpserver.GroupResponse
+type groupResponse struct {
+	Data  group  `json:"data"`
+	Error string `json:"error"`
+}
+
+func (tc *testClient) createGroup(name string, exclusive bool) (groupResponse, error) {
+	var response groupResponse
+	err := tc.proceed(map[string]any{"name": name, "exclusive": exclusive}, http.MethodPost, "groups", &response)
+	return response, err
+}
+
+func (tc *testClient) setSegmentParent(slug string, parent string) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(map[string]any{"parent": parent}, http.MethodPut, "segments/"+slug+"/parent", &response)
+	return response, err
+}
+
+func (tc *testClient) setSegmentGroup(slug string, group string) (segmentInfoResponse, error) {
+	var response segmentInfoResponse
+	err := tc.proceed(map[string]any{"group": group}, http.MethodPut, "segments/"+slug+"/group", &response)
+	return response, err
+}
+
 type changeResult httpserver.ChangeResultResponse
 type changeResultResponse struct {
 	Data  changeResult `json:"data"`
@@ -129,12 +288,122 @@ func (tc *testClient) changeUserSegments(userID int64, add []string, remove []st
 	return response, err
 }
 
+func (tc *testClient) replaceUserSegments(userID int64, add []string, remove []string) (changeResultResponse, error) {
+	body := map[string]any{
+		"add":     add,
+		"remove":  remove,
+		"replace": true,
+	}
+	var response changeResultResponse
+	err := tc.proceed(body, http.MethodPost, fmt.Sprintf("users/%d", userID), &response)
+	return response, err
+}
+
 type segment httpserver.SegmentResponse
 type segmentsResponse struct {
 	Data  []segment `json:"data"`
 	Error string    `json:"error"`
 }
 
+type attributesResult httpserver.AttributesResponse
+type attributesResponse struct {
+	Data  attributesResult `json:"data"`
+	Error string           `json:"error"`
+}
+
+func (tc *testClient) setUserAttributes(userID int64, attrs map[string]any) (attributesResponse, error) {
+	var response attributesResponse
+	err := tc.proceed(map[string]any{"attributes": attrs}, http.MethodPut, fmt.Sprintf("users/%d/attributes", userID), &response)
+	return response, err
+}
+
+func (tc *testClient) getUserAttributes(userID int64) (attributesResponse, error) {
+	var response attributesResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, fmt.Sprintf("users/%d/attributes", userID), &response)
+	return response, err
+}
+
+type bulkAttributesResponse struct {
+	Data  []attributesResult `json:"data"`
+	Error string             `json:"error"`
+}
+
+func (tc *testClient) upsertUserAttributes(users []map[string]any) (bulkAttributesResponse, error) {
+	var response bulkAttributesResponse
+	err := tc.proceed(map[string]any{"users": users}, http.MethodPost, "users/attributes", &response)
+	return response, err
+}
+
+type attributeChange httpserver.AttributeChangeResponse
+type attributeHistoryResponse struct {
+	Data  []attributeChange `json:"data"`
+	Error string            `json:"error"`
+}
+
+func (tc *testClient) getUserAttributeHistory(userID int64) (attributeHistoryResponse, error) {
+	var response attributeHistoryResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, fmt.Sprintf("users/%d/attributes/history", userID), &response)
+	return response, err
+}
+
+type definition httpserver.AttributeDefinitionResponse
+type definitionResponse struct {
+	Data  definition `json:"data"`
+	Error string     `json:"error"`
+}
+
+func (tc *testClient) defineAttribute(name string, typ string) (definitionResponse, error) {
+	var response definitionResponse
+	err := tc.proceed(map[string]any{"name": name, "type": typ}, http.MethodPost, "attributes", &response)
+	return response, err
+}
+
+func (tc *testClient) deleteAttribute(name string) (segmentProcessedResponse, error) {
+	var response segmentProcessedResponse
+	err := tc.proceed(map[string]any{}, http.MethodDelete, "attributes/"+name, &response)
+	return response, err
+}
+
+type config httpserver.ConfigResponse
+type configResponse struct {
+	Data  config `json:"data"`
+	Error string `json:"error"`
+}
+
+func (tc *testClient) getUserConfig(userID int64) (configResponse, error) {
+	var response configResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, fmt.Sprintf("users/%d/config", userID), &response)
+	return response, err
+}
+
+type flagsResponse struct {
+	Data  httpserver.FlagsResponse `json:"data"`
+	Error string                   `json:"error"`
+}
+
+func (tc *testClient) getUserFlags(userID int64, keys string) (flagsResponse, error) {
+	var response flagsResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, fmt.Sprintf("users/%d/flags?keys=%s", userID, url.QueryEscape(keys)), &response)
+	return response, err
+}
+
+type flagResponse struct {
+	Data  httpserver.FlagResponse `json:"data"`
+	Error string                  `json:"error"`
+}
+
+func (tc *testClient) setFlagOverride(userID int64, key string, value any) (flagResponse, error) {
+	var response flagResponse
+	err := tc.proceed(map[string]any{"value": value}, http.MethodPut, fmt.Sprintf("users/%d/flags/%s", userID, key), &response)
+	return response, err
+}
+
+func (tc *testClient) deleteFlagOverride(userID int64, key string) (segmentProcessedResponse, error) {
+	var response segmentProcessedResponse
+	err := tc.proceed(map[string]any{}, http.MethodDelete, fmt.Sprintf("users/%d/flags/%s", userID, key), &response)
+	return response, err
+}
+
 func (tc *testClient) getUserSegments(userID int64) (segmentsResponse, error) {
 	body := map[string]any{}
 	var response segmentsResponse
@@ -142,6 +411,12 @@ func (tc *testClient) getUserSegments(userID int64) (segmentsResponse, error) {
 	return response, err
 }
 
+func (tc *testClient) getUserImpliedSegments(userID int64) (segmentsResponse, error) {
+	var response segmentsResponse
+	err := tc.proceed(map[string]any{}, http.MethodGet, fmt.Sprintf("users/%d?implied=true", userID), &response)
+	return response, err
+}
+
 func (tc *testClient) getHistory(year, month int) ([][]string, error) {
 	resp, err := tc.request(map[string]any{}, http.MethodGet, fmt.Sprintf("history/%d/%d", year, month))
 	if err != nil {
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSegmentTree(t *testing.T) {
+	client := setupClient()
+	root, child, leaf := randString(20), randString(20), randString(20)
+	for _, slug := range []string{root, child, leaf} {
+		_, err := client.createSegment(slug)
+		require.NoError(t, err)
+	}
+	info, err := client.setSegmentParent(child, root)
+	require.NoError(t, err)
+	require.Equal(t, root, info.Data.Parent)
+	_, err = client.setSegmentParent(leaf, child)
+	require.NoError(t, err)
+
+	_, err = client.setSegmentParent(root, leaf)
+	require.ErrorIs(t, err, ErrConflict, "cycles are rejected")
+	_, err = client.setSegmentParent(root, root)
+	require.ErrorIs(t, err, ErrConflict)
+	_, err = client.setSegmentParent(root, randString(20))
+	require.ErrorIs(t, err, ErrNotFound)
+
+	userID := int64(randInt(1_000_000_000) + 1)
+	_, err = client.changeUserSegments(userID, []string{leaf}, nil)
+	require.NoError(t, err)
+	direct, err := client.getUserSegments(userID)
+	require.NoError(t, err)
+	require.Equal(t, []segment{{Slug: leaf}}, direct.Data)
+	implied, err := client.getUserImpliedSegments(userID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []segment{{Slug: leaf}, {Slug: root, Implied: true}, {Slug: child, Implied: true}}, implied.Data)
+
+	// the parent is implied through paused children, but paused parents are not returned
+	_, err = client.setSegmentState(root, "paused")
+	require.NoError(t, err)
+	implied, err = client.getUserImpliedSegments(userID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []segment{{Slug: leaf}, {Slug: child, Implied: true}}, implied.Data)
+
+	_, err = client.deleteSegment(child)
+	require.ErrorIs(t, err, ErrConflict, "parents are not archived while they have children")
+	_, err = client.deleteSegment(leaf)
+	require.NoError(t, err)
+	_, err = client.deleteSegment(child)
+	require.NoError(t, err)
+	_, err = client.restoreSegment(leaf)
+	require.ErrorIs(t, err, ErrConflict, "children of archived parents are not restored")
+	_, err = client.setSegmentParent(leaf, child)
+	require.ErrorIs(t, err, ErrConflict, "archived segments do not get children")
+	_, err = client.purgeSegment(child, child)
+	require.ErrorIs(t, err, ErrConflict, "parents are not purged while they have children")
+	_, err = client.purgeSegment(leaf, leaf)
+	require.NoError(t, err)
+	_, err = client.purgeSegment(child, child)
+	require.NoError(t, err)
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	entities "user-segmentation/internal/entities/webhooks"
+	"user-segmentation/internal/repo/webhooks"
+)
+
+func TestWebhookDeliveriesQueued(t *testing.T) {
+	ctx := context.Background()
+	_, _ = db.Exec(ctx, "DELETE FROM webhook_deliveries")
+	client := setupClient()
+	slug, other := randString(20), randString(20)
+	userID := int64(randInt(1_000_000) + 1)
+	for _, s := range []string{slug, other} {
+		_, err := client.createSegment(s)
+		require.NoError(t, err)
+	}
+
+	r := webhooks.New(db)
+	hook, err := entities.New("http://localhost:9999/hook", "", []string{slug}, []string{"add"})
+	require.NoError(t, err)
+	hook.ID, err = r.Store(ctx, hook)
+	require.NoError(t, err)
+
+	_, err = client.changeUserSegments(userID, []string{slug, other}, []string{})
+	require.NoError(t, err)
+	_, err = client.changeUserSegments(userID, []string{}, []string{slug})
+	require.NoError(t, err)
+
+	jobs, err := r.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1, "only adding to the subscribed segment is queued")
+	require.Equal(t, hook.ID, jobs[0].WebhookID)
+	require.Equal(t, hook.Secret, jobs[0].Secret)
+	var event map[string]any
+	require.NoError(t, json.Unmarshal(jobs[0].Payload, &event))
+	require.Equal(t, slug, event["segment"])
+	require.Equal(t, "add", event["operation"])
+	require.EqualValues(t, userID, event["user_id"])
+
+	jobs, err = r.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, jobs, "claimed delivery is leased")
+
+	deliveries, err := r.Deliveries(ctx, hook.ID, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.NoError(t, r.Fail(ctx, deliveries[0].ID, nil, "connection refused", nil))
+	dead := entities.Dead
+	deliveries, err = r.Deliveries(ctx, hook.ID, &dead, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, 1, deliveries[0].Attempts)
+
+	require.NoError(t, r.Retry(ctx, hook.ID, deliveries[0].ID))
+	jobs, err = r.Claim(ctx, 10, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+}